@@ -8,12 +8,18 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
+	"github.com/bufbuild/protovalidate-go"
+	"github.com/your-org/order-processing-system/pkg/apierrors"
+	"github.com/your-org/order-processing-system/pkg/chaos"
+	"github.com/your-org/order-processing-system/pkg/health"
 	"github.com/your-org/order-processing-system/pkg/inventory"
 	"github.com/your-org/order-processing-system/pkg/observability"
 	inventorypb "github.com/your-org/order-processing-system/pkg/pb/inventory"
+	"github.com/your-org/order-processing-system/pkg/validation"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"google.golang.org/grpc"
@@ -24,6 +30,7 @@ import (
 type inventoryServiceServer struct {
 	inventorypb.UnimplementedInventoryServiceServer
 	service inventory.Service
+	metrics *observability.Metrics
 	logger  *zap.Logger
 }
 
@@ -40,17 +47,18 @@ func (s *inventoryServiceServer) ReserveStock(ctx context.Context, req *inventor
 
 	response, err := s.service.ReserveStock(ctx, req.ProductId, req.Quantity, req.OrderId)
 	if err != nil {
+		// Running out of stock is an expected outcome rather than an error of the service
+		if apierrors.HasReason(err, apierrors.ReasonInsufficientStock) {
+			contextLogger.Warn("Stock reservation rejected", zap.Error(err))
+			s.metrics.InventoryReservations.WithLabelValues(req.ProductId, "failed").Inc()
+			return nil, err
+		}
 		contextLogger.Error("Failed to reserve stock", zap.Error(err))
-		observability.InventoryReservations.WithLabelValues(req.ProductId, "error").Inc()
+		s.metrics.InventoryReservations.WithLabelValues(req.ProductId, "error").Inc()
 		return nil, err
 	}
 
-	// Record metrics based on success/failure
-	status := "success"
-	if !response.Success {
-		status = "failed"
-	}
-	observability.InventoryReservations.WithLabelValues(req.ProductId, status).Inc()
+	s.metrics.InventoryReservations.WithLabelValues(req.ProductId, "success").Inc()
 
 	contextLogger.Info("Stock reservation processed",
 		zap.Bool("success", response.Success),
@@ -95,7 +103,7 @@ func (s *inventoryServiceServer) GetProductStock(ctx context.Context, req *inven
 	}
 
 	// Update current stock gauge metric
-	observability.CurrentStock.WithLabelValues(product.Id, product.Name).Set(float64(product.StockQuantity))
+	s.metrics.CurrentStock.WithLabelValues(product.Id, product.Name).Set(float64(product.StockQuantity))
 
 	contextLogger.Debug("Product stock retrieved",
 		zap.String("product_name", product.Name),
@@ -107,26 +115,44 @@ func (s *inventoryServiceServer) GetProductStock(ctx context.Context, req *inven
 func main() {
 	serviceName := "inventory-service"
 
-	// Initialize structured logger
-	logger, err := observability.NewLogger(serviceName, zapcore.InfoLevel)
+	// Initialize structured logger, hashing or redacting sensitive fields
+	logPolicy, err := observability.DefaultLogPolicy().ParseFields(os.Getenv("LOG_FIELD_POLICIES"))
+	if err != nil {
+		log.Fatalf("Invalid LOG_FIELD_POLICIES: %v", err)
+	}
+	logPolicy.HashKey = []byte(os.Getenv("LOG_HASH_KEY"))
+	// The level can be changed while running through /admin/loglevel
+	logLevel, err := zapcore.ParseLevel(getEnv("LOG_LEVEL", "info"))
+	if err != nil {
+		log.Fatalf("Invalid LOG_LEVEL: %v", err)
+	}
+	logLevels := observability.NewLogLevels(logLevel)
+	logger, err := observability.NewLogger(serviceName, logLevels, logPolicy)
 	if err != nil {
-		log.Fatalf("Failed to initialize logger: %v", err)
+		log.Fatalf("Failed to initialize logger, check LOG_FIELD_POLICIES and LOG_HASH_KEY: %v", err)
 	}
 	defer logger.Sync()
 
 	logger.Info("Starting Inventory Service with observability")
 
-	// Initialize metrics
-	observability.InitMetrics()
+	// Initialize metrics and the in-flight request view
+	metrics := observability.NewMetrics(serviceName)
+	requests := observability.NewRequestTracker(observability.DefaultRecentRequests)
 
 	// Initialize tracing
-	jaegerEndpoint := getEnv("JAEGER_ENDPOINT", "http://localhost:14268/api/traces")
-	cleanup, err := observability.InitTracing(serviceName, jaegerEndpoint, logger)
+	cleanup, err := observability.InitTracing(serviceName, observability.TracingConfigFromEnv(), logger)
 	if err != nil {
 		logger.Fatal("Failed to initialize tracing", zap.Error(err))
 	}
 	defer cleanup()
 
+	// Initialize metrics export
+	stopMetricsExport, err := observability.InitMetricsExport(serviceName, metrics, observability.MetricsExportConfigFromEnv(), logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize metrics export", zap.Error(err))
+	}
+	defer stopMetricsExport()
+
 	// Get configuration from environment variables
 	port := getEnv("PORT", "50052")
 	metricsPort := getEnv("METRICS_PORT", "8081")
@@ -134,14 +160,50 @@ func main() {
 	// Create inventory service
 	inventoryService := inventory.NewService(logger)
 
-	// Create gRPC server with observability interceptors
+	// Fault injection is always wired in but has no rules until they are set
+	// through the admin endpoint, which is only served with FAULT_INJECTION=true
+	faultInjection, err := strconv.ParseBool(getEnv("FAULT_INJECTION", "false"))
+	if err != nil {
+		logger.Fatal("Invalid FAULT_INJECTION", zap.Error(err))
+	}
+	// Changing log levels and listing in-flight requests are only served with
+	// ADMIN_ENDPOINTS_ENABLED=true, as the metrics port has no authentication
+	adminEnabled, err := strconv.ParseBool(getEnv("ADMIN_ENDPOINTS_ENABLED", "false"))
+	if err != nil {
+		logger.Fatal("Invalid ADMIN_ENDPOINTS_ENABLED", zap.Error(err))
+	}
+	pprofEnabled, err := strconv.ParseBool(getEnv("PPROF_ENABLED", "false"))
+	if err != nil {
+		logger.Fatal("Invalid PPROF_ENABLED", zap.Error(err))
+	}
+	faults := chaos.NewInjector(metrics, logger)
+
+	// Validate requests against the constraints declared in the protos
+	validator, err := protovalidate.New()
+	if err != nil {
+		logger.Fatal("Failed to create request validator", zap.Error(err))
+	}
+
+	// Create gRPC server with request IDs, observability, fault injection, error mapping and validation interceptors
 	grpcServer := grpc.NewServer(
-		grpc.UnaryInterceptor(observability.UnaryServerInterceptor(serviceName, logger)),
-		grpc.StreamInterceptor(observability.StreamServerInterceptor(serviceName, logger)),
+		grpc.StatsHandler(observability.ServerStatsHandler(serviceName, logPolicy, metrics, requests)),
+		grpc.ChainUnaryInterceptor(
+			// Outermost, so every other interceptor sees the request ID
+			observability.UnaryServerRequestIDInterceptor(),
+			observability.UnaryServerInterceptor(metrics, logger),
+			chaos.UnaryServerInterceptor(faults),
+			apierrors.UnaryServerInterceptor(logger),
+			validation.UnaryServerInterceptor(validator, logger),
+		),
+		grpc.ChainStreamInterceptor(
+			observability.StreamServerRequestIDInterceptor(),
+			observability.StreamServerInterceptor(metrics, logger),
+		),
 	)
 
 	inventoryServer := &inventoryServiceServer{
 		service: inventoryService,
+		metrics: metrics,
 		logger:  logger,
 	}
 
@@ -149,14 +211,36 @@ func main() {
 	inventorypb.RegisterInventoryServiceServer(grpcServer, inventoryServer)
 	reflection.Register(grpcServer)
 
+	// Report readiness from the stock store
+	checker := health.NewChecker(logger, health.DefaultConfig(), inventorypb.InventoryService_ServiceDesc.ServiceName)
+	checker.AddCheck("inventory-store", inventoryService.Ping)
+	checker.Register(grpcServer)
+	healthCtx, stopHealth := context.WithCancel(context.Background())
+	defer stopHealth()
+	go checker.Run(healthCtx)
+
 	// Start metrics server
 	go func() {
 		mux := http.NewServeMux()
-		mux.Handle("/metrics", observability.MetricsHandler())
-		mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte("OK"))
-		})
+		mux.Handle("/metrics", metrics.Handler())
+		mux.Handle("/livez", checker.LivenessHandler())
+		mux.Handle("/readyz", checker.ReadinessHandler())
+		// Kept for existing probes and dashboards; equivalent to /livez
+		mux.Handle("/health", checker.LivenessHandler())
+		if faultInjection {
+			logger.Warn("Fault injection admin endpoint enabled", zap.String("path", "/admin/faults"))
+			mux.Handle("/admin/faults", faults.Handler())
+		}
+		mux.Handle("/admin/buildinfo", observability.BuildInfoHandler(serviceName))
+		if adminEnabled {
+			logger.Warn("Admin endpoints enabled", zap.Strings("paths", []string{"/admin/loglevel", "/debug/requests"}))
+			mux.Handle("/admin/loglevel", logLevels.Handler())
+			mux.Handle("/debug/requests", requests.Handler())
+		}
+		if pprofEnabled {
+			logger.Warn("Profiling endpoints enabled", zap.String("path", "/debug/pprof/"))
+			mux.Handle("/debug/pprof/", observability.PprofHandler())
+		}
 
 		logger.Info("Starting metrics server", zap.String("port", metricsPort))
 		if err := http.ListenAndServe(":"+metricsPort, mux); err != nil {
@@ -188,6 +272,9 @@ func main() {
 
 	logger.Info("Shutting down Inventory Service")
 
+	// Stop advertising readiness before draining in-flight calls
+	checker.Shutdown()
+
 	// Graceful shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
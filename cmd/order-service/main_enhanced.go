@@ -8,12 +8,25 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/bufbuild/protovalidate-go"
+	"github.com/your-org/order-processing-system/pkg/apierrors"
+	"github.com/your-org/order-processing-system/pkg/chaos"
+	"github.com/your-org/order-processing-system/pkg/currency"
+	"github.com/your-org/order-processing-system/pkg/discovery"
+	"github.com/your-org/order-processing-system/pkg/fraud"
+	"github.com/your-org/order-processing-system/pkg/health"
 	"github.com/your-org/order-processing-system/pkg/observability"
 	"github.com/your-org/order-processing-system/pkg/order"
+	inventrypb "github.com/your-org/order-processing-system/pkg/pb/inventory"
 	orderpb "github.com/your-org/order-processing-system/pkg/pb/order"
+	paymentpb "github.com/your-org/order-processing-system/pkg/pb/payment"
+	"github.com/your-org/order-processing-system/pkg/resilience"
+	"github.com/your-org/order-processing-system/pkg/validation"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"google.golang.org/grpc"
@@ -25,30 +38,29 @@ import (
 type orderServiceServer struct {
 	orderpb.UnimplementedOrderServiceServer
 	service order.Service
+	metrics *observability.Metrics
 	logger  *zap.Logger
 }
 
 // CreateOrder handles order creation requests with enhanced logging and metrics
 func (s *orderServiceServer) CreateOrder(ctx context.Context, req *orderpb.CreateOrderRequest) (*orderpb.CreateOrderResponse, error) {
-	// Add business context to logger
-	contextLogger := observability.LoggerWithCustomerID(
-		observability.LoggerWithTraceContext(ctx, s.logger),
-		req.CustomerId,
-	)
+	// Add business context to logger and downstream calls
+	ctx = withBusinessContext(ctx, observability.BusinessContext{CustomerID: req.CustomerId})
+	contextLogger := observability.LoggerWithTraceContext(ctx, s.logger)
 
 	contextLogger.Info("Processing CreateOrder request",
-		zap.String("customer_id", req.CustomerId),
+		zap.String("currency", req.Currency),
 		zap.Int("items_count", len(req.Items)))
 
-	order, err := s.service.CreateOrder(ctx, req.CustomerId, req.Items)
+	order, err := s.service.CreateOrder(ctx, req)
 	if err != nil {
 		contextLogger.Error("Failed to create order", zap.Error(err))
-		observability.OrdersCreated.WithLabelValues("failed").Inc()
+		s.metrics.OrdersCreated.WithLabelValues("failed").Inc()
 		return nil, err
 	}
 
 	// Record successful order creation
-	observability.OrdersCreated.WithLabelValues("success").Inc()
+	s.metrics.OrdersCreated.WithLabelValues("success").Inc()
 
 	contextLogger.Info("Order created successfully",
 		zap.String("order_id", order.Id),
@@ -59,10 +71,8 @@ func (s *orderServiceServer) CreateOrder(ctx context.Context, req *orderpb.Creat
 
 // GetOrder handles order retrieval requests
 func (s *orderServiceServer) GetOrder(ctx context.Context, req *orderpb.GetOrderRequest) (*orderpb.GetOrderResponse, error) {
-	contextLogger := observability.LoggerWithOrderID(
-		observability.LoggerWithTraceContext(ctx, s.logger),
-		req.OrderId,
-	)
+	ctx = withBusinessContext(ctx, observability.BusinessContext{OrderID: req.OrderId})
+	contextLogger := observability.LoggerWithTraceContext(ctx, s.logger)
 
 	contextLogger.Debug("Processing GetOrder request")
 
@@ -72,16 +82,25 @@ func (s *orderServiceServer) GetOrder(ctx context.Context, req *orderpb.GetOrder
 		return nil, err
 	}
 
+	response := &orderpb.GetOrderResponse{Order: order}
+	if req.DisplayCurrency != "" {
+		amount, err := s.service.ConvertTotal(ctx, order, req.DisplayCurrency)
+		if err != nil {
+			contextLogger.Error("Failed to convert order total", zap.String("display_currency", req.DisplayCurrency), zap.Error(err))
+			return nil, err
+		}
+		response.DisplayAmount = amount
+		response.DisplayCurrency = strings.ToUpper(req.DisplayCurrency)
+	}
+
 	contextLogger.Debug("Order retrieved successfully")
-	return &orderpb.GetOrderResponse{Order: order}, nil
+	return response, nil
 }
 
 // UpdateOrderStatus handles order status update requests
 func (s *orderServiceServer) UpdateOrderStatus(ctx context.Context, req *orderpb.UpdateOrderStatusRequest) (*orderpb.UpdateOrderStatusResponse, error) {
-	contextLogger := observability.LoggerWithOrderID(
-		observability.LoggerWithTraceContext(ctx, s.logger),
-		req.OrderId,
-	)
+	ctx = withBusinessContext(ctx, observability.BusinessContext{OrderID: req.OrderId})
+	contextLogger := observability.LoggerWithTraceContext(ctx, s.logger)
 
 	contextLogger.Info("Processing UpdateOrderStatus request",
 		zap.String("new_status", req.NewStatus.String()))
@@ -98,49 +117,208 @@ func (s *orderServiceServer) UpdateOrderStatus(ctx context.Context, req *orderpb
 	return &orderpb.UpdateOrderStatusResponse{Order: order}, nil
 }
 
+// ReviewOrder handles the resolution of orders held for manual fraud review
+func (s *orderServiceServer) ReviewOrder(ctx context.Context, req *orderpb.ReviewOrderRequest) (*orderpb.ReviewOrderResponse, error) {
+	ctx = withBusinessContext(ctx, observability.BusinessContext{OrderID: req.OrderId})
+	contextLogger := observability.LoggerWithTraceContext(ctx, s.logger)
+
+	contextLogger.Info("Processing ReviewOrder request",
+		zap.Bool("approve", req.Approve),
+		zap.String("reviewer", req.Reviewer))
+
+	order, err := s.service.ReviewOrder(ctx, req.OrderId, req.Approve, req.Reviewer)
+	if err != nil {
+		contextLogger.Error("Failed to review order", zap.Error(err))
+		return nil, err
+	}
+
+	contextLogger.Info("Order review completed",
+		zap.String("status", order.Status.String()))
+
+	return &orderpb.ReviewOrderResponse{Order: order}, nil
+}
+
 func main() {
 	serviceName := "order-service"
 
-	// Initialize structured logger
-	logger, err := observability.NewLogger(serviceName, zapcore.InfoLevel)
+	// Initialize structured logger, hashing or redacting sensitive fields
+	logPolicy, err := observability.DefaultLogPolicy().ParseFields(os.Getenv("LOG_FIELD_POLICIES"))
+	if err != nil {
+		log.Fatalf("Invalid LOG_FIELD_POLICIES: %v", err)
+	}
+	logPolicy.HashKey = []byte(os.Getenv("LOG_HASH_KEY"))
+	// The level can be changed while running through /admin/loglevel
+	logLevel, err := zapcore.ParseLevel(getEnv("LOG_LEVEL", "info"))
+	if err != nil {
+		log.Fatalf("Invalid LOG_LEVEL: %v", err)
+	}
+	logLevels := observability.NewLogLevels(logLevel)
+	logger, err := observability.NewLogger(serviceName, logLevels, logPolicy)
 	if err != nil {
-		log.Fatalf("Failed to initialize logger: %v", err)
+		log.Fatalf("Failed to initialize logger, check LOG_FIELD_POLICIES and LOG_HASH_KEY: %v", err)
 	}
 	defer logger.Sync()
 
 	logger.Info("Starting Order Service with observability")
 
-	// Initialize metrics
-	observability.InitMetrics()
+	// Initialize metrics and the in-flight request view
+	metrics := observability.NewMetrics(serviceName)
+	requests := observability.NewRequestTracker(observability.DefaultRecentRequests)
 
 	// Initialize tracing
-	jaegerEndpoint := getEnv("JAEGER_ENDPOINT", "http://localhost:14268/api/traces")
-	cleanup, err := observability.InitTracing(serviceName, jaegerEndpoint, logger)
+	cleanup, err := observability.InitTracing(serviceName, observability.TracingConfigFromEnv(), logger)
 	if err != nil {
 		logger.Fatal("Failed to initialize tracing", zap.Error(err))
 	}
 	defer cleanup()
 
+	// Initialize metrics export
+	stopMetricsExport, err := observability.InitMetricsExport(serviceName, metrics, observability.MetricsExportConfigFromEnv(), logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize metrics export", zap.Error(err))
+	}
+	defer stopMetricsExport()
+
 	// Get service addresses from environment variables
 	inventoryAddr := getEnv("INVENTORY_SERVICE_ADDR", "localhost:50052")
 	paymentAddr := getEnv("PAYMENT_SERVICE_ADDR", "localhost:50053")
 	port := getEnv("PORT", "50051")
 	metricsPort := getEnv("METRICS_PORT", "8080")
+	settlementCurrency := getEnv("SETTLEMENT_CURRENCY", order.DefaultCurrency)
+	reserveConcurrency, err := strconv.Atoi(getEnv("RESERVE_CONCURRENCY", strconv.Itoa(order.DefaultReserveConcurrency)))
+	if err != nil {
+		logger.Fatal("Invalid RESERVE_CONCURRENCY", zap.Error(err))
+	}
+	deferredPayments := order.DefaultDeferredPaymentConfig()
+	deferredPayments.Enabled, err = strconv.ParseBool(getEnv("DEFER_PAYMENTS", "false"))
+	if err != nil {
+		logger.Fatal("Invalid DEFER_PAYMENTS", zap.Error(err))
+	}
+	deferredPayments.MaxAttempts, err = strconv.Atoi(getEnv("DEFERRED_PAYMENT_MAX_ATTEMPTS", strconv.Itoa(deferredPayments.MaxAttempts)))
+	if err != nil {
+		logger.Fatal("Invalid DEFERRED_PAYMENT_MAX_ATTEMPTS", zap.Error(err))
+	}
 
-	// Connect to inventory service with observability
+	// Load exchange rates; without a rates file only the settlement currency is accepted
+	var rates currency.RateProvider
+	if ratesFile := os.Getenv("EXCHANGE_RATES_FILE"); ratesFile != "" {
+		rates, err = currency.LoadStaticProvider(ratesFile)
+		if err != nil {
+			logger.Fatal("Failed to load exchange rates", zap.String("file", ratesFile), zap.Error(err))
+		}
+	} else {
+		logger.Warn("EXCHANGE_RATES_FILE not set, only the settlement currency is supported",
+			zap.String("settlement_currency", settlementCurrency))
+		rates = currency.NewStaticProvider(settlementCurrency, nil)
+	}
+
+	// Load fraud screening rules
+	fraudConfig := fraud.DefaultConfig()
+	if rulesFile := os.Getenv("FRAUD_RULES_FILE"); rulesFile != "" {
+		fraudConfig, err = fraud.LoadConfig(rulesFile)
+		if err != nil {
+			logger.Fatal("Failed to load fraud rules", zap.String("file", rulesFile), zap.Error(err))
+		}
+	}
+	screener, err := fraud.NewScreener(logger, fraudConfig)
+	if err != nil {
+		logger.Fatal("Failed to create fraud screener", zap.Error(err))
+	}
+
+	// Fault injection is always wired in but has no rules until they are set
+	// through the admin endpoint, which is only served with FAULT_INJECTION=true
+	faultInjection, err := strconv.ParseBool(getEnv("FAULT_INJECTION", "false"))
+	if err != nil {
+		logger.Fatal("Invalid FAULT_INJECTION", zap.Error(err))
+	}
+	// Changing log levels and listing in-flight requests are only served with
+	// ADMIN_ENDPOINTS_ENABLED=true, as the metrics port has no authentication
+	adminEnabled, err := strconv.ParseBool(getEnv("ADMIN_ENDPOINTS_ENABLED", "false"))
+	if err != nil {
+		logger.Fatal("Invalid ADMIN_ENDPOINTS_ENABLED", zap.Error(err))
+	}
+	pprofEnabled, err := strconv.ParseBool(getEnv("PPROF_ENABLED", "false"))
+	if err != nil {
+		logger.Fatal("Invalid PPROF_ENABLED", zap.Error(err))
+	}
+	faults := chaos.NewInjector(metrics, logger)
+
+	retryConfig := resilience.DefaultRetryConfig()
+	timeoutConfig, err := resilience.DefaultTimeoutConfig().ParseTimeouts(os.Getenv("DOWNSTREAM_TIMEOUTS"))
+	if err != nil {
+		logger.Fatal("Invalid DOWNSTREAM_TIMEOUTS", zap.Error(err))
+	}
+	// Resolve every replica of each downstream and balance calls across them;
+	// targets may be plain host:port, dns:///, srv:/// or file:/// targets
+	discovery.RegisterBalancer(metrics, logger)
+	balancerConfig := discovery.DefaultBalancerConfig()
+	balancerConfig.Policy = discovery.Policy(getEnv("LB_POLICY", string(discovery.PolicyRoundRobin)))
+	refreshInterval, err := time.ParseDuration(getEnv("DISCOVERY_REFRESH_INTERVAL", discovery.DefaultRefreshInterval.String()))
+	if err != nil {
+		logger.Fatal("Invalid DISCOVERY_REFRESH_INTERVAL", zap.Error(err))
+	}
+	resolvers := grpc.WithResolvers(
+		discovery.NewFileBuilder(logger, refreshInterval),
+		discovery.NewSRVBuilder(logger, refreshInterval),
+	)
+	inventoryServiceConfig, err := discovery.ServiceConfig(balancerConfig, inventrypb.InventoryService_ServiceDesc.ServiceName)
+	if err != nil {
+		logger.Fatal("Invalid load balancing config", zap.Error(err))
+	}
+	paymentServiceConfig, err := discovery.ServiceConfig(balancerConfig, paymentpb.PaymentService_ServiceDesc.ServiceName)
+	if err != nil {
+		logger.Fatal("Invalid load balancing config", zap.Error(err))
+	}
+
+	inventoryBreaker := resilience.NewCircuitBreaker("inventory-service", resilience.DefaultBreakerConfig(), metrics, logger)
+	paymentBreaker := resilience.NewCircuitBreaker("payment-service", resilience.DefaultBreakerConfig(), metrics, logger)
+
+	// Connect to inventory service with request IDs, observability, circuit breaking, retries, timeouts and fault injection
 	inventoryConn, err := grpc.Dial(inventoryAddr,
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithUnaryInterceptor(observability.UnaryClientInterceptor(serviceName, logger)),
+		grpc.WithStatsHandler(observability.ClientStatsHandler(serviceName, logPolicy, metrics, requests)),
+		resolvers,
+		grpc.WithDefaultServiceConfig(inventoryServiceConfig),
+		grpc.WithChainUnaryInterceptor(
+			observability.UnaryClientRequestIDInterceptor(),
+			observability.UnaryClientInterceptor(metrics, logger),
+			resilience.UnaryClientBreakerInterceptor(inventoryBreaker),
+			resilience.UnaryClientRetryInterceptor(retryConfig, metrics, logger),
+			// Innermost, so every retry attempt gets its own deadline
+			resilience.UnaryClientTimeoutInterceptor(timeoutConfig),
+			// Below the timeout, so injected faults behave like a slow or failing network
+			chaos.UnaryClientInterceptor(faults),
+		),
+		grpc.WithChainStreamInterceptor(
+			observability.StreamClientRequestIDInterceptor(),
+			observability.StreamClientInterceptor(metrics, logger),
+		),
 	)
 	if err != nil {
 		logger.Fatal("Failed to connect to inventory service", zap.String("address", inventoryAddr), zap.Error(err))
 	}
 	defer inventoryConn.Close()
 
-	// Connect to payment service with observability
+	// Connect to payment service with request IDs, observability, circuit breaking, retries, timeouts and fault injection
 	paymentConn, err := grpc.Dial(paymentAddr,
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithUnaryInterceptor(observability.UnaryClientInterceptor(serviceName, logger)),
+		grpc.WithStatsHandler(observability.ClientStatsHandler(serviceName, logPolicy, metrics, requests)),
+		resolvers,
+		grpc.WithDefaultServiceConfig(paymentServiceConfig),
+		grpc.WithChainUnaryInterceptor(
+			observability.UnaryClientRequestIDInterceptor(),
+			observability.UnaryClientInterceptor(metrics, logger),
+			resilience.UnaryClientBreakerInterceptor(paymentBreaker),
+			resilience.UnaryClientRetryInterceptor(retryConfig, metrics, logger),
+			// Innermost, so every retry attempt gets its own deadline
+			resilience.UnaryClientTimeoutInterceptor(timeoutConfig),
+			// Below the timeout, so injected faults behave like a slow or failing network
+			chaos.UnaryClientInterceptor(faults),
+		),
+		grpc.WithChainStreamInterceptor(
+			observability.StreamClientRequestIDInterceptor(),
+			observability.StreamClientInterceptor(metrics, logger),
+		),
 	)
 	if err != nil {
 		logger.Fatal("Failed to connect to payment service", zap.String("address", paymentAddr), zap.Error(err))
@@ -148,16 +326,44 @@ func main() {
 	defer paymentConn.Close()
 
 	// Create order service
-	orderService := order.NewService(logger, inventoryConn, paymentConn)
+	orderService := order.NewService(logger, metrics, inventoryConn, paymentConn, rates, screener, order.Config{
+		SettlementCurrency: settlementCurrency,
+		PaymentBreaker:     paymentBreaker,
+		ReserveConcurrency: reserveConcurrency,
+		DeferredPayments:   deferredPayments,
+	})
+
+	// Charge orders accepted while the payment service was unavailable
+	workerCtx, stopWorker := context.WithCancel(context.Background())
+	defer stopWorker()
+	go orderService.RetryDeferredPayments(workerCtx)
+
+	// Validate requests against the constraints declared in the protos
+	validator, err := protovalidate.New()
+	if err != nil {
+		logger.Fatal("Failed to create request validator", zap.Error(err))
+	}
 
-	// Create gRPC server with observability interceptors
+	// Create gRPC server with request IDs, observability, fault injection, error mapping and validation interceptors
 	grpcServer := grpc.NewServer(
-		grpc.UnaryInterceptor(observability.UnaryServerInterceptor(serviceName, logger)),
-		grpc.StreamInterceptor(observability.StreamServerInterceptor(serviceName, logger)),
+		grpc.StatsHandler(observability.ServerStatsHandler(serviceName, logPolicy, metrics, requests)),
+		grpc.ChainUnaryInterceptor(
+			// Outermost, so every other interceptor sees the request ID
+			observability.UnaryServerRequestIDInterceptor(),
+			observability.UnaryServerInterceptor(metrics, logger),
+			chaos.UnaryServerInterceptor(faults),
+			apierrors.UnaryServerInterceptor(logger),
+			validation.UnaryServerInterceptor(validator, logger),
+		),
+		grpc.ChainStreamInterceptor(
+			observability.StreamServerRequestIDInterceptor(),
+			observability.StreamServerInterceptor(metrics, logger),
+		),
 	)
 
 	orderServer := &orderServiceServer{
 		service: orderService,
+		metrics: metrics,
 		logger:  logger,
 	}
 
@@ -165,14 +371,66 @@ func main() {
 	orderpb.RegisterOrderServiceServer(grpcServer, orderServer)
 	reflection.Register(grpcServer)
 
+	// Probe downstream health over connections of their own, without the
+	// breakers, retries and fault injection of the connections above
+	inventoryHealthConn, err := grpc.Dial(inventoryAddr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		resolvers,
+		grpc.WithDefaultServiceConfig(inventoryServiceConfig),
+	)
+	if err != nil {
+		logger.Fatal("Failed to connect to inventory service", zap.String("address", inventoryAddr), zap.Error(err))
+	}
+	defer inventoryHealthConn.Close()
+	paymentHealthConn, err := grpc.Dial(paymentAddr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		resolvers,
+		grpc.WithDefaultServiceConfig(paymentServiceConfig),
+	)
+	if err != nil {
+		logger.Fatal("Failed to connect to payment service", zap.String("address", paymentAddr), zap.Error(err))
+	}
+	defer paymentHealthConn.Close()
+
+	// Report readiness from downstream connectivity and the order store
+	checker := health.NewChecker(logger, health.DefaultConfig(), orderpb.OrderService_ServiceDesc.ServiceName)
+	checker.AddCheck("inventory-service", health.GRPCCheck(inventoryHealthConn, inventrypb.InventoryService_ServiceDesc.ServiceName))
+	paymentCheck := health.GRPCCheck(paymentHealthConn, paymentpb.PaymentService_ServiceDesc.ServiceName)
+	if deferredPayments.Enabled {
+		// Orders are still accepted while payment is down, so its outage
+		// must not take this service out of rotation
+		checker.AddInformationalCheck("payment-service", paymentCheck)
+	} else {
+		checker.AddCheck("payment-service", paymentCheck)
+	}
+	checker.AddCheck("order-store", orderService.Ping)
+	checker.Register(grpcServer)
+	healthCtx, stopHealth := context.WithCancel(context.Background())
+	defer stopHealth()
+	go checker.Run(healthCtx)
+
 	// Start metrics server
 	go func() {
 		mux := http.NewServeMux()
-		mux.Handle("/metrics", observability.MetricsHandler())
-		mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte("OK"))
-		})
+		mux.Handle("/metrics", metrics.Handler())
+		mux.Handle("/livez", checker.LivenessHandler())
+		mux.Handle("/readyz", checker.ReadinessHandler())
+		// Kept for existing probes and dashboards; equivalent to /livez
+		mux.Handle("/health", checker.LivenessHandler())
+		if faultInjection {
+			logger.Warn("Fault injection admin endpoint enabled", zap.String("path", "/admin/faults"))
+			mux.Handle("/admin/faults", faults.Handler())
+		}
+		mux.Handle("/admin/buildinfo", observability.BuildInfoHandler(serviceName))
+		if adminEnabled {
+			logger.Warn("Admin endpoints enabled", zap.Strings("paths", []string{"/admin/loglevel", "/debug/requests"}))
+			mux.Handle("/admin/loglevel", logLevels.Handler())
+			mux.Handle("/debug/requests", requests.Handler())
+		}
+		if pprofEnabled {
+			logger.Warn("Profiling endpoints enabled", zap.String("path", "/debug/pprof/"))
+			mux.Handle("/debug/pprof/", observability.PprofHandler())
+		}
 
 		logger.Info("Starting metrics server", zap.String("port", metricsPort))
 		if err := http.ListenAndServe(":"+metricsPort, mux); err != nil {
@@ -204,6 +462,9 @@ func main() {
 
 	logger.Info("Shutting down Order Service")
 
+	// Stop advertising readiness before draining in-flight calls
+	checker.Shutdown()
+
 	// Graceful shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -223,6 +484,13 @@ func main() {
 	}
 }
 
+// withBusinessContext adds the channel and tenant the caller sent, and what
+// the request identifies, to the baggage passed on to downstream services
+func withBusinessContext(ctx context.Context, business observability.BusinessContext) context.Context {
+	ctx = observability.ContextWithBusinessContext(ctx, observability.BusinessContextFromMetadata(ctx))
+	return observability.ContextWithBusinessContext(ctx, business)
+}
+
 // getEnv gets an environment variable with a default value
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
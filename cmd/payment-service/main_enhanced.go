@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"log"
@@ -8,12 +9,20 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
 	"syscall"
 	"time"
 
+	"github.com/bufbuild/protovalidate-go"
+	"github.com/your-org/order-processing-system/pkg/apierrors"
+	"github.com/your-org/order-processing-system/pkg/chaos"
+	"github.com/your-org/order-processing-system/pkg/health"
 	"github.com/your-org/order-processing-system/pkg/observability"
 	"github.com/your-org/order-processing-system/pkg/payment"
 	paymentpb "github.com/your-org/order-processing-system/pkg/pb/payment"
+	"github.com/your-org/order-processing-system/pkg/reconciliation"
+	"github.com/your-org/order-processing-system/pkg/validation"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"google.golang.org/grpc"
@@ -23,8 +32,11 @@ import (
 // paymentServiceServer implements the gRPC PaymentService interface with observability
 type paymentServiceServer struct {
 	paymentpb.UnimplementedPaymentServiceServer
-	service payment.Service
-	logger  *zap.Logger
+	service        payment.Service
+	vault          payment.Vault
+	reconciliation reconciliation.Service
+	metrics        *observability.Metrics
+	logger         *zap.Logger
 }
 
 // ProcessPayment handles payment processing requests with enhanced logging and metrics
@@ -40,12 +52,12 @@ func (s *paymentServiceServer) ProcessPayment(ctx context.Context, req *paymentp
 	contextLogger.Info("Processing payment request",
 		zap.Float64("amount", req.Amount),
 		zap.String("currency", req.Currency),
-		zap.String("payment_method", req.PaymentMethod))
+		zap.String("payment_method", payment.MethodType(req.Method)))
 
 	response, err := s.service.ProcessPayment(ctx, req)
 	if err != nil {
 		contextLogger.Error("Failed to process payment", zap.Error(err))
-		observability.PaymentsProcessed.WithLabelValues("error").Inc()
+		s.metrics.PaymentsProcessed.WithLabelValues("error").Inc()
 		return nil, err
 	}
 
@@ -54,7 +66,7 @@ func (s *paymentServiceServer) ProcessPayment(ctx context.Context, req *paymentp
 	if response.Status != paymentpb.PaymentStatus_PAYMENT_STATUS_SUCCESS {
 		status = "failed"
 	}
-	observability.PaymentsProcessed.WithLabelValues(status).Inc()
+	s.metrics.PaymentsProcessed.WithLabelValues(status).Inc()
 
 	contextLogger.Info("Payment processed",
 		zap.String("payment_id", response.PaymentId),
@@ -64,59 +76,246 @@ func (s *paymentServiceServer) ProcessPayment(ctx context.Context, req *paymentp
 	return response, nil
 }
 
+// ReconcileSettlement handles acquirer settlement reconciliation requests
+func (s *paymentServiceServer) ReconcileSettlement(ctx context.Context, req *paymentpb.ReconcileSettlementRequest) (*paymentpb.ReconcileSettlementResponse, error) {
+	contextLogger := observability.LoggerWithTraceContext(ctx, s.logger)
+
+	contextLogger.Info("Processing ReconcileSettlement request",
+		zap.String("batch_id", req.BatchId),
+		zap.Int("file_size", len(req.SettlementCsv)))
+
+	report, err := s.reconciliation.Reconcile(ctx, req.BatchId, bytes.NewReader(req.SettlementCsv))
+	if err != nil {
+		contextLogger.Error("Failed to reconcile settlement", zap.Error(err))
+		return nil, err
+	}
+
+	// Record reconciliation outcomes
+	for _, entry := range report.Entries {
+		s.metrics.ReconciliationEntries.WithLabelValues(entry.Status.String()).Inc()
+	}
+
+	contextLogger.Info("Settlement reconciled",
+		zap.String("batch_id", report.BatchId),
+		zap.Int32("matched", report.MatchedCount),
+		zap.Int32("mismatched", report.MismatchedCount),
+		zap.Int32("missing_payment", report.MissingPaymentCount),
+		zap.Int32("missing_settlement", report.MissingSettlementCount))
+
+	return &paymentpb.ReconcileSettlementResponse{Report: report}, nil
+}
+
+// SavePaymentMethod handles requests to vault a customer's payment method
+func (s *paymentServiceServer) SavePaymentMethod(ctx context.Context, req *paymentpb.SavePaymentMethodRequest) (*paymentpb.SavePaymentMethodResponse, error) {
+	contextLogger := observability.LoggerWithCustomerID(
+		observability.LoggerWithTraceContext(ctx, s.logger),
+		req.CustomerId,
+	)
+
+	contextLogger.Info("Processing SavePaymentMethod request",
+		zap.String("payment_method", payment.MethodType(req.Method)))
+
+	saved, err := s.vault.Save(ctx, req.CustomerId, req.Method)
+	if err != nil {
+		contextLogger.Error("Failed to save payment method", zap.Error(err))
+		return nil, err
+	}
+
+	return &paymentpb.SavePaymentMethodResponse{SavedMethod: saved}, nil
+}
+
+// ListPaymentMethods handles requests for a customer's saved payment methods
+func (s *paymentServiceServer) ListPaymentMethods(ctx context.Context, req *paymentpb.ListPaymentMethodsRequest) (*paymentpb.ListPaymentMethodsResponse, error) {
+	contextLogger := observability.LoggerWithCustomerID(
+		observability.LoggerWithTraceContext(ctx, s.logger),
+		req.CustomerId,
+	)
+
+	contextLogger.Debug("Processing ListPaymentMethods request")
+
+	methods, err := s.vault.List(ctx, req.CustomerId)
+	if err != nil {
+		contextLogger.Error("Failed to list payment methods", zap.Error(err))
+		return nil, err
+	}
+
+	return &paymentpb.ListPaymentMethodsResponse{SavedMethods: methods}, nil
+}
+
+// DeletePaymentMethod handles requests to remove a saved payment method
+func (s *paymentServiceServer) DeletePaymentMethod(ctx context.Context, req *paymentpb.DeletePaymentMethodRequest) (*paymentpb.DeletePaymentMethodResponse, error) {
+	contextLogger := observability.LoggerWithCustomerID(
+		observability.LoggerWithTraceContext(ctx, s.logger),
+		req.CustomerId,
+	)
+
+	contextLogger.Info("Processing DeletePaymentMethod request", zap.String("token", req.Token))
+
+	if err := s.vault.Delete(ctx, req.CustomerId, req.Token); err != nil {
+		contextLogger.Error("Failed to delete payment method", zap.Error(err))
+		return nil, err
+	}
+
+	return &paymentpb.DeletePaymentMethodResponse{}, nil
+}
+
 func main() {
 	serviceName := "payment-service"
 
-	// Initialize structured logger
-	logger, err := observability.NewLogger(serviceName, zapcore.InfoLevel)
+	// Initialize structured logger, hashing or redacting sensitive fields
+	logPolicy, err := observability.DefaultLogPolicy().ParseFields(os.Getenv("LOG_FIELD_POLICIES"))
+	if err != nil {
+		log.Fatalf("Invalid LOG_FIELD_POLICIES: %v", err)
+	}
+	logPolicy.HashKey = []byte(os.Getenv("LOG_HASH_KEY"))
+	// The level can be changed while running through /admin/loglevel
+	logLevel, err := zapcore.ParseLevel(getEnv("LOG_LEVEL", "info"))
+	if err != nil {
+		log.Fatalf("Invalid LOG_LEVEL: %v", err)
+	}
+	logLevels := observability.NewLogLevels(logLevel)
+	logger, err := observability.NewLogger(serviceName, logLevels, logPolicy)
 	if err != nil {
-		log.Fatalf("Failed to initialize logger: %v", err)
+		log.Fatalf("Failed to initialize logger, check LOG_FIELD_POLICIES and LOG_HASH_KEY: %v", err)
 	}
 	defer logger.Sync()
 
 	logger.Info("Starting Payment Service with observability")
 
-	// Initialize metrics
-	observability.InitMetrics()
+	// Initialize metrics and the in-flight request view
+	metrics := observability.NewMetrics(serviceName)
+	requests := observability.NewRequestTracker(observability.DefaultRecentRequests)
 
 	// Initialize tracing
-	jaegerEndpoint := getEnv("JAEGER_ENDPOINT", "http://localhost:14268/api/traces")
-	cleanup, err := observability.InitTracing(serviceName, jaegerEndpoint, logger)
+	cleanup, err := observability.InitTracing(serviceName, observability.TracingConfigFromEnv(), logger)
 	if err != nil {
 		logger.Fatal("Failed to initialize tracing", zap.Error(err))
 	}
 	defer cleanup()
 
+	// Initialize metrics export
+	stopMetricsExport, err := observability.InitMetricsExport(serviceName, metrics, observability.MetricsExportConfigFromEnv(), logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize metrics export", zap.Error(err))
+	}
+	defer stopMetricsExport()
+
 	// Get configuration from environment variables
 	port := getEnv("PORT", "50053")
 	metricsPort := getEnv("METRICS_PORT", "8082")
+	vaultKeyFile := getEnv("VAULT_KEY_FILE", "")
+	vaultFile := ""
+	if vaultKeyFile != "" {
+		vaultFile = getEnv("VAULT_FILE", filepath.Join(filepath.Dir(vaultKeyFile), "vault.json"))
+	}
+
+	// Create payment method vault. Saved methods are kept in the vault file
+	// next to the key; without a key file every start gets a fresh key and
+	// saved methods only live in memory.
+	var vaultKey []byte
+	if vaultKeyFile != "" {
+		vaultKey, err = payment.LoadOrCreateVaultKey(vaultKeyFile)
+		if err != nil {
+			logger.Fatal("Failed to load vault key", zap.String("file", vaultKeyFile), zap.Error(err))
+		}
+	} else {
+		logger.Warn("VAULT_KEY_FILE not set, using a random vault key")
+		vaultKey, err = payment.GenerateVaultKey()
+		if err != nil {
+			logger.Fatal("Failed to generate vault key", zap.Error(err))
+		}
+	}
+	vault, err := payment.NewVault(logger, vaultKey, vaultFile)
+	if err != nil {
+		logger.Fatal("Failed to create payment method vault", zap.Error(err))
+	}
 
 	// Create payment service
-	paymentService := payment.NewService(logger)
+	paymentService := payment.NewService(logger, payment.DefaultGateways(logger), vault)
+
+	// Fault injection is always wired in but has no rules until they are set
+	// through the admin endpoint, which is only served with FAULT_INJECTION=true
+	faultInjection, err := strconv.ParseBool(getEnv("FAULT_INJECTION", "false"))
+	if err != nil {
+		logger.Fatal("Invalid FAULT_INJECTION", zap.Error(err))
+	}
+	// Changing log levels and listing in-flight requests are only served with
+	// ADMIN_ENDPOINTS_ENABLED=true, as the metrics port has no authentication
+	adminEnabled, err := strconv.ParseBool(getEnv("ADMIN_ENDPOINTS_ENABLED", "false"))
+	if err != nil {
+		logger.Fatal("Invalid ADMIN_ENDPOINTS_ENABLED", zap.Error(err))
+	}
+	pprofEnabled, err := strconv.ParseBool(getEnv("PPROF_ENABLED", "false"))
+	if err != nil {
+		logger.Fatal("Invalid PPROF_ENABLED", zap.Error(err))
+	}
+	faults := chaos.NewInjector(metrics, logger)
+
+	// Validate requests against the constraints declared in the protos
+	validator, err := protovalidate.New()
+	if err != nil {
+		logger.Fatal("Failed to create request validator", zap.Error(err))
+	}
 
-	// Create gRPC server with observability interceptors
+	// Create gRPC server with request IDs, observability, fault injection, error mapping and validation interceptors
 	grpcServer := grpc.NewServer(
-		grpc.UnaryInterceptor(observability.UnaryServerInterceptor(serviceName, logger)),
-		grpc.StreamInterceptor(observability.StreamServerInterceptor(serviceName, logger)),
+		grpc.StatsHandler(observability.ServerStatsHandler(serviceName, logPolicy, metrics, requests)),
+		grpc.ChainUnaryInterceptor(
+			// Outermost, so every other interceptor sees the request ID
+			observability.UnaryServerRequestIDInterceptor(),
+			observability.UnaryServerInterceptor(metrics, logger),
+			chaos.UnaryServerInterceptor(faults),
+			apierrors.UnaryServerInterceptor(logger),
+			validation.UnaryServerInterceptor(validator, logger),
+		),
+		grpc.ChainStreamInterceptor(
+			observability.StreamServerRequestIDInterceptor(),
+			observability.StreamServerInterceptor(metrics, logger),
+		),
 	)
 
 	paymentServer := &paymentServiceServer{
-		service: paymentService,
-		logger:  logger,
+		service:        paymentService,
+		vault:          vault,
+		reconciliation: reconciliation.NewService(logger, paymentService),
+		metrics:        metrics,
+		logger:         logger,
 	}
 
 	// Register services
 	paymentpb.RegisterPaymentServiceServer(grpcServer, paymentServer)
 	reflection.Register(grpcServer)
 
+	// Report readiness from the payment store and vault
+	checker := health.NewChecker(logger, health.DefaultConfig(), paymentpb.PaymentService_ServiceDesc.ServiceName)
+	checker.AddCheck("payment-store", paymentService.Ping)
+	checker.Register(grpcServer)
+	healthCtx, stopHealth := context.WithCancel(context.Background())
+	defer stopHealth()
+	go checker.Run(healthCtx)
+
 	// Start metrics server
 	go func() {
 		mux := http.NewServeMux()
-		mux.Handle("/metrics", observability.MetricsHandler())
-		mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte("OK"))
-		})
+		mux.Handle("/metrics", metrics.Handler())
+		mux.Handle("/livez", checker.LivenessHandler())
+		mux.Handle("/readyz", checker.ReadinessHandler())
+		// Kept for existing probes and dashboards; equivalent to /livez
+		mux.Handle("/health", checker.LivenessHandler())
+		if faultInjection {
+			logger.Warn("Fault injection admin endpoint enabled", zap.String("path", "/admin/faults"))
+			mux.Handle("/admin/faults", faults.Handler())
+		}
+		mux.Handle("/admin/buildinfo", observability.BuildInfoHandler(serviceName))
+		if adminEnabled {
+			logger.Warn("Admin endpoints enabled", zap.Strings("paths", []string{"/admin/loglevel", "/debug/requests"}))
+			mux.Handle("/admin/loglevel", logLevels.Handler())
+			mux.Handle("/debug/requests", requests.Handler())
+		}
+		if pprofEnabled {
+			logger.Warn("Profiling endpoints enabled", zap.String("path", "/debug/pprof/"))
+			mux.Handle("/debug/pprof/", observability.PprofHandler())
+		}
 
 		logger.Info("Starting metrics server", zap.String("port", metricsPort))
 		if err := http.ListenAndServe(":"+metricsPort, mux); err != nil {
@@ -148,6 +347,9 @@ func main() {
 
 	logger.Info("Shutting down Payment Service")
 
+	// Stop advertising readiness before draining in-flight calls
+	checker.Shutdown()
+
 	// Graceful shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
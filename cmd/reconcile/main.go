@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	paymentpb "github.com/your-org/order-processing-system/pkg/pb/payment"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func main() {
+	file := flag.String("file", "", "Path to the acquirer settlement CSV file")
+	batchID := flag.String("batch", "", "Settlement batch ID (defaults to the file name)")
+	addr := flag.String("addr", getEnv("PAYMENT_SERVICE_ADDR", "localhost:50053"), "Payment service address")
+	failOnDiscrepancy := flag.Bool("fail-on-discrepancy", false, "Exit with status 1 when any entry is not matched")
+	flag.Parse()
+
+	if *file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	settlement, err := os.ReadFile(*file)
+	if err != nil {
+		log.Fatalf("Failed to read settlement file: %v", err)
+	}
+
+	if *batchID == "" {
+		*batchID = strings.TrimSuffix(filepath.Base(*file), filepath.Ext(*file))
+	}
+
+	// Connect to payment service
+	conn, err := grpc.Dial(*addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Fatalf("Failed to connect to payment service: %v", err)
+	}
+	defer conn.Close()
+
+	client := paymentpb.NewPaymentServiceClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	resp, err := client.ReconcileSettlement(ctx, &paymentpb.ReconcileSettlementRequest{
+		BatchId:       *batchID,
+		SettlementCsv: settlement,
+	})
+	if err != nil {
+		log.Fatalf("Failed to reconcile settlement: %v", err)
+	}
+
+	report := resp.Report
+	printReport(report)
+
+	discrepancies := report.MismatchedCount + report.MissingPaymentCount + report.MissingSettlementCount
+	if *failOnDiscrepancy && discrepancies > 0 {
+		os.Exit(1)
+	}
+}
+
+// printReport writes a human readable reconciliation report to stdout
+func printReport(report *paymentpb.ReconciliationReport) {
+	fmt.Printf("Batch:              %s\n", report.BatchId)
+	fmt.Printf("Generated at:       %s\n", report.GeneratedAt)
+	fmt.Printf("Matched:            %d\n", report.MatchedCount)
+	fmt.Printf("Mismatched:         %d\n", report.MismatchedCount)
+	fmt.Printf("Missing payment:    %d\n", report.MissingPaymentCount)
+	fmt.Printf("Missing settlement: %d\n\n", report.MissingSettlementCount)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STATUS\tTRANSACTION\tEXPECTED\tSETTLED\tCURRENCY\tREASON")
+	for _, entry := range report.Entries {
+		status := strings.TrimPrefix(entry.Status.String(), "RECONCILIATION_STATUS_")
+		fmt.Fprintf(w, "%s\t%s\t%.2f\t%.2f\t%s\t%s\n",
+			status, entry.TransactionId, entry.ExpectedAmount, entry.SettledAmount, entry.Currency, entry.Reason)
+	}
+	w.Flush()
+}
+
+// getEnv gets an environment variable with a default value
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
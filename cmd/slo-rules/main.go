@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/your-org/order-processing-system/pkg/slo"
+)
+
+func main() {
+	config := flag.String("config", "deployments/docker/slos.json", "Path to the SLO definitions")
+	output := flag.String("output", "", "Path of the Prometheus rule file to write (defaults to stdout)")
+	check := flag.Bool("check", false, "Exit with status 1 if the output file is not up to date instead of writing it")
+	flag.Parse()
+
+	if *check && *output == "" {
+		log.Fatalf("-check needs -output")
+	}
+
+	slos, err := slo.LoadConfig(*config)
+	if err != nil {
+		log.Fatalf("Failed to load SLOs: %v", err)
+	}
+
+	rules, err := slo.Generate(slos)
+	if err != nil {
+		log.Fatalf("Failed to generate rules: %v", err)
+	}
+	data, err := rules.Marshal()
+	if err != nil {
+		log.Fatalf("Failed to generate rules: %v", err)
+	}
+	data = append([]byte(fmt.Sprintf("# Generated by cmd/slo-rules from %s. DO NOT EDIT.\n", *config)), data...)
+
+	switch {
+	case *check:
+		current, err := os.ReadFile(*output)
+		if err != nil {
+			log.Fatalf("Failed to read rules: %v", err)
+		}
+		if !bytes.Equal(current, data) {
+			fmt.Fprintf(os.Stderr, "%s is out of date with %s; run make slo-rules\n", *output, *config)
+			os.Exit(1)
+		}
+	case *output == "":
+		os.Stdout.Write(data)
+	default:
+		if err := os.WriteFile(*output, data, 0o644); err != nil {
+			log.Fatalf("Failed to write rules: %v", err)
+		}
+	}
+}
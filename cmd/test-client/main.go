@@ -2,12 +2,17 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"strings"
 	"time"
 
+	"github.com/your-org/order-processing-system/pkg/apierrors"
 	orderpb "github.com/your-org/order-processing-system/pkg/pb/order"
+	paymentpb "github.com/your-org/order-processing-system/pkg/pb/payment"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 )
 
 func main() {
@@ -26,6 +31,24 @@ func main() {
 
 	orderReq := &orderpb.CreateOrderRequest{
 		CustomerId: "customer-123",
+		Currency:   "USD",
+		PaymentMethod: &paymentpb.PaymentMethod{
+			Method: &paymentpb.PaymentMethod_Card{
+				Card: &paymentpb.CardDetails{
+					Number:      "4111111111111111",
+					ExpiryMonth: 12,
+					ExpiryYear:  int32(time.Now().Year() + 2),
+					Cvv:         "123",
+					HolderName:  "Test Customer",
+				},
+			},
+		},
+		RiskContext: &orderpb.RiskContext{
+			Email:           "customer-123@example.com",
+			IpCountry:       "US",
+			BillingCountry:  "US",
+			ShippingCountry: "US",
+		},
 		Items: []*orderpb.OrderItem{
 			{
 				ProductId: "product-1",
@@ -43,11 +66,11 @@ func main() {
 	log.Println("Creating order...")
 	orderResp, err := client.CreateOrder(ctx, orderReq)
 	if err != nil {
-		log.Fatalf("Failed to create order: %v", err)
+		log.Fatalf("Failed to create order: %s", describeError(err))
 	}
 
 	log.Printf("Order created successfully: %s", orderResp.Order.Id)
-	log.Printf("Total amount: $%.2f", orderResp.Order.TotalAmount)
+	log.Printf("Total amount: %.2f %s", orderResp.Order.TotalAmount, orderResp.Order.Currency)
 	log.Printf("Status: %s", orderResp.Order.Status.String())
 
 	// Get the order
@@ -55,7 +78,7 @@ func main() {
 	getReq := &orderpb.GetOrderRequest{OrderId: orderResp.Order.Id}
 	getResp, err := client.GetOrder(ctx, getReq)
 	if err != nil {
-		log.Fatalf("Failed to get order: %v", err)
+		log.Fatalf("Failed to get order: %s", describeError(err))
 	}
 
 	log.Printf("Retrieved order: %s", getResp.Order.Id)
@@ -70,10 +93,25 @@ func main() {
 	}
 	updateResp, err := client.UpdateOrderStatus(ctx, updateReq)
 	if err != nil {
-		log.Fatalf("Failed to update order status: %v", err)
+		log.Fatalf("Failed to update order status: %s", describeError(err))
 	}
 
 	log.Printf("Order status updated to: %s", updateResp.Order.Status.String())
 	log.Println("Test completed successfully!")
 }
 
+// describeError formats a gRPC error with its status code and error details
+func describeError(err error) string {
+	st := status.Convert(err)
+	parts := []string{fmt.Sprintf("%s: %s", st.Code(), st.Message())}
+	if reason := apierrors.Reason(err); reason != "" {
+		parts = append(parts, "reason="+reason)
+	}
+	for _, v := range apierrors.FieldViolations(err) {
+		parts = append(parts, fmt.Sprintf("%s (%s)", v.Field, v.Description))
+	}
+	if requestID := apierrors.RequestID(err); requestID != "" {
+		parts = append(parts, "request_id="+requestID)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,115 @@
+// Package apierrors maps domain errors to gRPC status errors carrying
+// machine-readable details, and reads those details back on the client side.
+package apierrors
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// Domain identifies this system in ErrorInfo details
+const Domain = "order-processing-system"
+
+// Reasons reported in ErrorInfo details; clients should branch on these rather than on messages
+const (
+	ReasonInvalidRequest           = "INVALID_REQUEST"
+	ReasonOrderNotFound            = "ORDER_NOT_FOUND"
+	ReasonProductNotFound          = "PRODUCT_NOT_FOUND"
+	ReasonPaymentNotFound          = "PAYMENT_NOT_FOUND"
+	ReasonPaymentMethodNotFound    = "PAYMENT_METHOD_NOT_FOUND"
+	ReasonInsufficientStock        = "INSUFFICIENT_STOCK"
+	ReasonReservationConflict      = "RESERVATION_CONFLICT"
+	ReasonInvalidOrderStatus       = "INVALID_ORDER_STATUS"
+	ReasonExchangeRateUnavailable  = "EXCHANGE_RATE_UNAVAILABLE"
+	ReasonPaymentMethodUnavailable = "PAYMENT_METHOD_UNAVAILABLE"
+	ReasonPaymentDeclined          = "PAYMENT_DECLINED"
+	ReasonPaymentInProgress        = "PAYMENT_IN_PROGRESS"
+	ReasonPaymentConflict          = "PAYMENT_CONFLICT"
+	ReasonFraudRejected            = "FRAUD_REJECTED"
+	ReasonFraudScreeningFailed     = "FRAUD_SCREENING_FAILED"
+	ReasonServiceUnavailable       = "SERVICE_UNAVAILABLE"
+	ReasonGatewayUnavailable       = "GATEWAY_UNAVAILABLE"
+)
+
+// FieldViolation describes one invalid field of a request
+type FieldViolation struct {
+	// Field is the path of the field within the request, e.g. "items[0].quantity"
+	Field       string
+	Description string
+}
+
+// New creates a status error with an ErrorInfo detail
+func New(code codes.Code, reason string, metadata map[string]string, format string, args ...interface{}) error {
+	return withDetails(status.New(code, fmt.Sprintf(format, args...)), &errdetails.ErrorInfo{
+		Reason:   reason,
+		Domain:   Domain,
+		Metadata: metadata,
+	})
+}
+
+// NotFound reports a missing order, product, payment or saved payment method
+func NotFound(reason string, metadata map[string]string, format string, args ...interface{}) error {
+	return New(codes.NotFound, reason, metadata, format, args...)
+}
+
+// FailedPrecondition reports a request the system's current state does not allow
+func FailedPrecondition(reason string, metadata map[string]string, format string, args ...interface{}) error {
+	return New(codes.FailedPrecondition, reason, metadata, format, args...)
+}
+
+// ResourceExhausted reports a request that needs more of a resource than is available
+func ResourceExhausted(reason string, metadata map[string]string, format string, args ...interface{}) error {
+	return New(codes.ResourceExhausted, reason, metadata, format, args...)
+}
+
+// Unavailable reports a dependency that cannot serve the request right now
+func Unavailable(reason string, metadata map[string]string, format string, args ...interface{}) error {
+	return New(codes.Unavailable, reason, metadata, format, args...)
+}
+
+// InvalidArgument reports invalid request fields with a BadRequest detail
+func InvalidArgument(violations ...FieldViolation) error {
+	descriptions := make([]string, 0, len(violations))
+	badRequest := &errdetails.BadRequest{}
+	for _, v := range violations {
+		descriptions = append(descriptions, fmt.Sprintf("%s: %s", v.Field, v.Description))
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       v.Field,
+			Description: v.Description,
+		})
+	}
+
+	st := status.New(codes.InvalidArgument, "invalid request: "+strings.Join(descriptions, "; "))
+	return withDetails(st, &errdetails.ErrorInfo{Reason: ReasonInvalidRequest, Domain: Domain}, badRequest)
+}
+
+// FieldError is implemented by validation errors that know which nested field failed
+type FieldError interface {
+	error
+	FieldPath() string
+}
+
+// InvalidField reports a single invalid request field. When err is a
+// FieldError its path is appended to field, e.g. "payment_method.card.cvv".
+func InvalidField(field string, err error) error {
+	var fieldErr FieldError
+	if errors.As(err, &fieldErr) && fieldErr.FieldPath() != "" {
+		field += "." + fieldErr.FieldPath()
+	}
+	return InvalidArgument(FieldViolation{Field: field, Description: err.Error()})
+}
+
+func withDetails(st *status.Status, details ...protoadapt.MessageV1) error {
+	detailed, err := st.WithDetails(details...)
+	if err != nil {
+		// Details are best effort; the code and message still reach the client
+		return st.Err()
+	}
+	return detailed.Err()
+}
@@ -0,0 +1,74 @@
+package apierrors
+
+import (
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/status"
+)
+
+// errorInfo returns the ErrorInfo detail of a status error, if it has one
+func errorInfo(err error) *errdetails.ErrorInfo {
+	st, ok := status.FromError(err)
+	if !ok {
+		return nil
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info
+		}
+	}
+	return nil
+}
+
+// Reason returns the ErrorInfo reason of an error, or "" when it has none
+func Reason(err error) string {
+	if info := errorInfo(err); info != nil {
+		return info.Reason
+	}
+	return ""
+}
+
+// HasReason reports whether an error carries the given ErrorInfo reason
+func HasReason(err error, reason string) bool {
+	return err != nil && Reason(err) == reason
+}
+
+// Metadata returns the ErrorInfo metadata of an error
+func Metadata(err error) map[string]string {
+	if info := errorInfo(err); info != nil {
+		return info.Metadata
+	}
+	return nil
+}
+
+// RequestID returns the ID of the failed request from its RequestInfo detail,
+// or "" when it has none
+func RequestID(err error) string {
+	st, ok := status.FromError(err)
+	if !ok {
+		return ""
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.RequestInfo); ok {
+			return info.RequestId
+		}
+	}
+	return ""
+}
+
+// FieldViolations returns the BadRequest field violations of an error
+func FieldViolations(err error) []FieldViolation {
+	st, ok := status.FromError(err)
+	if !ok {
+		return nil
+	}
+
+	var violations []FieldViolation
+	for _, detail := range st.Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			for _, v := range badRequest.FieldViolations {
+				violations = append(violations, FieldViolation{Field: v.Field, Description: v.Description})
+			}
+		}
+	}
+	return violations
+}
@@ -0,0 +1,46 @@
+package apierrors
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ToStatus converts an error returned by a handler into a status error. Errors
+// that already carry a status, including wrapped ones, keep their code and
+// details; context errors become Canceled or DeadlineExceeded; anything else
+// is an unexpected failure and becomes Internal rather than Unknown.
+func ToStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if st := status.FromContextError(err); st.Code() != codes.Unknown {
+		return st.Err()
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// UnaryServerInterceptor creates a gRPC unary server interceptor that applies
+// ToStatus to every handler error
+func UnaryServerInterceptor(logger *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		if err == nil {
+			return resp, nil
+		}
+
+		converted := ToStatus(err)
+		if _, ok := status.FromError(err); !ok && status.Code(converted) == codes.Internal {
+			logger.Warn("Handler returned an error without a status code",
+				zap.String("method", info.FullMethod),
+				zap.Error(err))
+		}
+		return resp, converted
+	}
+}
@@ -0,0 +1,89 @@
+package apierrors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestToStatus(t *testing.T) {
+	notFound := NotFound(ReasonOrderNotFound, map[string]string{"order_id": "order-1"}, "order not found: order-1")
+
+	tests := []struct {
+		name   string
+		err    error
+		code   codes.Code
+		reason string
+	}{
+		{name: "nil", err: nil, code: codes.OK},
+		{name: "status with details", err: notFound, code: codes.NotFound, reason: ReasonOrderNotFound},
+		{name: "wrapped status", err: fmt.Errorf("loading order: %w", notFound), code: codes.NotFound, reason: ReasonOrderNotFound},
+		{name: "plain status", err: status.Error(codes.PermissionDenied, "denied"), code: codes.PermissionDenied},
+		{name: "deadline", err: context.DeadlineExceeded, code: codes.DeadlineExceeded},
+		{name: "wrapped cancellation", err: fmt.Errorf("reserving stock: %w", context.Canceled), code: codes.Canceled},
+		{name: "plain error", err: errors.New("boom"), code: codes.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ToStatus(tt.err)
+			if tt.err == nil {
+				if got != nil {
+					t.Errorf("ToStatus(nil) = %v, want nil", got)
+				}
+				return
+			}
+			st, ok := status.FromError(got)
+			if !ok {
+				t.Fatalf("ToStatus() = %v, want a status error", got)
+			}
+			if st.Code() != tt.code {
+				t.Errorf("ToStatus() code = %v, want %v", st.Code(), tt.code)
+			}
+			if Reason(got) != tt.reason {
+				t.Errorf("ToStatus() reason = %q, want %q", Reason(got), tt.reason)
+			}
+		})
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	interceptor := UnaryServerInterceptor(zap.New(core))
+	info := &grpc.UnaryServerInfo{FullMethod: "/order.OrderService/GetOrder"}
+	call := func(err error) (interface{}, error) {
+		return interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return "response", err
+		})
+	}
+
+	if resp, err := call(nil); err != nil || resp != "response" {
+		t.Errorf("successful call = %v, %v; want the handler's response", resp, err)
+	}
+
+	notFound := NotFound(ReasonOrderNotFound, nil, "order not found")
+	if _, err := call(notFound); status.Code(err) != codes.NotFound || !HasReason(err, ReasonOrderNotFound) {
+		t.Errorf("status error became %v, want it unchanged", err)
+	}
+	if _, err := call(context.DeadlineExceeded); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("context error became %v, want DeadlineExceeded", err)
+	}
+	if logs.Len() != 0 {
+		t.Errorf("logged %d warnings for errors with a status", logs.Len())
+	}
+
+	if _, err := call(errors.New("boom")); status.Code(err) != codes.Internal {
+		t.Errorf("plain error became %v, want Internal", err)
+	}
+	entries := logs.FilterMessage("Handler returned an error without a status code").All()
+	if len(entries) != 1 || entries[0].ContextMap()["method"] != info.FullMethod {
+		t.Errorf("warnings = %v, want one for %s", logs.All(), info.FullMethod)
+	}
+}
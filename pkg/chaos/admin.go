@@ -0,0 +1,40 @@
+package chaos
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ruleSet is the body accepted and served by the admin endpoint
+type ruleSet struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Handler serves the fault rules: GET lists them, PUT replaces them with the
+// rules in the JSON body, and DELETE removes them all
+func (i *Injector) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var body ruleSet
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, "invalid rules: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := i.SetRules(body.Rules); err != nil {
+				http.Error(w, "invalid rules: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		case http.MethodDelete:
+			i.SetRules(nil)
+		default:
+			w.Header().Set("Allow", "GET, PUT, DELETE")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ruleSet{Rules: i.Rules()})
+	})
+}
@@ -0,0 +1,199 @@
+// Package chaos injects faults into gRPC calls so failure handling, such as
+// saga compensation, can be exercised without changing code.
+package chaos
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/your-org/order-processing-system/pkg/observability"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// Directions a rule can apply to
+const (
+	// DirectionServer matches the calls a service handles
+	DirectionServer = "server"
+	// DirectionClient matches the calls a service makes downstream
+	DirectionClient = "client"
+)
+
+// healthService is never injected into, so probes and dependency checks
+// always report the real state of a service
+const healthService = "/grpc.health.v1.Health/"
+
+// Rule describes one fault, in the form accepted by the admin endpoint
+type Rule struct {
+	// Method matches a full method name, e.g. "/inventory.InventoryService/ReserveStock",
+	// or just the method, e.g. "ReserveStock"; empty matches every method
+	Method string `json:"method,omitempty"`
+	// Direction limits the rule to the calls a service handles ("server") or
+	// makes downstream ("client"); empty matches both
+	Direction string `json:"direction,omitempty"`
+	// Metadata lists keys and values the call's metadata must all carry
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Percentage is the share of matching calls that get the fault; unset means every call
+	Percentage float64 `json:"percentage,omitempty"`
+	// Skip lets the first Skip matching calls through untouched
+	Skip int `json:"skip,omitempty"`
+	// Times limits how many calls get the fault; unset means no limit
+	Times int `json:"times,omitempty"`
+	// Latency delays the call, e.g. "2s"; a delay past the call's deadline fails it with DEADLINE_EXCEEDED
+	Latency string `json:"latency,omitempty"`
+	// Code fails the call with a status code name, e.g. "UNAVAILABLE"
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+	// After fails the call only once it has been handled, so its side
+	// effects happen but the caller sees an error
+	After bool `json:"after,omitempty"`
+}
+
+// fault is a validated rule and its match counters
+type fault struct {
+	rule     Rule
+	latency  time.Duration
+	code     codes.Code
+	matched  int
+	injected int
+}
+
+// Injector holds the active fault rules; with no rules it lets every call through
+type Injector struct {
+	metrics *observability.Metrics
+	logger  *zap.Logger
+	active  atomic.Bool
+	mutex   sync.Mutex
+	faults  []*fault
+	random  *rand.Rand
+}
+
+// NewInjector creates an injector with no rules
+func NewInjector(metrics *observability.Metrics, logger *zap.Logger) *Injector {
+	return &Injector{
+		metrics: metrics,
+		logger:  logger.Named("chaos"),
+		random:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// SetRules replaces every rule; if any rule is invalid none are applied
+func (i *Injector) SetRules(rules []Rule) error {
+	faults := make([]*fault, 0, len(rules))
+	for n, rule := range rules {
+		f, err := newFault(rule)
+		if err != nil {
+			return fmt.Errorf("rule %d: %w", n, err)
+		}
+		faults = append(faults, f)
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.faults = faults
+	i.active.Store(len(faults) > 0)
+
+	i.logger.Warn("Fault injection rules updated", zap.Int("rules", len(faults)))
+	return nil
+}
+
+// Rules returns the active rules
+func (i *Injector) Rules() []Rule {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	rules := make([]Rule, 0, len(i.faults))
+	for _, f := range i.faults {
+		rules = append(rules, f.rule)
+	}
+	return rules
+}
+
+func newFault(rule Rule) (*fault, error) {
+	f := &fault{rule: rule, code: codes.OK}
+
+	if rule.Latency != "" {
+		latency, err := time.ParseDuration(rule.Latency)
+		if err != nil {
+			return nil, fmt.Errorf("invalid latency %q: %w", rule.Latency, err)
+		}
+		f.latency = latency
+	}
+	if rule.Code != "" {
+		if err := f.code.UnmarshalJSON([]byte(`"` + strings.ToUpper(rule.Code) + `"`)); err != nil {
+			return nil, fmt.Errorf("invalid code %q", rule.Code)
+		}
+	}
+	if f.latency <= 0 && f.code == codes.OK {
+		return nil, fmt.Errorf("a rule needs a latency, a code or both")
+	}
+	if rule.After && f.code == codes.OK {
+		return nil, fmt.Errorf("after needs a code")
+	}
+	if rule.Direction != "" && rule.Direction != DirectionServer && rule.Direction != DirectionClient {
+		return nil, fmt.Errorf("direction must be %q or %q, got %q", DirectionServer, DirectionClient, rule.Direction)
+	}
+	if rule.Percentage < 0 || rule.Percentage > 100 {
+		return nil, fmt.Errorf("percentage must be between 0 and 100, got %v", rule.Percentage)
+	}
+	if rule.Skip < 0 || rule.Times < 0 {
+		return nil, fmt.Errorf("skip and times must not be negative")
+	}
+	return f, nil
+}
+
+// pick returns the first rule that applies to a call, or nil
+func (i *Injector) pick(direction, method string, md metadata.MD) *fault {
+	if !i.active.Load() || strings.HasPrefix(method, healthService) {
+		return nil
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	for _, f := range i.faults {
+		if !f.matches(direction, method, md) {
+			continue
+		}
+		f.matched++
+		if f.matched <= f.rule.Skip {
+			continue
+		}
+		if f.rule.Times > 0 && f.injected >= f.rule.Times {
+			continue
+		}
+		if f.rule.Percentage > 0 && i.random.Float64()*100 >= f.rule.Percentage {
+			continue
+		}
+		f.injected++
+		return f
+	}
+	return nil
+}
+
+func (f *fault) matches(direction, method string, md metadata.MD) bool {
+	if f.rule.Direction != "" && f.rule.Direction != direction {
+		return false
+	}
+	if f.rule.Method != "" && method != f.rule.Method && !strings.HasSuffix(method, "/"+f.rule.Method) {
+		return false
+	}
+	for key, value := range f.rule.Metadata {
+		found := false
+		for _, v := range md.Get(key) {
+			if v == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
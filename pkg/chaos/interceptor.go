@@ -0,0 +1,84 @@
+package chaos
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor creates a gRPC unary server interceptor that injects
+// faults into the calls a service handles, matching incoming metadata
+func UnaryServerInterceptor(injector *Injector) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		var resp interface{}
+		err := injector.inject(ctx, DirectionServer, info.FullMethod, md, func(ctx context.Context) error {
+			var err error
+			resp, err = handler(ctx, req)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+}
+
+// UnaryClientInterceptor creates a gRPC unary client interceptor that injects
+// faults into downstream calls, matching outgoing metadata. It should be the
+// innermost interceptor, so retries, breakers and timeouts see injected faults
+// as they would see real ones.
+func UnaryClientInterceptor(injector *Injector) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		return injector.inject(ctx, DirectionClient, method, md, func(ctx context.Context) error {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		})
+	}
+}
+
+// inject runs call with the first fault that applies to it, if any
+func (i *Injector) inject(ctx context.Context, direction, method string, md metadata.MD, call func(ctx context.Context) error) error {
+	f := i.pick(direction, method, md)
+	if f == nil {
+		return call(ctx)
+	}
+
+	i.logger.Warn("Injecting fault",
+		zap.String("direction", direction),
+		zap.String("method", method),
+		zap.Duration("latency", f.latency),
+		zap.String("code", f.code.String()),
+		zap.Bool("after", f.rule.After))
+	i.metrics.FaultsInjected.WithLabelValues(method, f.code.String()).Inc()
+
+	if f.latency > 0 {
+		timer := time.NewTimer(f.latency)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return status.FromContextError(ctx.Err()).Err()
+		case <-timer.C:
+		}
+	}
+
+	if f.code == codes.OK {
+		return call(ctx)
+	}
+	if f.rule.After {
+		if err := call(ctx); err != nil {
+			return err
+		}
+	}
+
+	message := f.rule.Message
+	if message == "" {
+		message = "injected fault"
+	}
+	return status.Error(f.code, message)
+}
@@ -0,0 +1,80 @@
+package chaos
+
+import (
+	"context"
+	"testing"
+
+	"github.com/your-org/order-processing-system/pkg/observability"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newTestInjector(t *testing.T, rules ...Rule) *Injector {
+	t.Helper()
+	injector := NewInjector(observability.NewMetrics("test"), zap.NewNop())
+	if err := injector.SetRules(rules); err != nil {
+		t.Fatalf("SetRules() error = %v", err)
+	}
+	return injector
+}
+
+// serve runs a call through the server interceptor
+func serve(injector *Injector, method string) error {
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return "ok", nil }
+	_, err := UnaryServerInterceptor(injector)(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+	return err
+}
+
+// invoke runs a call through the client interceptor
+func invoke(injector *Injector, method string) error {
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+	return UnaryClientInterceptor(injector)(context.Background(), method, nil, nil, nil, invoker)
+}
+
+func TestInjectorDirection(t *testing.T) {
+	const method = "/payment.PaymentService/ProcessPayment"
+	tests := []struct {
+		direction  string
+		wantServer codes.Code
+		wantClient codes.Code
+	}{
+		{direction: "", wantServer: codes.Unavailable, wantClient: codes.Unavailable},
+		{direction: DirectionServer, wantServer: codes.Unavailable, wantClient: codes.OK},
+		{direction: DirectionClient, wantServer: codes.OK, wantClient: codes.Unavailable},
+	}
+
+	for _, tt := range tests {
+		injector := newTestInjector(t, Rule{Method: "ProcessPayment", Direction: tt.direction, Code: "UNAVAILABLE"})
+		if got := status.Code(serve(injector, method)); got != tt.wantServer {
+			t.Errorf("direction %q: server call code = %v, want %v", tt.direction, got, tt.wantServer)
+		}
+		if got := status.Code(invoke(injector, method)); got != tt.wantClient {
+			t.Errorf("direction %q: client call code = %v, want %v", tt.direction, got, tt.wantClient)
+		}
+	}
+}
+
+func TestInjectorSkipsHealthService(t *testing.T) {
+	injector := newTestInjector(t, Rule{Code: "UNAVAILABLE"})
+
+	if err := serve(injector, "/grpc.health.v1.Health/Check"); err != nil {
+		t.Errorf("health check served with %v", err)
+	}
+	if err := invoke(injector, "/grpc.health.v1.Health/Check"); err != nil {
+		t.Errorf("health check made with %v", err)
+	}
+	if status.Code(serve(injector, "/order.OrderService/CreateOrder")) != codes.Unavailable {
+		t.Error("rule matching every method did not fault CreateOrder")
+	}
+}
+
+func TestSetRulesRejectsUnknownDirection(t *testing.T) {
+	injector := NewInjector(observability.NewMetrics("test"), zap.NewNop())
+	if err := injector.SetRules([]Rule{{Direction: "both", Code: "UNAVAILABLE"}}); err == nil {
+		t.Error("SetRules() accepted direction \"both\"")
+	}
+}
@@ -0,0 +1,60 @@
+package currency
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Currency describes an ISO 4217 currency supported by the system
+type Currency struct {
+	Code       string
+	MinorUnits int
+}
+
+// supported lists the currencies orders and payments may be placed in
+var supported = map[string]Currency{
+	"USD": {Code: "USD", MinorUnits: 2},
+	"EUR": {Code: "EUR", MinorUnits: 2},
+	"GBP": {Code: "GBP", MinorUnits: 2},
+	"CAD": {Code: "CAD", MinorUnits: 2},
+	"AUD": {Code: "AUD", MinorUnits: 2},
+	"CHF": {Code: "CHF", MinorUnits: 2},
+	"SEK": {Code: "SEK", MinorUnits: 2},
+	"JPY": {Code: "JPY", MinorUnits: 0},
+	"KWD": {Code: "KWD", MinorUnits: 3},
+}
+
+// Lookup returns the currency for an ISO 4217 code
+func Lookup(code string) (Currency, error) {
+	c, ok := supported[strings.ToUpper(code)]
+	if !ok {
+		return Currency{}, fmt.Errorf("unsupported currency: %s", code)
+	}
+	return c, nil
+}
+
+// Round rounds an amount half away from zero to the currency's minor units
+func (c Currency) Round(amount float64) float64 {
+	scale := math.Pow10(c.MinorUnits)
+	return math.Round(amount*scale) / scale
+}
+
+// IsExact reports whether an amount carries no more precision than the
+// currency allows, i.e. it is the closest float to a whole number of minor units
+func (c Currency) IsExact(amount float64) bool {
+	return c.Round(amount) == amount
+}
+
+// Equal reports whether two amounts come to the same number of minor units
+func (c Currency) Equal(a, b float64) bool {
+	scale := math.Pow10(c.MinorUnits)
+	return math.Round(a*scale) == math.Round(b*scale)
+}
+
+// Format formats an amount with the currency's minor units, e.g. 1500 JPY as
+// "1500" and 1.5 KWD as "1.500"
+func (c Currency) Format(amount float64) string {
+	return strconv.FormatFloat(c.Round(amount), 'f', c.MinorUnits, 64)
+}
@@ -0,0 +1,109 @@
+package currency
+
+import "testing"
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		code       string
+		wantCode   string
+		minorUnits int
+		wantErr    bool
+	}{
+		{code: "USD", wantCode: "USD", minorUnits: 2},
+		{code: "jpy", wantCode: "JPY", minorUnits: 0},
+		{code: "KWD", wantCode: "KWD", minorUnits: 3},
+		{code: "XYZ", wantErr: true},
+		{code: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		c, err := Lookup(tt.code)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Lookup(%q) = %+v, want an error", tt.code, c)
+			}
+			continue
+		}
+		if err != nil || c.Code != tt.wantCode || c.MinorUnits != tt.minorUnits {
+			t.Errorf("Lookup(%q) = %+v, %v; want %s with %d minor units", tt.code, c, err, tt.wantCode, tt.minorUnits)
+		}
+	}
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		code   string
+		amount float64
+		want   float64
+	}{
+		{code: "USD", amount: 10.004, want: 10},
+		{code: "USD", amount: 0.125, want: 0.13},
+		{code: "USD", amount: -0.125, want: -0.13},
+		{code: "JPY", amount: 1499.5, want: 1500},
+		{code: "JPY", amount: 1499.4, want: 1499},
+		{code: "KWD", amount: 0.0625, want: 0.063},
+		{code: "KWD", amount: 1.2344, want: 1.234},
+	}
+
+	for _, tt := range tests {
+		c, _ := Lookup(tt.code)
+		if got := c.Round(tt.amount); got != tt.want {
+			t.Errorf("%s Round(%v) = %v, want %v", tt.code, tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestIsExact(t *testing.T) {
+	// A sum computed at run time carries float noise past the last cent
+	tenth := 0.1
+
+	tests := []struct {
+		code   string
+		amount float64
+		want   bool
+	}{
+		{code: "USD", amount: 19.99, want: true},
+		{code: "USD", amount: 0.29, want: true},
+		{code: "USD", amount: 19.999, want: false},
+		{code: "USD", amount: tenth + 0.2, want: false},
+		{code: "USD", amount: 12345678901.23, want: true},
+		{code: "JPY", amount: 1500, want: true},
+		{code: "JPY", amount: 1500.5, want: false},
+		{code: "KWD", amount: 1.234, want: true},
+		{code: "KWD", amount: 1.2345, want: false},
+		{code: "KWD", amount: 1.2340001, want: false},
+	}
+
+	for _, tt := range tests {
+		c, _ := Lookup(tt.code)
+		if got := c.IsExact(tt.amount); got != tt.want {
+			t.Errorf("%s IsExact(%v) = %v, want %v", tt.code, tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestEqualAndFormat(t *testing.T) {
+	tests := []struct {
+		code      string
+		a, b      float64
+		wantEqual bool
+		wantA     string
+	}{
+		{code: "USD", a: 10, b: 10.004, wantEqual: true, wantA: "10.00"},
+		{code: "USD", a: 19.9, b: 20, wantEqual: false, wantA: "19.90"},
+		{code: "JPY", a: 1500, b: 1500.4, wantEqual: true, wantA: "1500"},
+		{code: "JPY", a: 1500, b: 1501, wantEqual: false, wantA: "1500"},
+		{code: "KWD", a: 1.5, b: 1.5004, wantEqual: true, wantA: "1.500"},
+		{code: "KWD", a: 1.5, b: 1.501, wantEqual: false, wantA: "1.500"},
+	}
+
+	for _, tt := range tests {
+		c, _ := Lookup(tt.code)
+		if got := c.Equal(tt.a, tt.b); got != tt.wantEqual {
+			t.Errorf("%s Equal(%v, %v) = %v, want %v", tt.code, tt.a, tt.b, got, tt.wantEqual)
+		}
+		if got := c.Format(tt.a); got != tt.wantA {
+			t.Errorf("%s Format(%v) = %q, want %q", tt.code, tt.a, got, tt.wantA)
+		}
+	}
+}
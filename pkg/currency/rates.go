@@ -0,0 +1,99 @@
+package currency
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// RateProvider returns exchange rates between currencies
+type RateProvider interface {
+	// Rate returns how many units of "to" one unit of "from" is worth
+	Rate(ctx context.Context, from, to string) (float64, error)
+}
+
+// staticProvider serves fixed rates quoted against a single base currency
+type staticProvider struct {
+	base  string
+	rates map[string]float64
+}
+
+// NewStaticProvider creates a provider from rates quoted against base. The base
+// currency itself is implicitly quoted at 1.
+func NewStaticProvider(base string, rates map[string]float64) RateProvider {
+	normalized := make(map[string]float64, len(rates)+1)
+	for code, rate := range rates {
+		normalized[strings.ToUpper(code)] = rate
+	}
+	normalized[strings.ToUpper(base)] = 1
+
+	return &staticProvider{
+		base:  strings.ToUpper(base),
+		rates: normalized,
+	}
+}
+
+// staticRatesFile is the on-disk format read by LoadStaticProvider, e.g.
+//
+//	{"base": "USD", "rates": {"EUR": 0.92, "JPY": 148.5}}
+type staticRatesFile struct {
+	Base  string             `json:"base"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+// LoadStaticProvider creates a provider from a JSON rates file
+func LoadStaticProvider(path string) (RateProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rates file: %w", err)
+	}
+
+	var file staticRatesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse rates file: %w", err)
+	}
+	if file.Base == "" {
+		return nil, fmt.Errorf("rates file %s has no base currency", path)
+	}
+	for code, rate := range file.Rates {
+		if rate <= 0 {
+			return nil, fmt.Errorf("rates file %s has invalid rate for %s: %v", path, code, rate)
+		}
+	}
+
+	return NewStaticProvider(file.Base, file.Rates), nil
+}
+
+// Rate returns the cross rate between two currencies via the base currency
+func (p *staticProvider) Rate(ctx context.Context, from, to string) (float64, error) {
+	fromRate, ok := p.rates[strings.ToUpper(from)]
+	if !ok {
+		return 0, fmt.Errorf("no exchange rate for %s", from)
+	}
+	toRate, ok := p.rates[strings.ToUpper(to)]
+	if !ok {
+		return 0, fmt.Errorf("no exchange rate for %s", to)
+	}
+
+	return toRate / fromRate, nil
+}
+
+// Convert converts an amount between currencies, rounding to the target currency's minor units
+func Convert(ctx context.Context, provider RateProvider, amount float64, from, to string) (float64, error) {
+	target, err := Lookup(to)
+	if err != nil {
+		return 0, err
+	}
+	if strings.EqualFold(from, to) {
+		return target.Round(amount), nil
+	}
+
+	rate, err := provider.Rate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+
+	return target.Round(amount * rate), nil
+}
@@ -0,0 +1,87 @@
+package currency
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	provider := NewStaticProvider("usd", map[string]float64{"EUR": 0.92, "jpy": 148.5, "KWD": 0.307})
+
+	tests := []struct {
+		amount   float64
+		from, to string
+		want     float64
+		wantErr  string
+	}{
+		{amount: 10, from: "USD", to: "EUR", want: 9.2},
+		{amount: 10, from: "USD", to: "JPY", want: 1485},
+		{amount: 10.01, from: "USD", to: "JPY", want: 1486},
+		{amount: 1000, from: "JPY", to: "USD", want: 6.73},
+		{amount: 92, from: "EUR", to: "KWD", want: 30.7},
+		{amount: 10, from: "USD", to: "KWD", want: 3.07},
+		{amount: 10.004, from: "USD", to: "usd", want: 10},
+		{amount: 10, from: "USD", to: "XYZ", wantErr: "unsupported currency: XYZ"},
+		{amount: 10, from: "GBP", to: "USD", wantErr: "no exchange rate for GBP"},
+		{amount: 10, from: "USD", to: "GBP", wantErr: "no exchange rate for GBP"},
+	}
+
+	for _, tt := range tests {
+		got, err := Convert(context.Background(), provider, tt.amount, tt.from, tt.to)
+		switch {
+		case tt.wantErr != "":
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Convert(%v %s to %s) error = %v, want %q", tt.amount, tt.from, tt.to, err, tt.wantErr)
+			}
+		case err != nil:
+			t.Errorf("Convert(%v %s to %s) error = %v", tt.amount, tt.from, tt.to, err)
+		case got != tt.want:
+			t.Errorf("Convert(%v %s to %s) = %v, want %v", tt.amount, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestLoadStaticProvider(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "valid", content: `{"base": "USD", "rates": {"EUR": 0.92, "JPY": 148.5}}`},
+		{name: "malformed", content: `{"base": "USD", "rates": {"EUR": }`, wantErr: "failed to parse rates file"},
+		{name: "wrong type", content: `{"base": "USD", "rates": {"EUR": "0.92"}}`, wantErr: "failed to parse rates file"},
+		{name: "no base", content: `{"rates": {"EUR": 0.92}}`, wantErr: "has no base currency"},
+		{name: "zero rate", content: `{"base": "USD", "rates": {"EUR": 0}}`, wantErr: "invalid rate for EUR"},
+		{name: "negative rate", content: `{"base": "USD", "rates": {"EUR": -1}}`, wantErr: "invalid rate for EUR"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rates.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			provider, err := LoadStaticProvider(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("LoadStaticProvider() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadStaticProvider() error = %v", err)
+			}
+			if rate, err := provider.Rate(context.Background(), "EUR", "JPY"); err != nil || rate != 148.5/0.92 {
+				t.Errorf("Rate(EUR, JPY) = %v, %v; want %v", rate, err, 148.5/0.92)
+			}
+		})
+	}
+
+	if _, err := LoadStaticProvider(filepath.Join(t.TempDir(), "missing.json")); err == nil || !strings.Contains(err.Error(), "failed to read rates file") {
+		t.Errorf("LoadStaticProvider(missing file) error = %v, want a read error", err)
+	}
+}
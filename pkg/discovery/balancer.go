@@ -0,0 +1,459 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/your-org/order-processing-system/pkg/observability"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	// Registers the client side of grpc.health.v1 used by healthCheckConfig
+	_ "google.golang.org/grpc/health"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/serviceconfig"
+	"google.golang.org/grpc/status"
+)
+
+// BalancerName is the load balancing policy name used in service configs
+const BalancerName = "outlier_ejecting"
+
+// Policy selects how calls are spread across ready endpoints
+type Policy string
+
+const (
+	// PolicyRoundRobin sends calls to each endpoint in turn
+	PolicyRoundRobin Policy = "round_robin"
+	// PolicyLeastRequest samples two endpoints and sends the call to the one
+	// with fewer calls in flight, which steers load away from slow replicas
+	PolicyLeastRequest Policy = "least_request"
+)
+
+// OutlierConfig controls when an endpoint is taken out of rotation
+type OutlierConfig struct {
+	// ConsecutiveFailures is the number of failed calls in a row that ejects an endpoint
+	ConsecutiveFailures int `json:"consecutiveFailures"`
+	// BaseEjectionTime is how long the first ejection lasts; each repeated
+	// ejection of the same endpoint lasts one BaseEjectionTime longer
+	BaseEjectionTime time.Duration `json:"baseEjectionTime"`
+	// MaxEjectionTime caps the length of an ejection
+	MaxEjectionTime time.Duration `json:"maxEjectionTime"`
+	// MaxEjectionPercent is the largest share of endpoints that may be ejected at once
+	MaxEjectionPercent int `json:"maxEjectionPercent"`
+}
+
+// BalancerConfig is the load balancing config of the outlier ejecting policy
+type BalancerConfig struct {
+	serviceconfig.LoadBalancingConfig `json:"-"`
+
+	Policy  Policy        `json:"policy"`
+	Outlier OutlierConfig `json:"outlierDetection"`
+}
+
+// DefaultBalancerConfig returns the balancing settings used for downstream clients
+func DefaultBalancerConfig() BalancerConfig {
+	return BalancerConfig{
+		Policy: PolicyRoundRobin,
+		Outlier: OutlierConfig{
+			ConsecutiveFailures: 5,
+			BaseEjectionTime:    30 * time.Second,
+			MaxEjectionTime:     5 * time.Minute,
+			MaxEjectionPercent:  50,
+		},
+	}
+}
+
+// ServiceConfig returns a gRPC service config selecting the outlier ejecting
+// policy. When healthService is set, subchannels also run grpc.health.v1
+// checks against it and only endpoints reporting SERVING receive calls.
+func ServiceConfig(config BalancerConfig, healthService string) (string, error) {
+	if err := config.validate(); err != nil {
+		return "", err
+	}
+
+	serviceConfig := map[string]interface{}{
+		"loadBalancingConfig": []map[string]interface{}{{BalancerName: config}},
+	}
+	if healthService != "" {
+		serviceConfig["healthCheckConfig"] = map[string]string{"serviceName": healthService}
+	}
+
+	data, err := json.Marshal(serviceConfig)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode service config: %w", err)
+	}
+	return string(data), nil
+}
+
+func (c BalancerConfig) validate() error {
+	switch c.Policy {
+	case PolicyRoundRobin, PolicyLeastRequest:
+	default:
+		return fmt.Errorf("unknown balancing policy %q", c.Policy)
+	}
+	if c.Outlier.ConsecutiveFailures < 1 {
+		return fmt.Errorf("consecutiveFailures must be at least 1, got %d", c.Outlier.ConsecutiveFailures)
+	}
+	if c.Outlier.MaxEjectionPercent < 0 || c.Outlier.MaxEjectionPercent > 100 {
+		return fmt.Errorf("maxEjectionPercent must be between 0 and 100, got %d", c.Outlier.MaxEjectionPercent)
+	}
+	return nil
+}
+
+// outlierFailureCodes are the status codes that count against an endpoint;
+// other errors are the caller's fault and say nothing about the replica
+var outlierFailureCodes = map[codes.Code]bool{
+	codes.Unavailable:      true,
+	codes.DeadlineExceeded: true,
+	codes.Internal:         true,
+}
+
+// RegisterBalancer registers the outlier ejecting policy with gRPC; it must
+// be called before dialing any connection whose service config uses it.
+// gRPC keeps one policy per name, so the last registration's metrics are used.
+func RegisterBalancer(metrics *observability.Metrics, logger *zap.Logger) {
+	balancer.Register(&balancerBuilder{metrics: metrics, logger: logger.Named("discovery.balancer")})
+}
+
+// balancerBuilder builds one balancer, with its own outlier state, per client connection
+type balancerBuilder struct {
+	metrics *observability.Metrics
+	logger  *zap.Logger
+}
+
+// Name returns the policy name
+func (b *balancerBuilder) Name() string {
+	return BalancerName
+}
+
+// ParseConfig parses the policy's load balancing config, filling in defaults
+func (b *balancerBuilder) ParseConfig(data json.RawMessage) (serviceconfig.LoadBalancingConfig, error) {
+	config := DefaultBalancerConfig()
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s config: %w", BalancerName, err)
+	}
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// Build creates a balancer for one client connection
+func (b *balancerBuilder) Build(cc balancer.ClientConn, opts balancer.BuildOptions) balancer.Balancer {
+	target := opts.Target.URL.String()
+	config := DefaultBalancerConfig()
+	picker := &pickerBuilder{
+		outliers: &outlierTracker{
+			target:    target,
+			metrics:   b.metrics,
+			logger:    b.logger,
+			config:    config.Outlier,
+			endpoints: make(map[string]*endpointStats),
+			now:       time.Now,
+		},
+		config: config,
+	}
+
+	states := &stateRecorder{ClientConn: cc, target: target, metrics: b.metrics}
+	return &outlierBalancer{
+		Balancer: base.NewBalancerBuilder(BalancerName, picker, base.Config{HealthCheck: true}).Build(states, opts),
+		target:   target,
+		metrics:  b.metrics,
+		picker:   picker,
+	}
+}
+
+// outlierBalancer is the base balancer, which manages subchannels, plus
+// config and endpoint bookkeeping for the picker
+type outlierBalancer struct {
+	balancer.Balancer
+	target  string
+	metrics *observability.Metrics
+	picker  *pickerBuilder
+}
+
+// UpdateClientConnState applies a new config and address list
+func (b *outlierBalancer) UpdateClientConnState(state balancer.ClientConnState) error {
+	if config, ok := state.BalancerConfig.(*BalancerConfig); ok {
+		b.picker.setConfig(*config)
+	}
+
+	addresses := make(map[string]bool, len(state.ResolverState.Addresses))
+	for _, addr := range state.ResolverState.Addresses {
+		addresses[addr.Addr] = true
+	}
+	b.picker.outliers.retain(addresses)
+	b.metrics.DownstreamEndpoints.WithLabelValues(b.target).Set(float64(len(addresses)))
+
+	// The base balancer rebuilds the picker, so the new config takes effect immediately
+	return b.Balancer.UpdateClientConnState(state)
+}
+
+// Close shuts down every subchannel
+func (b *outlierBalancer) Close() {
+	b.Balancer.Close()
+	b.metrics.DownstreamEndpoints.DeleteLabelValues(b.target)
+}
+
+// stateRecorder counts subchannels per connectivity state
+type stateRecorder struct {
+	balancer.ClientConn
+	target  string
+	metrics *observability.Metrics
+}
+
+// NewSubConn creates a subchannel whose state changes are recorded before
+// being passed on to the base balancer
+func (r *stateRecorder) NewSubConn(addrs []resolver.Address, opts balancer.NewSubConnOptions) (balancer.SubConn, error) {
+	var mutex sync.Mutex
+	current := connectivity.Idle
+	r.metrics.DownstreamConnections.WithLabelValues(r.target, current.String()).Inc()
+
+	listener := opts.StateListener
+	opts.StateListener = func(state balancer.SubConnState) {
+		mutex.Lock()
+		r.metrics.DownstreamConnections.WithLabelValues(r.target, current.String()).Dec()
+		current = state.ConnectivityState
+		if current != connectivity.Shutdown {
+			r.metrics.DownstreamConnections.WithLabelValues(r.target, current.String()).Inc()
+		}
+		mutex.Unlock()
+
+		if listener != nil {
+			listener(state)
+		}
+	}
+
+	sc, err := r.ClientConn.NewSubConn(addrs, opts)
+	if err != nil {
+		r.metrics.DownstreamConnections.WithLabelValues(r.target, current.String()).Dec()
+	}
+	return sc, err
+}
+
+// pickerBuilder builds pickers over the ready subchannels
+type pickerBuilder struct {
+	outliers *outlierTracker
+	mutex    sync.Mutex
+	config   BalancerConfig
+}
+
+func (b *pickerBuilder) setConfig(config BalancerConfig) {
+	b.mutex.Lock()
+	b.config = config
+	b.mutex.Unlock()
+	b.outliers.setConfig(config.Outlier)
+}
+
+// Build creates a picker for the current set of ready subchannels
+func (b *pickerBuilder) Build(info base.PickerBuildInfo) balancer.Picker {
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+	}
+
+	b.mutex.Lock()
+	policy := b.config.Policy
+	b.mutex.Unlock()
+
+	endpoints := make([]*endpoint, 0, len(info.ReadySCs))
+	for sc, scInfo := range info.ReadySCs {
+		endpoints = append(endpoints, &endpoint{
+			subConn: sc,
+			address: scInfo.Address.Addr,
+			stats:   b.outliers.stats(scInfo.Address.Addr),
+		})
+	}
+
+	return &picker{
+		policy:    policy,
+		endpoints: endpoints,
+		outliers:  b.outliers,
+		// Start at a random endpoint so clients do not all hit the same replica first
+		next:   uint32(rand.Intn(len(endpoints))),
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// endpoint is a ready subchannel and the outlier state of its address
+type endpoint struct {
+	subConn balancer.SubConn
+	address string
+	stats   *endpointStats
+}
+
+// picker chooses an endpoint for each call, skipping ejected ones
+type picker struct {
+	policy      Policy
+	endpoints   []*endpoint
+	outliers    *outlierTracker
+	next        uint32
+	randomMutex sync.Mutex
+	random      *rand.Rand
+}
+
+// Pick chooses the endpoint for one call
+func (p *picker) Pick(balancer.PickInfo) (balancer.PickResult, error) {
+	candidates := p.available(p.outliers.now())
+
+	var chosen *endpoint
+	switch p.policy {
+	case PolicyLeastRequest:
+		first, second := candidates[p.intn(len(candidates))], candidates[p.intn(len(candidates))]
+		chosen = first
+		if atomic.LoadInt64(&second.stats.inFlight) < atomic.LoadInt64(&first.stats.inFlight) {
+			chosen = second
+		}
+	default:
+		chosen = candidates[atomic.AddUint32(&p.next, 1)%uint32(len(candidates))]
+	}
+
+	atomic.AddInt64(&chosen.stats.inFlight, 1)
+	return balancer.PickResult{
+		SubConn: chosen.subConn,
+		Done: func(info balancer.DoneInfo) {
+			atomic.AddInt64(&chosen.stats.inFlight, -1)
+			p.outliers.record(chosen.address, chosen.stats, info.Err)
+		},
+	}, nil
+}
+
+// available returns the endpoints that are not ejected, or every endpoint if
+// all of them are, since a possibly failing replica beats failing every call
+func (p *picker) available(now time.Time) []*endpoint {
+	available := make([]*endpoint, 0, len(p.endpoints))
+	for _, e := range p.endpoints {
+		if !e.stats.ejected(now) {
+			available = append(available, e)
+		}
+	}
+	if len(available) == 0 {
+		return p.endpoints
+	}
+	return available
+}
+
+func (p *picker) intn(n int) int {
+	p.randomMutex.Lock()
+	defer p.randomMutex.Unlock()
+	return p.random.Intn(n)
+}
+
+// endpointStats is the call history of one address
+type endpointStats struct {
+	inFlight            int64
+	mutex               sync.Mutex
+	consecutiveFailures int
+	ejections           int
+	ejectedUntil        time.Time
+	// forgetAt is when an endpoint that has stayed healthy since its last
+	// ejection starts over with the base ejection time
+	forgetAt time.Time
+}
+
+func (s *endpointStats) ejected(now time.Time) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return now.Before(s.ejectedUntil)
+}
+
+// outlierTracker ejects addresses that fail too many calls in a row. Its
+// state is kept by address, so it survives subchannels reconnecting and
+// pickers being rebuilt.
+type outlierTracker struct {
+	target    string
+	metrics   *observability.Metrics
+	logger    *zap.Logger
+	mutex     sync.Mutex
+	config    OutlierConfig
+	endpoints map[string]*endpointStats
+	now       func() time.Time
+}
+
+func (t *outlierTracker) setConfig(config OutlierConfig) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.config = config
+}
+
+// stats returns the state of an address, creating it on first use
+func (t *outlierTracker) stats(address string) *endpointStats {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	stats, exists := t.endpoints[address]
+	if !exists {
+		stats = &endpointStats{}
+		t.endpoints[address] = stats
+	}
+	return stats
+}
+
+// retain forgets addresses that are no longer resolved
+func (t *outlierTracker) retain(addresses map[string]bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for address := range t.endpoints {
+		if !addresses[address] {
+			delete(t.endpoints, address)
+		}
+	}
+}
+
+// record updates an address's history with the outcome of a call and ejects it if needed
+func (t *outlierTracker) record(address string, stats *endpointStats, err error) {
+	if err == nil || !outlierFailureCodes[status.Code(err)] {
+		stats.mutex.Lock()
+		stats.consecutiveFailures = 0
+		if stats.ejections > 0 && t.now().After(stats.forgetAt) {
+			stats.ejections = 0
+		}
+		stats.mutex.Unlock()
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+
+	now := t.now()
+	stats.consecutiveFailures++
+	if stats.consecutiveFailures < t.config.ConsecutiveFailures || now.Before(stats.ejectedUntil) {
+		return
+	}
+
+	// Never eject so many endpoints that the rest are overwhelmed
+	ejected := 1
+	for _, other := range t.endpoints {
+		if other != stats && other.ejected(now) {
+			ejected++
+		}
+	}
+	if ejected*100 > t.config.MaxEjectionPercent*len(t.endpoints) {
+		return
+	}
+
+	stats.ejections++
+	duration := time.Duration(stats.ejections) * t.config.BaseEjectionTime
+	if duration > t.config.MaxEjectionTime {
+		duration = t.config.MaxEjectionTime
+	}
+	stats.ejectedUntil = now.Add(duration)
+	stats.forgetAt = stats.ejectedUntil.Add(t.config.MaxEjectionTime)
+	stats.consecutiveFailures = 0
+
+	t.logger.Warn("Ejected failing endpoint",
+		zap.String("target", t.target),
+		zap.String("address", address),
+		zap.Int("ejections", stats.ejections),
+		zap.Duration("duration", duration),
+		zap.Error(err))
+	t.metrics.OutlierEjections.WithLabelValues(t.target).Inc()
+}
@@ -0,0 +1,150 @@
+package discovery
+
+import (
+	"testing"
+	"time"
+
+	"github.com/your-org/order-processing-system/pkg/observability"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var errUnavailable = status.Error(codes.Unavailable, "connection refused")
+
+// newTestTracker returns a tracker over the given addresses whose clock is *now
+func newTestTracker(now *time.Time, addresses ...string) *outlierTracker {
+	tracker := &outlierTracker{
+		target:  "test",
+		metrics: observability.NewMetrics("test-service"),
+		logger:  zap.NewNop(),
+		config: OutlierConfig{
+			ConsecutiveFailures: 2,
+			BaseEjectionTime:    10 * time.Second,
+			MaxEjectionTime:     30 * time.Second,
+			MaxEjectionPercent:  50,
+		},
+		endpoints: make(map[string]*endpointStats),
+		now:       func() time.Time { return *now },
+	}
+	for _, address := range addresses {
+		tracker.stats(address)
+	}
+	return tracker
+}
+
+// fail records n failed calls to an address
+func fail(tracker *outlierTracker, address string, n int) {
+	for i := 0; i < n; i++ {
+		tracker.record(address, tracker.stats(address), errUnavailable)
+	}
+}
+
+func TestOutlierEjection(t *testing.T) {
+	now := time.Unix(0, 0)
+	tracker := newTestTracker(&now, "a:1", "b:1")
+	stats := tracker.stats("a:1")
+
+	fail(tracker, "a:1", 1)
+	tracker.record("a:1", stats, nil)
+	fail(tracker, "a:1", 1)
+	if stats.ejected(now) {
+		t.Fatal("ejected after failures that a success interrupted")
+	}
+
+	for i := 0; i < 3; i++ {
+		tracker.record("a:1", stats, status.Error(codes.InvalidArgument, "bad request"))
+	}
+	if stats.ejected(now) {
+		t.Fatal("ejected after failures that are the caller's fault")
+	}
+
+	fail(tracker, "a:1", 2)
+	if !stats.ejected(now) {
+		t.Fatal("not ejected after consecutive failures")
+	}
+	if until := stats.ejectedUntil.Sub(now); until != 10*time.Second {
+		t.Errorf("ejected for %v, want 10s", until)
+	}
+}
+
+func TestOutlierEjectionPercentCap(t *testing.T) {
+	now := time.Unix(0, 0)
+	tracker := newTestTracker(&now, "a:1", "b:1", "c:1", "d:1")
+
+	for _, address := range []string{"a:1", "b:1", "c:1"} {
+		fail(tracker, address, 2)
+	}
+
+	ejected := 0
+	for _, address := range []string{"a:1", "b:1", "c:1", "d:1"} {
+		if tracker.stats(address).ejected(now) {
+			ejected++
+		}
+	}
+	if ejected != 2 {
+		t.Errorf("%d of 4 endpoints ejected, want the 50%% cap of 2", ejected)
+	}
+}
+
+func TestOutlierReadmission(t *testing.T) {
+	now := time.Unix(0, 0)
+	tracker := newTestTracker(&now, "a:1", "b:1")
+	stats := tracker.stats("a:1")
+
+	fail(tracker, "a:1", 2)
+	now = now.Add(10 * time.Second)
+	if stats.ejected(now) {
+		t.Fatal("still ejected after the ejection time")
+	}
+
+	// Repeated ejections last longer, up to the maximum
+	fail(tracker, "a:1", 2)
+	if until := stats.ejectedUntil.Sub(now); until != 20*time.Second {
+		t.Errorf("second ejection lasts %v, want 20s", until)
+	}
+	now = stats.ejectedUntil
+	fail(tracker, "a:1", 2)
+	now = stats.ejectedUntil
+	fail(tracker, "a:1", 2)
+	if until := stats.ejectedUntil.Sub(now); until != 30*time.Second {
+		t.Errorf("fourth ejection lasts %v, want the 30s maximum", until)
+	}
+
+	// An endpoint that stays healthy long enough starts over
+	now = stats.forgetAt.Add(time.Second)
+	tracker.record("a:1", stats, nil)
+	fail(tracker, "a:1", 2)
+	if until := stats.ejectedUntil.Sub(now); until != 10*time.Second {
+		t.Errorf("ejection after recovering lasts %v, want 10s", until)
+	}
+}
+
+func TestPickerAvailable(t *testing.T) {
+	now := time.Unix(0, 0)
+	tracker := newTestTracker(&now, "a:1", "b:1")
+	p := &picker{
+		endpoints: []*endpoint{
+			{address: "a:1", stats: tracker.stats("a:1")},
+			{address: "b:1", stats: tracker.stats("b:1")},
+		},
+		outliers: tracker,
+	}
+
+	fail(tracker, "a:1", 2)
+	if available := p.available(now); len(available) != 1 || available[0].address != "b:1" {
+		t.Errorf("available = %v, want only b:1", available)
+	}
+
+	// Once every endpoint is ejected, all of them are used again
+	tracker.config.MaxEjectionPercent = 100
+	fail(tracker, "b:1", 2)
+	if available := p.available(now); len(available) != 2 {
+		t.Errorf("%d endpoints available with all ejected, want 2", len(available))
+	}
+
+	now = now.Add(time.Minute)
+	if available := p.available(now); len(available) != 2 {
+		t.Errorf("%d endpoints available after the ejections ended, want 2", len(available))
+	}
+}
@@ -0,0 +1,260 @@
+// Package discovery resolves downstream services to multiple endpoints and
+// balances calls across them, ejecting endpoints that keep failing.
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/resolver"
+)
+
+const (
+	// FileScheme resolves targets such as file:///etc/order-service/inventory.json
+	FileScheme = "file"
+	// SRVScheme resolves targets such as srv:///_grpc._tcp.inventory-service.order-processing.svc.cluster.local
+	SRVScheme = "srv"
+
+	// DefaultRefreshInterval is how often endpoints are re-read when no interval is given
+	DefaultRefreshInterval = 10 * time.Second
+)
+
+// EndpointsFile is the format of a static endpoints file
+type EndpointsFile struct {
+	// Addresses are host:port pairs of the service's replicas
+	Addresses []string `json:"addresses"`
+}
+
+// lookupFunc returns the current addresses of a target
+type lookupFunc func(ctx context.Context) ([]string, error)
+
+// pollingBuilder builds resolvers that re-run a lookup on an interval
+type pollingBuilder struct {
+	scheme   string
+	interval time.Duration
+	logger   *zap.Logger
+	lookup   func(target resolver.Target) (lookupFunc, error)
+}
+
+// NewFileBuilder creates a resolver builder for static endpoints files. The
+// file is polled, not watched: it is re-read every interval, so replicas
+// added or removed by rewriting it are picked up within one interval
+// without restarting the client.
+func NewFileBuilder(logger *zap.Logger, interval time.Duration) resolver.Builder {
+	return &pollingBuilder{
+		scheme:   FileScheme,
+		interval: interval,
+		logger:   logger.Named("discovery"),
+		lookup: func(target resolver.Target) (lookupFunc, error) {
+			path := target.URL.Path
+			if path == "" {
+				path = target.URL.Opaque
+			}
+			if path == "" {
+				return nil, fmt.Errorf("file target %q has no path", target.URL.String())
+			}
+			return func(ctx context.Context) ([]string, error) {
+				return readEndpointsFile(path)
+			}, nil
+		},
+	}
+}
+
+// NewSRVBuilder creates a resolver builder that looks up DNS SRV records,
+// which carry each replica's port, e.g. for a Kubernetes headless service
+func NewSRVBuilder(logger *zap.Logger, interval time.Duration) resolver.Builder {
+	return newSRVBuilder(logger, interval, net.DefaultResolver.LookupSRV)
+}
+
+// srvLookupFunc looks up SRV records, as net.Resolver.LookupSRV does
+type srvLookupFunc func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+
+func newSRVBuilder(logger *zap.Logger, interval time.Duration, lookupRecords srvLookupFunc) resolver.Builder {
+	return &pollingBuilder{
+		scheme:   SRVScheme,
+		interval: interval,
+		logger:   logger.Named("discovery"),
+		lookup: func(target resolver.Target) (lookupFunc, error) {
+			name := target.Endpoint()
+			if name == "" {
+				return nil, fmt.Errorf("srv target %q has no record name", target.URL.String())
+			}
+			return func(ctx context.Context) ([]string, error) {
+				return lookupSRV(ctx, lookupRecords, name)
+			}, nil
+		},
+	}
+}
+
+// Scheme returns the target scheme the builder resolves
+func (b *pollingBuilder) Scheme() string {
+	return b.scheme
+}
+
+// Build starts resolving a target
+func (b *pollingBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	lookup, err := b.lookup(target)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := b.interval
+	if interval <= 0 {
+		interval = DefaultRefreshInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &pollingResolver{
+		target:   target.URL.String(),
+		cc:       cc,
+		lookup:   lookup,
+		interval: interval,
+		logger:   b.logger,
+		refresh:  make(chan struct{}, 1),
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	go r.run(ctx)
+	return r, nil
+}
+
+// pollingResolver pushes a target's addresses to gRPC whenever they change
+type pollingResolver struct {
+	target   string
+	cc       resolver.ClientConn
+	lookup   lookupFunc
+	interval time.Duration
+	logger   *zap.Logger
+	refresh  chan struct{}
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// ResolveNow asks for an immediate lookup, e.g. after a connection failure
+func (r *pollingResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// Close stops the resolver
+func (r *pollingResolver) Close() {
+	r.cancel()
+	<-r.done
+}
+
+func (r *pollingResolver) run(ctx context.Context) {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	var current []string
+	for {
+		addresses, err := r.lookup(ctx)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return
+		case err != nil:
+			// Keep the last known endpoints; gRPC only sees the error if there are none
+			r.logger.Warn("Failed to resolve endpoints", zap.String("target", r.target), zap.Error(err))
+			if current == nil {
+				r.cc.ReportError(err)
+			}
+		case !equalAddresses(addresses, current):
+			r.logger.Info("Resolved endpoints", zap.String("target", r.target), zap.Strings("addresses", addresses))
+			state := resolver.State{}
+			for _, addr := range addresses {
+				state.Addresses = append(state.Addresses, resolver.Address{Addr: addr})
+			}
+			if err := r.cc.UpdateState(state); err != nil {
+				r.logger.Warn("Endpoints rejected", zap.String("target", r.target), zap.Error(err))
+			}
+			current = addresses
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.refresh:
+		}
+	}
+}
+
+// readEndpointsFile reads and validates a static endpoints file
+func readEndpointsFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read endpoints file: %w", err)
+	}
+
+	var file EndpointsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse endpoints file %s: %w", path, err)
+	}
+	if len(file.Addresses) == 0 {
+		return nil, fmt.Errorf("endpoints file %s lists no addresses", path)
+	}
+	for _, addr := range file.Addresses {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return nil, fmt.Errorf("invalid address %q in %s: %w", addr, path, err)
+		}
+	}
+
+	return sortedAddresses(file.Addresses), nil
+}
+
+// lookupSRV resolves an SRV record name to host:port pairs
+func lookupSRV(ctx context.Context, lookupRecords srvLookupFunc, name string) ([]string, error) {
+	_, records, err := lookupRecords(ctx, "", "", name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up SRV records for %s: %w", name, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no SRV records for %s", name)
+	}
+
+	addresses := make([]string, 0, len(records))
+	for _, record := range records {
+		host := strings.TrimSuffix(record.Target, ".")
+		addresses = append(addresses, net.JoinHostPort(host, strconv.Itoa(int(record.Port))))
+	}
+	return sortedAddresses(addresses), nil
+}
+
+// sortedAddresses returns a sorted, deduplicated copy, so unchanged endpoint
+// sets compare equal whatever order they were listed in
+func sortedAddresses(addresses []string) []string {
+	sorted := append([]string(nil), addresses...)
+	sort.Strings(sorted)
+
+	unique := sorted[:0]
+	for _, addr := range sorted {
+		if len(unique) == 0 || addr != unique[len(unique)-1] {
+			unique = append(unique, addr)
+		}
+	}
+	return unique
+}
+
+func equalAddresses(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
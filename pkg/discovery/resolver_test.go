@@ -0,0 +1,172 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/resolver"
+)
+
+// fakeClientConn passes every resolved address list to states
+type fakeClientConn struct {
+	resolver.ClientConn
+	states chan []string
+}
+
+func (c *fakeClientConn) UpdateState(state resolver.State) error {
+	addresses := make([]string, 0, len(state.Addresses))
+	for _, addr := range state.Addresses {
+		addresses = append(addresses, addr.Addr)
+	}
+	c.states <- addresses
+	return nil
+}
+
+func (c *fakeClientConn) ReportError(error) {}
+
+func (c *fakeClientConn) next(t *testing.T) []string {
+	t.Helper()
+	select {
+	case addresses := <-c.states:
+		return addresses
+	case <-time.After(5 * time.Second):
+		t.Fatal("no addresses resolved")
+		return nil
+	}
+}
+
+func buildResolver(t *testing.T, builder resolver.Builder, target string) *fakeClientConn {
+	t.Helper()
+	parsed, err := url.Parse(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc := &fakeClientConn{states: make(chan []string, 10)}
+	r, err := builder.Build(resolver.Target{URL: *parsed}, cc, resolver.BuildOptions{})
+	if err != nil {
+		t.Fatalf("Build(%s) error = %v", target, err)
+	}
+	t.Cleanup(r.Close)
+	return cc
+}
+
+func writeEndpoints(t *testing.T, path, content string) {
+	t.Helper()
+	// Replace the file in one step, as deployments should, so it is never read half written
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadEndpointsFile(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+		wantErr bool
+	}{
+		{"sorted and deduplicated", `{"addresses": ["b:1", "a:1", "b:1"]}`, []string{"a:1", "b:1"}, false},
+		{"malformed", `{"addresses": `, nil, true},
+		{"no addresses", `{"addresses": []}`, nil, true},
+		{"missing port", `{"addresses": ["a"]}`, nil, true},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.name+".json")
+		writeEndpoints(t, path, tt.content)
+
+		got, err := readEndpointsFile(path)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: readEndpointsFile() = %v, %v, want %v, error %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+
+	if _, err := readEndpointsFile(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("readEndpointsFile() of a missing file succeeded")
+	}
+}
+
+func TestFileResolverReloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "endpoints.json")
+	writeEndpoints(t, path, `{"addresses": ["a:1"]}`)
+
+	cc := buildResolver(t, NewFileBuilder(zap.NewNop(), 10*time.Millisecond), "file://"+path)
+	if got := cc.next(t); !reflect.DeepEqual(got, []string{"a:1"}) {
+		t.Fatalf("resolved %v, want [a:1]", got)
+	}
+
+	writeEndpoints(t, path, `{"addresses": ["b:1", "a:1"]}`)
+	if got := cc.next(t); !reflect.DeepEqual(got, []string{"a:1", "b:1"}) {
+		t.Fatalf("after rewrite resolved %v, want [a:1 b:1]", got)
+	}
+
+	// A broken rewrite keeps the last known endpoints
+	writeEndpoints(t, path, `{"addresses": []}`)
+	select {
+	case got := <-cc.states:
+		t.Errorf("resolved %v from an invalid file", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestFileBuilderRequiresPath(t *testing.T) {
+	parsed, _ := url.Parse("file://")
+	_, err := NewFileBuilder(zap.NewNop(), time.Second).Build(resolver.Target{URL: *parsed}, &fakeClientConn{}, resolver.BuildOptions{})
+	if err == nil {
+		t.Error("Build() without a path succeeded")
+	}
+}
+
+func TestSRVResolver(t *testing.T) {
+	var names []string
+	lookup := func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+		names = append(names, name)
+		return "", []*net.SRV{
+			{Target: "inventory-1.inventory-service.", Port: 50052},
+			{Target: "inventory-0.inventory-service.", Port: 50052},
+		}, nil
+	}
+
+	cc := buildResolver(t, newSRVBuilder(zap.NewNop(), time.Hour, lookup), "srv:///_grpc._tcp.inventory-service")
+	want := []string{"inventory-0.inventory-service:50052", "inventory-1.inventory-service:50052"}
+	if got := cc.next(t); !reflect.DeepEqual(got, want) {
+		t.Errorf("resolved %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(names, []string{"_grpc._tcp.inventory-service"}) {
+		t.Errorf("looked up %v, want the record name", names)
+	}
+}
+
+func TestLookupSRVErrors(t *testing.T) {
+	none := func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+		return "", nil, nil
+	}
+	if _, err := lookupSRV(context.Background(), none, "_grpc._tcp.missing"); err == nil {
+		t.Error("lookupSRV() without records succeeded")
+	}
+
+	failing := func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+		return "", nil, errors.New("no such host")
+	}
+	if _, err := lookupSRV(context.Background(), failing, "_grpc._tcp.missing"); err == nil {
+		t.Error("lookupSRV() succeeded despite the lookup failing")
+	}
+
+	parsed, _ := url.Parse("srv:///")
+	if _, err := newSRVBuilder(zap.NewNop(), time.Second, none).Build(resolver.Target{URL: *parsed}, &fakeClientConn{}, resolver.BuildOptions{}); err == nil {
+		t.Error("Build() without a record name succeeded")
+	}
+}
@@ -0,0 +1,101 @@
+package fraud
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Config defines the screening rules and the score thresholds that drive decisions
+type Config struct {
+	// ReviewThreshold is the score at which an order is held for manual review
+	ReviewThreshold int `json:"review_threshold"`
+	// RejectThreshold is the score at which an order is rejected outright
+	RejectThreshold int `json:"reject_threshold"`
+
+	Velocity         VelocityConfig         `json:"velocity"`
+	Amounts          []AmountThreshold      `json:"amounts"`
+	LocationMismatch LocationMismatchConfig `json:"location_mismatch"`
+	Blocked          BlockedConfig          `json:"blocked"`
+}
+
+// VelocityConfig limits how many orders a customer may place within a window
+type VelocityConfig struct {
+	MaxOrders int    `json:"max_orders"`
+	Window    string `json:"window"` // Go duration, e.g. "1h"
+	Score     int    `json:"score"`
+}
+
+// AmountThreshold scores orders above a settlement-currency amount
+type AmountThreshold struct {
+	Above float64 `json:"above"`
+	Score int     `json:"score"`
+}
+
+// LocationMismatchConfig scores orders whose IP, billing and shipping countries disagree
+type LocationMismatchConfig struct {
+	Score int `json:"score"`
+}
+
+// BlockedConfig lists identifiers that are never allowed to order
+type BlockedConfig struct {
+	Customers   []string `json:"customers"`
+	Emails      []string `json:"emails"`
+	IPAddresses []string `json:"ip_addresses"`
+	Countries   []string `json:"countries"`
+	CardBINs    []string `json:"card_bins"`
+	Score       int      `json:"score"`
+}
+
+// DefaultConfig returns conservative rules suitable for development
+func DefaultConfig() Config {
+	return Config{
+		ReviewThreshold: 50,
+		RejectThreshold: 80,
+		Velocity: VelocityConfig{
+			MaxOrders: 5,
+			Window:    "1h",
+			Score:     40,
+		},
+		Amounts: []AmountThreshold{
+			{Above: 2000, Score: 20},
+			{Above: 10000, Score: 50},
+		},
+		LocationMismatch: LocationMismatchConfig{Score: 30},
+		Blocked:          BlockedConfig{Score: 100},
+	}
+}
+
+// LoadConfig reads screening rules from a JSON file, starting from DefaultConfig
+func LoadConfig(path string) (Config, error) {
+	config := DefaultConfig()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read fraud rules file: %w", err)
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return Config{}, fmt.Errorf("failed to parse fraud rules file: %w", err)
+	}
+	if err := config.validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid fraud rules file %s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+func (c Config) validate() error {
+	if c.ReviewThreshold <= 0 || c.RejectThreshold <= 0 {
+		return fmt.Errorf("thresholds must be positive")
+	}
+	if c.ReviewThreshold > c.RejectThreshold {
+		return fmt.Errorf("review threshold %d exceeds reject threshold %d", c.ReviewThreshold, c.RejectThreshold)
+	}
+	if c.Velocity.MaxOrders > 0 {
+		if _, err := time.ParseDuration(c.Velocity.Window); err != nil {
+			return fmt.Errorf("invalid velocity window: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package fraud
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rule scores one risk signal of an order
+type Rule interface {
+	Name() string
+	// Evaluate returns a positive score and a reason when the rule matches
+	Evaluate(input *Input, now time.Time) (int, string)
+}
+
+// velocityRule matches customers placing too many orders within a window
+type velocityRule struct {
+	maxOrders int
+	window    time.Duration
+	score     int
+	history   map[string][]time.Time
+	lastPrune time.Time
+	mutex     sync.Mutex
+}
+
+func newVelocityRule(config VelocityConfig) Rule {
+	window, _ := time.ParseDuration(config.Window)
+	return &velocityRule{
+		maxOrders: config.MaxOrders,
+		window:    window,
+		score:     config.Score,
+		history:   make(map[string][]time.Time),
+	}
+}
+
+func (r *velocityRule) Name() string { return "velocity" }
+
+// Evaluate records the order and counts the customer's orders in the window
+func (r *velocityRule) Evaluate(input *Input, now time.Time) (int, string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	cutoff := now.Add(-r.window)
+	recent := r.history[input.CustomerID][:0]
+	for _, placed := range r.history[input.CustomerID] {
+		if placed.After(cutoff) {
+			recent = append(recent, placed)
+		}
+	}
+	recent = append(recent, now)
+	r.history[input.CustomerID] = recent
+	r.prune(now, cutoff)
+
+	if len(recent) > r.maxOrders {
+		return r.score, fmt.Sprintf("%d orders within %s exceeds limit of %d", len(recent), r.window, r.maxOrders)
+	}
+	return 0, ""
+}
+
+// prune forgets customers without orders in the window, at most once per
+// window, so the history does not grow with every customer ever seen
+func (r *velocityRule) prune(now, cutoff time.Time) {
+	if now.Sub(r.lastPrune) < r.window {
+		return
+	}
+	r.lastPrune = now
+
+	for customerID, placed := range r.history {
+		if !placed[len(placed)-1].After(cutoff) {
+			delete(r.history, customerID)
+		}
+	}
+}
+
+// amountRule matches orders above the highest configured threshold they exceed
+type amountRule struct {
+	thresholds []AmountThreshold
+}
+
+func (r *amountRule) Name() string { return "amount" }
+
+func (r *amountRule) Evaluate(input *Input, now time.Time) (int, string) {
+	var matched *AmountThreshold
+	for i := range r.thresholds {
+		threshold := &r.thresholds[i]
+		if input.Amount > threshold.Above && (matched == nil || threshold.Above > matched.Above) {
+			matched = threshold
+		}
+	}
+	if matched == nil {
+		return 0, ""
+	}
+	return matched.Score, fmt.Sprintf("amount %.2f exceeds %.2f", input.Amount, matched.Above)
+}
+
+// locationMismatchRule matches orders whose known countries disagree
+type locationMismatchRule struct {
+	score int
+}
+
+func (r *locationMismatchRule) Name() string { return "location_mismatch" }
+
+func (r *locationMismatchRule) Evaluate(input *Input, now time.Time) (int, string) {
+	countries := map[string]string{
+		"ip":       input.IPCountry,
+		"billing":  input.BillingCountry,
+		"shipping": input.ShippingCountry,
+	}
+
+	var first, firstSource string
+	for _, source := range []string{"ip", "billing", "shipping"} {
+		country := strings.ToUpper(countries[source])
+		if country == "" {
+			continue
+		}
+		if first == "" {
+			first, firstSource = country, source
+			continue
+		}
+		if country != first {
+			return r.score, fmt.Sprintf("%s country %s does not match %s country %s", source, country, firstSource, first)
+		}
+	}
+	return 0, ""
+}
+
+// blockedListRule matches orders from blocked customers, emails, IPs, countries or card BINs
+type blockedListRule struct {
+	lists map[string]map[string]bool
+	score int
+}
+
+func newBlockedListRule(config BlockedConfig) Rule {
+	toSet := func(values []string) map[string]bool {
+		set := make(map[string]bool, len(values))
+		for _, value := range values {
+			set[strings.ToLower(value)] = true
+		}
+		return set
+	}
+
+	return &blockedListRule{
+		lists: map[string]map[string]bool{
+			"customer":   toSet(config.Customers),
+			"email":      toSet(config.Emails),
+			"ip address": toSet(config.IPAddresses),
+			"country":    toSet(config.Countries),
+			"card BIN":   toSet(config.CardBINs),
+		},
+		score: config.Score,
+	}
+}
+
+func (r *blockedListRule) Name() string { return "blocked_list" }
+
+func (r *blockedListRule) Evaluate(input *Input, now time.Time) (int, string) {
+	checks := []struct {
+		list  string
+		value string
+	}{
+		{"customer", input.CustomerID},
+		{"email", input.Email},
+		{"ip address", input.IPAddress},
+		{"country", input.IPCountry},
+		{"country", input.BillingCountry},
+		{"country", input.ShippingCountry},
+		{"card BIN", input.CardBIN},
+	}
+
+	for _, check := range checks {
+		if check.value != "" && r.lists[check.list][strings.ToLower(check.value)] {
+			return r.score, fmt.Sprintf("%s %s is blocked", check.list, check.value)
+		}
+	}
+	return 0, ""
+}
+
+// buildRules creates the rules enabled by a configuration
+func buildRules(config Config) []Rule {
+	var rules []Rule
+	if len(config.Blocked.Customers)+len(config.Blocked.Emails)+len(config.Blocked.IPAddresses)+
+		len(config.Blocked.Countries)+len(config.Blocked.CardBINs) > 0 {
+		rules = append(rules, newBlockedListRule(config.Blocked))
+	}
+	if config.Velocity.MaxOrders > 0 {
+		rules = append(rules, newVelocityRule(config.Velocity))
+	}
+	if len(config.Amounts) > 0 {
+		rules = append(rules, &amountRule{thresholds: config.Amounts})
+	}
+	if config.LocationMismatch.Score > 0 {
+		rules = append(rules, &locationMismatchRule{score: config.LocationMismatch.Score})
+	}
+	return rules
+}
@@ -0,0 +1,132 @@
+package fraud
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVelocityRule(t *testing.T) {
+	rule := newVelocityRule(VelocityConfig{MaxOrders: 2, Window: "1h", Score: 40}).(*velocityRule)
+	start := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		customer string
+		at       time.Duration
+		score    int
+	}{
+		{"customer-1", 0, 0},
+		{"customer-1", 10 * time.Minute, 0},
+		{"customer-1", 20 * time.Minute, 40},
+		{"customer-2", 30 * time.Minute, 0},
+		// The first two orders of customer-1 have left the window
+		{"customer-1", 75 * time.Minute, 0},
+	}
+	for i, step := range steps {
+		score, reason := rule.Evaluate(&Input{CustomerID: step.customer}, start.Add(step.at))
+		if score != step.score {
+			t.Errorf("step %d: score = %d (%q), want %d", i, score, reason, step.score)
+		}
+	}
+}
+
+func TestVelocityRulePrunesIdleCustomers(t *testing.T) {
+	rule := newVelocityRule(VelocityConfig{MaxOrders: 5, Window: "1h", Score: 40}).(*velocityRule)
+	start := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	rule.Evaluate(&Input{CustomerID: "customer-1"}, start)
+	rule.Evaluate(&Input{CustomerID: "customer-2"}, start.Add(45*time.Minute))
+	rule.Evaluate(&Input{CustomerID: "customer-3"}, start.Add(90*time.Minute))
+
+	if _, exists := rule.history["customer-1"]; exists {
+		t.Error("customer-1 was not pruned after a window without orders")
+	}
+	for _, customer := range []string{"customer-2", "customer-3"} {
+		if _, exists := rule.history[customer]; !exists {
+			t.Errorf("%s was pruned while it has orders in the window", customer)
+		}
+	}
+}
+
+func TestBlockedListRule(t *testing.T) {
+	rule := newBlockedListRule(BlockedConfig{
+		Emails:   []string{"Fraud@Example.com"},
+		CardBINs: []string{"411111"},
+		Score:    100,
+	})
+
+	tests := []struct {
+		name  string
+		input Input
+		score int
+	}{
+		{"clean", Input{Email: "jane@example.com", CardBIN: "555555"}, 0},
+		{"blocked email ignores case", Input{Email: "fraud@example.COM"}, 100},
+		{"blocked card BIN", Input{CardBIN: "411111"}, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if score, _ := rule.Evaluate(&tt.input, time.Now()); score != tt.score {
+				t.Errorf("Evaluate() score = %d, want %d", score, tt.score)
+			}
+		})
+	}
+}
+
+func TestAmountRule(t *testing.T) {
+	rule := &amountRule{thresholds: []AmountThreshold{{Above: 10000, Score: 50}, {Above: 2000, Score: 20}}}
+
+	tests := []struct {
+		amount float64
+		score  int
+		reason string
+	}{
+		{amount: 100, score: 0},
+		{amount: 2000, score: 0},
+		{amount: 2000.01, score: 20, reason: "amount 2000.01 exceeds 2000.00"},
+		{amount: 10000, score: 20, reason: "amount 10000.00 exceeds 2000.00"},
+		// Only the highest threshold exceeded counts, whatever the configured order
+		{amount: 25000, score: 50, reason: "amount 25000.00 exceeds 10000.00"},
+	}
+	for _, tt := range tests {
+		score, reason := rule.Evaluate(&Input{Amount: tt.amount}, time.Now())
+		if score != tt.score || reason != tt.reason {
+			t.Errorf("Evaluate(%v) = %d, %q; want %d, %q", tt.amount, score, reason, tt.score, tt.reason)
+		}
+	}
+}
+
+func TestLocationMismatchRule(t *testing.T) {
+	rule := &locationMismatchRule{score: 30}
+
+	tests := []struct {
+		name   string
+		input  Input
+		score  int
+		reason string
+	}{
+		{name: "no countries", input: Input{}},
+		{name: "one country", input: Input{BillingCountry: "US"}},
+		{name: "all agree ignoring case", input: Input{IPCountry: "us", BillingCountry: "US", ShippingCountry: "Us"}},
+		{name: "unknown countries are skipped", input: Input{IPCountry: "DE", ShippingCountry: "DE"}},
+		{
+			name:   "billing differs from IP",
+			input:  Input{IPCountry: "NG", BillingCountry: "US", ShippingCountry: "US"},
+			score:  30,
+			reason: "billing country US does not match ip country NG",
+		},
+		{
+			name:   "shipping differs from billing",
+			input:  Input{BillingCountry: "US", ShippingCountry: "CA"},
+			score:  30,
+			reason: "shipping country CA does not match billing country US",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score, reason := rule.Evaluate(&tt.input, time.Now())
+			if score != tt.score || reason != tt.reason {
+				t.Errorf("Evaluate() = %d, %q; want %d, %q", score, reason, tt.score, tt.reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package fraud
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Action is the outcome of screening an order
+type Action string
+
+const (
+	ActionApprove Action = "approve"
+	ActionReview  Action = "review"
+	ActionReject  Action = "reject"
+)
+
+// Input carries the risk signals of an order
+type Input struct {
+	OrderID           string
+	CustomerID        string
+	Email             string
+	IPAddress         string
+	IPCountry         string
+	BillingCountry    string
+	ShippingCountry   string
+	Amount            float64 // In the settlement currency
+	PaymentMethodType string
+	CardBIN           string
+}
+
+// RuleMatch records a rule that contributed to an order's score
+type RuleMatch struct {
+	Rule   string
+	Score  int
+	Reason string
+}
+
+// Assessment is the scored screening result of an order
+type Assessment struct {
+	Score        int
+	Action       Action
+	MatchedRules []RuleMatch
+	AssessedAt   time.Time
+}
+
+// Screener scores orders for fraud risk
+type Screener interface {
+	Screen(ctx context.Context, input *Input) (*Assessment, error)
+}
+
+// screener implements the Screener interface by summing rule scores
+type screener struct {
+	rules  []Rule
+	config Config
+	logger *zap.Logger
+}
+
+// NewScreener creates a rule-based screener from a configuration
+func NewScreener(logger *zap.Logger, config Config) (Screener, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	return &screener{
+		rules:  buildRules(config),
+		config: config,
+		logger: logger.Named("fraud"),
+	}, nil
+}
+
+// Screen evaluates every rule and maps the total score to an action
+func (s *screener) Screen(ctx context.Context, input *Input) (*Assessment, error) {
+	now := time.Now()
+	assessment := &Assessment{Action: ActionApprove, AssessedAt: now}
+
+	for _, rule := range s.rules {
+		score, reason := rule.Evaluate(input, now)
+		if score <= 0 {
+			continue
+		}
+		assessment.Score += score
+		assessment.MatchedRules = append(assessment.MatchedRules, RuleMatch{
+			Rule:   rule.Name(),
+			Score:  score,
+			Reason: reason,
+		})
+	}
+
+	switch {
+	case assessment.Score >= s.config.RejectThreshold:
+		assessment.Action = ActionReject
+	case assessment.Score >= s.config.ReviewThreshold:
+		assessment.Action = ActionReview
+	}
+
+	if assessment.Action != ActionApprove {
+		s.logger.Warn("Order flagged by fraud screening",
+			zap.String("order_id", input.OrderID),
+			zap.String("customer_id", input.CustomerID),
+			zap.Int("score", assessment.Score),
+			zap.String("action", string(assessment.Action)),
+			zap.Int("matched_rules", len(assessment.MatchedRules)))
+	}
+
+	return assessment, nil
+}
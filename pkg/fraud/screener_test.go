@@ -0,0 +1,78 @@
+package fraud
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestScreenerThresholds(t *testing.T) {
+	config := Config{
+		ReviewThreshold:  50,
+		RejectThreshold:  80,
+		Amounts:          []AmountThreshold{{Above: 2000, Score: 20}, {Above: 10000, Score: 50}},
+		LocationMismatch: LocationMismatchConfig{Score: 30},
+	}
+	screener, err := NewScreener(zap.NewNop(), config)
+	if err != nil {
+		t.Fatalf("NewScreener() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		input  Input
+		score  int
+		action Action
+		rules  []string
+	}{
+		{name: "no signals", input: Input{Amount: 100}, score: 0, action: ActionApprove},
+		{name: "below review", input: Input{Amount: 5000}, score: 20, action: ActionApprove, rules: []string{"amount"}},
+		{name: "at review", input: Input{Amount: 15000}, score: 50, action: ActionReview, rules: []string{"amount"}},
+		{
+			name:   "between review and reject",
+			input:  Input{Amount: 5000, BillingCountry: "US", ShippingCountry: "CA"},
+			score:  50,
+			action: ActionReview,
+			rules:  []string{"amount", "location_mismatch"},
+		},
+		{
+			name:   "at reject",
+			input:  Input{Amount: 15000, BillingCountry: "US", ShippingCountry: "CA"},
+			score:  80,
+			action: ActionReject,
+			rules:  []string{"amount", "location_mismatch"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assessment, err := screener.Screen(context.Background(), &tt.input)
+			if err != nil {
+				t.Fatalf("Screen() error = %v", err)
+			}
+			if assessment.Score != tt.score || assessment.Action != tt.action {
+				t.Errorf("Screen() = score %d, %s; want score %d, %s", assessment.Score, assessment.Action, tt.score, tt.action)
+			}
+			if len(assessment.MatchedRules) != len(tt.rules) {
+				t.Fatalf("matched rules = %+v, want %v", assessment.MatchedRules, tt.rules)
+			}
+			for i, rule := range tt.rules {
+				if assessment.MatchedRules[i].Rule != rule {
+					t.Errorf("matched rule %d = %s, want %s", i, assessment.MatchedRules[i].Rule, rule)
+				}
+			}
+		})
+	}
+}
+
+func TestNewScreenerRejectsInvalidThresholds(t *testing.T) {
+	for _, config := range []Config{
+		{ReviewThreshold: 0, RejectThreshold: 80},
+		{ReviewThreshold: 90, RejectThreshold: 80},
+	} {
+		if _, err := NewScreener(zap.NewNop(), config); err == nil {
+			t.Errorf("NewScreener(review %d, reject %d) succeeded", config.ReviewThreshold, config.RejectThreshold)
+		}
+	}
+}
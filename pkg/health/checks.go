@@ -0,0 +1,53 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// GRPCCheck checks a downstream gRPC service through its grpc.health.v1
+// endpoint, so the check fails both when the service is unreachable and when
+// it reports itself as not serving. conn should have no client interceptors,
+// so probes are neither retried, failed fast by a circuit breaker nor hit by
+// injected faults.
+func GRPCCheck(conn *grpc.ClientConn, service string) Check {
+	client := healthpb.NewHealthClient(conn)
+	return func(ctx context.Context) error {
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			return fmt.Errorf("%s: %w", conn.Target(), err)
+		}
+		if resp.Status != healthpb.HealthCheckResponse_SERVING {
+			return fmt.Errorf("%s: %s is %s", conn.Target(), service, resp.Status)
+		}
+		return nil
+	}
+}
+
+// lockPollInterval is how often LockCheck retries a lock that is held
+const lockPollInterval = 10 * time.Millisecond
+
+// LockCheck fails if mutex cannot be read-locked before ctx is done, which
+// catches an in-memory store wedged by a stuck writer. It polls instead of
+// blocking, so a wedged lock does not strand a goroutine on every round.
+func LockCheck(ctx context.Context, mutex *sync.RWMutex) error {
+	ticker := time.NewTicker(lockPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if mutex.TryRLock() {
+			mutex.RUnlock()
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("store lock not acquired: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
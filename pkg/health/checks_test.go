@@ -0,0 +1,90 @@
+package health
+
+import (
+	"context"
+	"net"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestLockCheck(t *testing.T) {
+	var mutex sync.RWMutex
+	if err := LockCheck(context.Background(), &mutex); err != nil {
+		t.Errorf("LockCheck() of a free lock error = %v", err)
+	}
+
+	// Readers do not block the check
+	mutex.RLock()
+	if err := LockCheck(context.Background(), &mutex); err != nil {
+		t.Errorf("LockCheck() of a read-locked lock error = %v", err)
+	}
+	mutex.RUnlock()
+}
+
+func TestLockCheckWedged(t *testing.T) {
+	var mutex sync.RWMutex
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	goroutines := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		err := LockCheck(ctx, &mutex)
+		cancel()
+		if err == nil {
+			t.Fatal("LockCheck() of a write-locked lock succeeded")
+		}
+	}
+	if leaked := runtime.NumGoroutine() - goroutines; leaked > 0 {
+		t.Errorf("%d goroutines left behind by failed checks", leaked)
+	}
+}
+
+func TestLockCheckWaitsForWriter(t *testing.T) {
+	var mutex sync.RWMutex
+	mutex.Lock()
+	time.AfterFunc(30*time.Millisecond, mutex.Unlock)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := LockCheck(ctx, &mutex); err != nil {
+		t.Errorf("LockCheck() error = %v", err)
+	}
+}
+
+func TestGRPCCheck(t *testing.T) {
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	healthServer := grpchealth.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	check := GRPCCheck(conn, "inventory.InventoryService")
+
+	healthServer.SetServingStatus("inventory.InventoryService", healthpb.HealthCheckResponse_SERVING)
+	if err := check(context.Background()); err != nil {
+		t.Errorf("check of a serving service error = %v", err)
+	}
+
+	healthServer.SetServingStatus("inventory.InventoryService", healthpb.HealthCheckResponse_NOT_SERVING)
+	if err := check(context.Background()); err == nil {
+		t.Error("check of a service that is not serving succeeded")
+	}
+}
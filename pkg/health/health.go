@@ -0,0 +1,242 @@
+// Package health publishes service health over the standard grpc.health.v1
+// protocol and as HTTP liveness and readiness endpoints.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Check reports whether one dependency of the service is usable
+type Check func(ctx context.Context) error
+
+// Config controls how often dependency checks run
+type Config struct {
+	// Interval is the time between rounds of checks
+	Interval time.Duration
+	// Timeout bounds each individual check
+	Timeout time.Duration
+}
+
+// DefaultConfig returns the check schedule used by the services
+func DefaultConfig() Config {
+	return Config{
+		Interval: 5 * time.Second,
+		Timeout:  2 * time.Second,
+	}
+}
+
+// Checker runs dependency checks in the background and publishes the outcome
+// as the serving status of every registered gRPC service and of the server
+// as a whole (the empty service name)
+type Checker struct {
+	logger   *zap.Logger
+	config   Config
+	server   *grpchealth.Server
+	services []string
+	names    []string
+	checks   map[string]Check
+	// informational checks are reported but do not affect readiness
+	informational map[string]bool
+	mutex         sync.RWMutex
+	results       map[string]error
+	checked       bool
+	shutdown      bool
+}
+
+// NewChecker creates a checker for the named gRPC services, e.g.
+// "order.OrderService". Everything reports NOT_SERVING until the first round
+// of checks has passed.
+func NewChecker(logger *zap.Logger, config Config, services ...string) *Checker {
+	if config.Interval <= 0 {
+		config.Interval = DefaultConfig().Interval
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultConfig().Timeout
+	}
+
+	c := &Checker{
+		logger:   logger.Named("health"),
+		config:   config,
+		server:   grpchealth.NewServer(),
+		services: append([]string{""}, services...),
+		checks:   make(map[string]Check),
+		results:  make(map[string]error),
+
+		informational: make(map[string]bool),
+	}
+	c.setStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+	return c
+}
+
+// AddCheck adds a named dependency check; it must be called before Run
+func (c *Checker) AddCheck(name string, check Check) {
+	c.names = append(c.names, name)
+	c.checks[name] = check
+}
+
+// AddInformationalCheck adds a dependency check whose outcome is logged and
+// served by the readiness endpoint but never makes the service NOT_SERVING,
+// for dependencies the service can keep working without; it must be called
+// before Run
+func (c *Checker) AddInformationalCheck(name string, check Check) {
+	c.AddCheck(name, check)
+	c.informational[name] = true
+}
+
+// Register registers the grpc.health.v1 Health service on a gRPC server
+func (c *Checker) Register(s *grpc.Server) {
+	healthpb.RegisterHealthServer(s, c.server)
+}
+
+// Run checks dependencies immediately and then every interval until ctx is done
+func (c *Checker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		c.runChecks(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runChecks runs every check concurrently and publishes the combined result
+func (c *Checker) runChecks(ctx context.Context) {
+	results := make(map[string]error, len(c.names))
+	var (
+		wg    sync.WaitGroup
+		mutex sync.Mutex
+	)
+	for _, name := range c.names {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+			defer cancel()
+
+			err := check(checkCtx)
+			mutex.Lock()
+			results[name] = err
+			mutex.Unlock()
+		}(name, c.checks[name])
+	}
+	wg.Wait()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.shutdown {
+		return
+	}
+
+	ready := true
+	for _, name := range c.names {
+		err := results[name]
+		previous, seen := c.results[name]
+		switch {
+		case err != nil && (!seen || previous == nil):
+			c.logger.Warn("Dependency check failing", zap.String("check", name), zap.Error(err))
+		case err == nil && seen && previous != nil:
+			c.logger.Info("Dependency check recovered", zap.String("check", name))
+		}
+		if err != nil && !c.informational[name] {
+			ready = false
+		}
+	}
+	c.results = results
+	c.checked = true
+
+	if ready {
+		c.setStatus(healthpb.HealthCheckResponse_SERVING)
+	} else {
+		c.setStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+}
+
+// setStatus sets the serving status of every service
+func (c *Checker) setStatus(status healthpb.HealthCheckResponse_ServingStatus) {
+	for _, service := range c.services {
+		c.server.SetServingStatus(service, status)
+	}
+}
+
+// Shutdown reports NOT_SERVING from now on, so load balancers and readiness
+// probes drain the instance before the gRPC server stops
+func (c *Checker) Shutdown() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.shutdown = true
+	c.server.Shutdown()
+}
+
+// Ready reports whether the last round of checks passed and the service is not shutting down
+func (c *Checker) Ready() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if !c.checked || c.shutdown {
+		return false
+	}
+	for name, err := range c.results {
+		if err != nil && !c.informational[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// readinessReport is the body served by the readiness endpoint
+type readinessReport struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// LivenessHandler serves 200 for as long as the process can serve HTTP;
+// dependency failures never fail liveness, since restarting would not fix them
+func (c *Checker) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+	})
+}
+
+// ReadinessHandler serves 200 while the service is ready and 503 otherwise,
+// with the outcome of each dependency check as JSON
+func (c *Checker) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := readinessReport{Status: "SERVING", Checks: make(map[string]string)}
+		code := http.StatusOK
+		if !c.Ready() {
+			report.Status = "NOT_SERVING"
+			code = http.StatusServiceUnavailable
+		}
+
+		c.mutex.RLock()
+		for _, name := range c.names {
+			err, seen := c.results[name]
+			switch {
+			case !seen:
+				report.Checks[name] = "pending"
+			case err != nil:
+				report.Checks[name] = err.Error()
+			default:
+				report.Checks[name] = "ok"
+			}
+		}
+		c.mutex.RUnlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(report)
+	})
+}
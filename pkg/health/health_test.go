@@ -0,0 +1,83 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func ok(ctx context.Context) error { return nil }
+
+func failing(ctx context.Context) error { return errors.New("down") }
+
+func servingStatus(t *testing.T, c *Checker) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+	resp, err := c.server.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "order.OrderService"})
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	return resp.Status
+}
+
+func TestCheckerReadiness(t *testing.T) {
+	tests := []struct {
+		name          string
+		check         Check
+		informational Check
+		want          bool
+	}{
+		{name: "all passing", check: ok, informational: ok, want: true},
+		{name: "check failing", check: failing, informational: ok, want: false},
+		{name: "informational check failing", check: ok, informational: failing, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewChecker(zap.NewNop(), DefaultConfig(), "order.OrderService")
+			c.AddCheck("store", tt.check)
+			c.AddInformationalCheck("payment-service", tt.informational)
+			if c.Ready() {
+				t.Fatal("Ready() before the first round of checks")
+			}
+
+			c.runChecks(context.Background())
+			if got := c.Ready(); got != tt.want {
+				t.Errorf("Ready() = %v, want %v", got, tt.want)
+			}
+			want := healthpb.HealthCheckResponse_NOT_SERVING
+			if tt.want {
+				want = healthpb.HealthCheckResponse_SERVING
+			}
+			if got := servingStatus(t, c); got != want {
+				t.Errorf("serving status = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestReadinessHandlerReportsInformationalChecks(t *testing.T) {
+	c := NewChecker(zap.NewNop(), DefaultConfig())
+	c.AddCheck("store", ok)
+	c.AddInformationalCheck("payment-service", failing)
+	c.runChecks(context.Background())
+
+	recorder := httptest.NewRecorder()
+	c.ReadinessHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("status code = %d, want 200", recorder.Code)
+	}
+
+	var report readinessReport
+	if err := json.NewDecoder(recorder.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Checks["store"] != "ok" || report.Checks["payment-service"] != "down" {
+		t.Errorf("checks = %v, want store ok and payment-service down", report.Checks)
+	}
+}
@@ -2,9 +2,12 @@ package inventory
 
 import (
 	"context"
-	"fmt"
+	"strconv"
 	"sync"
+	"time"
 
+	"github.com/your-org/order-processing-system/pkg/apierrors"
+	"github.com/your-org/order-processing-system/pkg/health"
 	inventorypb "github.com/your-org/order-processing-system/pkg/pb/inventory"
 	"go.uber.org/zap"
 )
@@ -14,13 +17,35 @@ type Service interface {
 	ReserveStock(ctx context.Context, productID string, quantity int32, orderID string) (*inventorypb.ReserveStockResponse, error)
 	ReleaseStock(ctx context.Context, productID string, quantity int32, orderID string) (*inventorypb.ReleaseStockResponse, error)
 	GetProductStock(ctx context.Context, productID string) (*inventorypb.Product, error)
+	Ping(ctx context.Context) error
 }
 
+// reservationTTL is how long an order's reservation is remembered for retries.
+// Orders that complete never release their stock, so without it the
+// reservations of every order ever placed would be kept.
+const reservationTTL = 24 * time.Hour
+
 // service implements the Service interface
 type service struct {
-	products map[string]*inventorypb.Product
-	mutex    sync.RWMutex
-	logger   *zap.Logger
+	products     map[string]*inventorypb.Product
+	reservations map[reservationKey]reservation
+	lastPrune    time.Time
+	now          func() time.Time
+	mutex        sync.RWMutex
+	logger       *zap.Logger
+}
+
+// reservationKey identifies the stock held for one product of one order, which
+// makes repeated reserve and release calls for the same order safe to retry
+type reservationKey struct {
+	orderID   string
+	productID string
+}
+
+// reservation is the stock an order holds of a product
+type reservation struct {
+	quantity   int32
+	reservedAt time.Time
 }
 
 // NewService creates a new inventory service instance
@@ -48,8 +73,10 @@ func NewService(logger *zap.Logger) Service {
 	}
 
 	return &service{
-		products: products,
-		logger:   logger,
+		products:     products,
+		reservations: make(map[reservationKey]reservation),
+		now:          time.Now,
+		logger:       logger,
 	}
 }
 
@@ -60,25 +87,55 @@ func (s *service) ReserveStock(ctx context.Context, productID string, quantity i
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	now := s.now()
+	s.prune(now)
+
 	product, exists := s.products[productID]
 	if !exists {
 		s.logger.Warn("Product not found", zap.String("product_id", productID))
+		return nil, productNotFound(productID)
+	}
+
+	// A retry finds its reservation in place; a different quantity is another
+	// request for the same product and would otherwise go unreserved
+	key := reservationKey{orderID: orderID, productID: productID}
+	if held, exists := s.reservations[key]; exists && orderID != "" {
+		reserved := held.quantity
+		if reserved != quantity {
+			s.logger.Warn("Conflicting reservation for order", zap.String("product_id", productID), zap.String("order_id", orderID), zap.Int32("reserved_quantity", reserved), zap.Int32("requested", quantity))
+			return nil, apierrors.FailedPrecondition(apierrors.ReasonReservationConflict,
+				map[string]string{
+					"product_id": productID,
+					"order_id":   orderID,
+					"reserved":   strconv.Itoa(int(reserved)),
+					"requested":  strconv.Itoa(int(quantity)),
+				},
+				"order %s already holds %d of product %s, requested %d", orderID, reserved, productID, quantity)
+		}
+		s.logger.Info("Stock already reserved for order", zap.String("product_id", productID), zap.String("order_id", orderID), zap.Int32("reserved_quantity", reserved))
 		return &inventorypb.ReserveStockResponse{
-			Success: false,
-			Message: fmt.Sprintf("Product not found: %s", productID),
+			Success:          true,
+			Message:          "Stock already reserved",
+			ReservedQuantity: reserved,
 		}, nil
 	}
 
 	if product.StockQuantity < quantity {
 		s.logger.Warn("Insufficient stock", zap.String("product_id", productID), zap.Int32("available", product.StockQuantity), zap.Int32("requested", quantity))
-		return &inventorypb.ReserveStockResponse{
-			Success: false,
-			Message: fmt.Sprintf("Insufficient stock. Available: %d, Requested: %d", product.StockQuantity, quantity),
-		}, nil
+		return nil, apierrors.ResourceExhausted(apierrors.ReasonInsufficientStock,
+			map[string]string{
+				"product_id": productID,
+				"available":  strconv.Itoa(int(product.StockQuantity)),
+				"requested":  strconv.Itoa(int(quantity)),
+			},
+			"insufficient stock for product %s: available %d, requested %d", productID, product.StockQuantity, quantity)
 	}
 
 	// Reserve the stock
 	product.StockQuantity -= quantity
+	if orderID != "" {
+		s.reservations[key] = reservation{quantity: quantity, reservedAt: now}
+	}
 
 	s.logger.Info("Stock reserved successfully", zap.String("product_id", productID), zap.Int32("reserved_quantity", quantity), zap.Int32("remaining_stock", product.StockQuantity))
 
@@ -99,10 +156,29 @@ func (s *service) ReleaseStock(ctx context.Context, productID string, quantity i
 	product, exists := s.products[productID]
 	if !exists {
 		s.logger.Warn("Product not found for stock release", zap.String("product_id", productID))
-		return &inventorypb.ReleaseStockResponse{
-			Success: false,
-			Message: fmt.Sprintf("Product not found: %s", productID),
-		}, nil
+		return nil, productNotFound(productID)
+	}
+
+	// Only release what the order still holds so repeated releases are no-ops
+	if orderID != "" {
+		key := reservationKey{orderID: orderID, productID: productID}
+		held, exists := s.reservations[key]
+		if !exists {
+			s.logger.Info("No reservation to release", zap.String("product_id", productID), zap.String("order_id", orderID))
+			return &inventorypb.ReleaseStockResponse{
+				Success: true,
+				Message: "No reserved stock to release",
+			}, nil
+		}
+		if quantity > held.quantity {
+			quantity = held.quantity
+		}
+		if held.quantity-quantity > 0 {
+			held.quantity -= quantity
+			s.reservations[key] = held
+		} else {
+			delete(s.reservations, key)
+		}
 	}
 
 	// Release the stock
@@ -116,6 +192,23 @@ func (s *service) ReleaseStock(ctx context.Context, productID string, quantity i
 	}, nil
 }
 
+// prune forgets reservations older than reservationTTL, at most once per TTL.
+// Their stock stays with the order; only retries and releases for it after
+// the TTL are no longer recognized. Callers must hold the write lock.
+func (s *service) prune(now time.Time) {
+	if now.Sub(s.lastPrune) < reservationTTL {
+		return
+	}
+	s.lastPrune = now
+
+	cutoff := now.Add(-reservationTTL)
+	for key, held := range s.reservations {
+		if held.reservedAt.Before(cutoff) {
+			delete(s.reservations, key)
+		}
+	}
+}
+
 // GetProductStock retrieves product information including stock
 func (s *service) GetProductStock(ctx context.Context, productID string) (*inventorypb.Product, error) {
 	s.logger.Debug("Getting product stock", zap.String("product_id", productID))
@@ -126,9 +219,17 @@ func (s *service) GetProductStock(ctx context.Context, productID string) (*inven
 
 	if !exists {
 		s.logger.Warn("Product not found", zap.String("product_id", productID))
-		return nil, fmt.Errorf("product not found: %s", productID)
+		return nil, productNotFound(productID)
 	}
 
 	return product, nil
 }
 
+// Ping reports whether the stock store can be read
+func (s *service) Ping(ctx context.Context) error {
+	return health.LockCheck(ctx, &s.mutex)
+}
+
+func productNotFound(productID string) error {
+	return apierrors.NotFound(apierrors.ReasonProductNotFound, map[string]string{"product_id": productID}, "product not found: %s", productID)
+}
@@ -0,0 +1,113 @@
+package inventory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/your-org/order-processing-system/pkg/apierrors"
+	"go.uber.org/zap"
+)
+
+func stock(t *testing.T, service Service, productID string) int32 {
+	t.Helper()
+	product, err := service.GetProductStock(context.Background(), productID)
+	if err != nil {
+		t.Fatalf("GetProductStock() error = %v", err)
+	}
+	return product.StockQuantity
+}
+
+func TestReserveStockIsIdempotentPerOrder(t *testing.T) {
+	ctx := context.Background()
+	service := NewService(zap.NewNop())
+	before := stock(t, service, "product-1")
+
+	for i := 0; i < 2; i++ {
+		resp, err := service.ReserveStock(ctx, "product-1", 3, "order-1")
+		if err != nil {
+			t.Fatalf("ReserveStock() attempt %d error = %v", i+1, err)
+		}
+		if resp.ReservedQuantity != 3 {
+			t.Errorf("ReserveStock() attempt %d reserved %d, want 3", i+1, resp.ReservedQuantity)
+		}
+	}
+	if got := stock(t, service, "product-1"); got != before-3 {
+		t.Errorf("stock = %d after a retried reservation, want %d", got, before-3)
+	}
+
+	// Releasing twice only returns what the order held
+	for i := 0; i < 2; i++ {
+		if _, err := service.ReleaseStock(ctx, "product-1", 3, "order-1"); err != nil {
+			t.Fatalf("ReleaseStock() attempt %d error = %v", i+1, err)
+		}
+	}
+	if got := stock(t, service, "product-1"); got != before {
+		t.Errorf("stock = %d after release, want %d", got, before)
+	}
+}
+
+func TestReserveStockRejectsConflictingQuantity(t *testing.T) {
+	ctx := context.Background()
+	service := NewService(zap.NewNop())
+	before := stock(t, service, "product-1")
+
+	if _, err := service.ReserveStock(ctx, "product-1", 3, "order-1"); err != nil {
+		t.Fatalf("ReserveStock() error = %v", err)
+	}
+	_, err := service.ReserveStock(ctx, "product-1", 5, "order-1")
+	if !apierrors.HasReason(err, apierrors.ReasonReservationConflict) {
+		t.Fatalf("ReserveStock() with another quantity error = %v, want %s", err, apierrors.ReasonReservationConflict)
+	}
+	if got := stock(t, service, "product-1"); got != before-3 {
+		t.Errorf("stock = %d, want %d", got, before-3)
+	}
+}
+
+func TestReserveStockInsufficient(t *testing.T) {
+	service := NewService(zap.NewNop())
+	available := stock(t, service, "product-2")
+
+	_, err := service.ReserveStock(context.Background(), "product-2", available+1, "order-1")
+	if !apierrors.HasReason(err, apierrors.ReasonInsufficientStock) {
+		t.Fatalf("ReserveStock() error = %v, want %s", err, apierrors.ReasonInsufficientStock)
+	}
+	if got := stock(t, service, "product-2"); got != available {
+		t.Errorf("stock = %d, want %d", got, available)
+	}
+}
+
+func TestReservationsExpire(t *testing.T) {
+	ctx := context.Background()
+	s := NewService(zap.NewNop()).(*service)
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	if _, err := s.ReserveStock(ctx, "product-1", 1, "order-old"); err != nil {
+		t.Fatalf("ReserveStock() error = %v", err)
+	}
+	now = now.Add(reservationTTL / 2)
+	if _, err := s.ReserveStock(ctx, "product-1", 1, "order-recent"); err != nil {
+		t.Fatalf("ReserveStock() error = %v", err)
+	}
+
+	now = now.Add(reservationTTL/2 + time.Minute)
+	if _, err := s.ReserveStock(ctx, "product-2", 1, "order-new"); err != nil {
+		t.Fatalf("ReserveStock() error = %v", err)
+	}
+	for _, key := range []reservationKey{{"order-old", "product-1"}, {"order-recent", "product-1"}, {"order-new", "product-2"}} {
+		_, remembered := s.reservations[key]
+		if want := key.orderID != "order-old"; remembered != want {
+			t.Errorf("reservation of %s remembered = %v, want %v", key.orderID, remembered, want)
+		}
+	}
+
+	// Releases are tracked per reservation, so a release after the TTL leaves stock alone
+	before := stock(t, s, "product-1")
+	if _, err := s.ReleaseStock(ctx, "product-1", 1, "order-old"); err != nil {
+		t.Fatalf("ReleaseStock() error = %v", err)
+	}
+	if got := stock(t, s, "product-1"); got != before {
+		t.Errorf("stock = %d after releasing a forgotten reservation, want %d", got, before)
+	}
+}
@@ -0,0 +1,61 @@
+package observability
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+)
+
+// Build details, set at build time with
+// -ldflags "-X github.com/your-org/order-processing-system/pkg/observability.Version=..."
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildTime = "unknown"
+)
+
+// startTime is when the service started, reported as its uptime
+var startTime = time.Now()
+
+// BuildInfo describes the running build of a service
+type BuildInfo struct {
+	Service   string    `json:"service"`
+	Version   string    `json:"version"`
+	Commit    string    `json:"commit"`
+	BuildTime string    `json:"build_time"`
+	GoVersion string    `json:"go_version"`
+	Platform  string    `json:"platform"`
+	StartedAt time.Time `json:"started_at"`
+	Uptime    string    `json:"uptime"`
+}
+
+// BuildInfoHandler serves the build details and uptime of a service as JSON
+func BuildInfoHandler(serviceName string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(BuildInfo{
+			Service:   serviceName,
+			Version:   Version,
+			Commit:    Commit,
+			BuildTime: BuildTime,
+			GoVersion: runtime.Version(),
+			Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+			StartedAt: startTime,
+			Uptime:    time.Since(startTime).Round(time.Second).String(),
+		})
+	})
+}
+
+// PprofHandler serves the Go profiling endpoints under /debug/pprof/. They
+// expose internals and can be costly, so mount it only when asked to.
+func PprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
@@ -0,0 +1,139 @@
+package observability
+
+import (
+	"context"
+	"net/url"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/metadata"
+)
+
+// Baggage keys of the business context passed on to downstream services. They
+// double as log field names, so the log policy applies to them as it does to
+// the same fields logged directly.
+const (
+	BaggageCustomerID = "customer_id"
+	BaggageOrderID    = "order_id"
+	BaggageChannel    = "channel"
+	BaggageTenantID   = "tenant_id"
+)
+
+// Metadata keys callers can set to name the channel and tenant of a request
+const (
+	ChannelHeader  = "x-channel"
+	TenantIDHeader = "x-tenant-id"
+)
+
+// businessKeys are the baggage members copied into logs and spans; any other
+// members are passed on untouched but not recorded
+var businessKeys = []string{BaggageCustomerID, BaggageOrderID, BaggageChannel, BaggageTenantID}
+
+// BusinessContext identifies what a request is about, for correlating the
+// logs and spans of every service it reaches
+type BusinessContext struct {
+	CustomerID string
+	OrderID    string
+	Channel    string
+	TenantID   string
+}
+
+// members returns the business context by baggage key
+func (b BusinessContext) members() map[string]string {
+	return map[string]string{
+		BaggageCustomerID: b.CustomerID,
+		BaggageOrderID:    b.OrderID,
+		BaggageChannel:    b.Channel,
+		BaggageTenantID:   b.TenantID,
+	}
+}
+
+// ContextWithBusinessContext returns ctx with the set fields of business added
+// to its baggage, replacing any values already there. Fields that are not set
+// keep the value they have in ctx. Values may hold any text, such as spaces
+// or non-ASCII names; they are kept escaped in the baggage, see memberValue.
+func ContextWithBusinessContext(ctx context.Context, business BusinessContext) context.Context {
+	bag := baggage.FromContext(ctx)
+	for key, value := range business.members() {
+		if value == "" {
+			continue
+		}
+		// NewMember unescapes the value it is given, so escaping it twice
+		// leaves the member holding the escaped value
+		member, err := baggage.NewMember(key, url.PathEscape(url.PathEscape(value)))
+		if err != nil {
+			continue
+		}
+		if updated, err := bag.SetMember(member); err == nil {
+			bag = updated
+		}
+	}
+	return baggage.ContextWithBaggage(ctx, bag)
+}
+
+// BusinessContextFromContext returns the business context in ctx's baggage
+func BusinessContextFromContext(ctx context.Context) BusinessContext {
+	bag := baggage.FromContext(ctx)
+	return BusinessContext{
+		CustomerID: memberValue(bag, BaggageCustomerID),
+		OrderID:    memberValue(bag, BaggageOrderID),
+		Channel:    memberValue(bag, BaggageChannel),
+		TenantID:   memberValue(bag, BaggageTenantID),
+	}
+}
+
+// memberValue returns the unescaped value of a baggage member. Values are kept
+// escaped because the baggage header only carries printable ASCII without
+// spaces intact: it sends spaces as '+', which receivers keep, and receivers
+// drop the whole baggage if a value holds anything else once unescaped.
+func memberValue(bag baggage.Baggage, key string) string {
+	value := bag.Member(key).Value()
+	if unescaped, err := url.PathUnescape(value); err == nil {
+		return unescaped
+	}
+	return value
+}
+
+// BusinessContextFromMetadata returns the channel and tenant callers set in
+// the incoming metadata of a request
+func BusinessContextFromMetadata(ctx context.Context) BusinessContext {
+	var business BusinessContext
+	if values := metadata.ValueFromIncomingContext(ctx, ChannelHeader); len(values) > 0 {
+		business.Channel = values[0]
+	}
+	if values := metadata.ValueFromIncomingContext(ctx, TenantIDHeader); len(values) > 0 {
+		business.TenantID = values[0]
+	}
+	return business
+}
+
+// businessFields returns the business context in ctx as log fields
+func businessFields(ctx context.Context) []zap.Field {
+	bag := baggage.FromContext(ctx)
+	var fields []zap.Field
+	for _, key := range businessKeys {
+		if value := memberValue(bag, key); value != "" {
+			fields = append(fields, zap.String(key, value))
+		}
+	}
+	return fields
+}
+
+// setContextAttributes records the request ID and business context in ctx on
+// a span, with the log policy applied to the business context
+func setContextAttributes(ctx context.Context, span trace.Span, policy LogPolicy) {
+	setRequestIDAttribute(ctx, span)
+
+	bag := baggage.FromContext(ctx)
+	for _, key := range businessKeys {
+		value := memberValue(bag, key)
+		if value == "" {
+			continue
+		}
+		if value, ok := policy.apply(key, value); ok {
+			span.SetAttributes(attribute.String("app."+key, value))
+		}
+	}
+}
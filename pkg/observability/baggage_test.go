@@ -0,0 +1,86 @@
+package observability
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// contextAttributes returns the attributes setContextAttributes records for business
+func contextAttributes(business BusinessContext, policy LogPolicy) map[attribute.Key]string {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	ctx, span := tracer.Start(ContextWithBusinessContext(context.Background(), business), "span")
+	setContextAttributes(ctx, span, policy)
+	span.End()
+
+	attributes := make(map[attribute.Key]string)
+	for _, kv := range recorder.Ended()[0].Attributes() {
+		attributes[kv.Key] = kv.Value.AsString()
+	}
+	return attributes
+}
+
+func TestSetContextAttributesAppliesLogPolicy(t *testing.T) {
+	business := BusinessContext{CustomerID: "customer-1", OrderID: "order-1", Channel: "web", TenantID: "tenant-1"}
+
+	policy := testPolicy()
+	policy.Fields[BaggageTenantID] = FieldDrop
+	policy.Fields[BaggageChannel] = FieldRedact
+
+	attributes := contextAttributes(business, policy)
+	want := map[attribute.Key]string{
+		"app.customer_id": policy.hash("customer-1"),
+		"app.order_id":    "order-1",
+		"app.channel":     redacted,
+	}
+	if len(attributes) != len(want) {
+		t.Errorf("attributes = %v, want %v", attributes, want)
+	}
+	for key, value := range want {
+		if attributes[key] != value {
+			t.Errorf("%s = %q, want %q", key, attributes[key], value)
+		}
+	}
+
+	// Without a hash key, hashed fields are redacted
+	if got := contextAttributes(business, DefaultLogPolicy())["app.customer_id"]; got != redacted {
+		t.Errorf("app.customer_id without a hash key = %q, want %s", got, redacted)
+	}
+}
+
+func TestBusinessContextRoundTrip(t *testing.T) {
+	business := BusinessContext{CustomerID: "Jane Doe", OrderID: "order 1/2+3%", Channel: "web", TenantID: "Zürich"}
+	ctx := ContextWithBusinessContext(context.Background(), business)
+
+	if got := BusinessContextFromContext(ctx); got != business {
+		t.Errorf("BusinessContextFromContext() = %+v, want %+v", got, business)
+	}
+
+	// Through the baggage header, as the next service receives it
+	carrier := propagation.MapCarrier{}
+	propagation.Baggage{}.Inject(ctx, carrier)
+	received := propagation.Baggage{}.Extract(context.Background(), carrier)
+	if got := BusinessContextFromContext(received); got != business {
+		t.Errorf("received business context %+v, want %+v", got, business)
+	}
+
+	fields := make(map[string]string)
+	for _, field := range businessFields(received) {
+		fields[field.Key] = field.String
+	}
+	if fields[BaggageCustomerID] != "Jane Doe" || fields[BaggageTenantID] != "Zürich" {
+		t.Errorf("log fields = %v, want unescaped values", fields)
+	}
+
+	policy := LogPolicy{Fields: map[string]FieldPolicy{}}
+	attributes := contextAttributes(business, policy)
+	if attributes["app.customer_id"] != "Jane Doe" || attributes["app.tenant_id"] != "Zürich" {
+		t.Errorf("attributes = %v, want unescaped values", attributes)
+	}
+}
@@ -2,33 +2,22 @@ package observability
 
 import (
 	"context"
+	"io"
+	"sync"
 	"time"
 
-	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/codes"
-	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
 )
 
-// UnaryServerInterceptor creates a gRPC unary server interceptor for observability
-func UnaryServerInterceptor(serviceName string, logger *zap.Logger) grpc.UnaryServerInterceptor {
-	tracer := otel.Tracer(serviceName)
-
+// UnaryServerInterceptor creates a gRPC unary server interceptor for
+// observability. It logs and counts requests; their spans are started and
+// ended by the server's stats handler, see ServerStatsHandler.
+func UnaryServerInterceptor(metrics *Metrics, logger *zap.Logger) grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 		start := time.Now()
 
-		// Start tracing span
-		ctx, span := tracer.Start(ctx, info.FullMethod,
-			trace.WithAttributes(
-				attribute.String("rpc.system", "grpc"),
-				attribute.String("rpc.service", serviceName),
-				attribute.String("rpc.method", info.FullMethod),
-			),
-		)
-		defer span.End()
-
 		// Add trace context to logger
 		contextLogger := LoggerWithTraceContext(ctx, logger)
 		contextLogger.Info("gRPC request started", zap.String("method", info.FullMethod))
@@ -36,50 +25,35 @@ func UnaryServerInterceptor(serviceName string, logger *zap.Logger) grpc.UnarySe
 		// Call the handler
 		resp, err := handler(ctx, req)
 
-		// Record metrics and tracing
+		// Record metrics and logs
 		duration := time.Since(start)
-		statusCode := "success"
+		code := status.Code(err)
 		if err != nil {
-			statusCode = "error"
-			span.RecordError(err)
-			span.SetStatus(codes.Error, err.Error())
 			contextLogger.Error("gRPC request failed", 
 				zap.String("method", info.FullMethod),
 				zap.Error(err),
 				zap.Duration("duration", duration))
 		} else {
-			span.SetStatus(codes.Ok, "")
 			contextLogger.Info("gRPC request completed", 
 				zap.String("method", info.FullMethod),
 				zap.Duration("duration", duration))
 		}
 
 		// Record Prometheus metrics
-		RequestsTotal.WithLabelValues(serviceName, info.FullMethod, statusCode).Inc()
-		RequestDuration.WithLabelValues(serviceName, info.FullMethod).Observe(duration.Seconds())
+		metrics.RequestsTotal.WithLabelValues(info.FullMethod, code.String()).Inc()
+		ObserveWithTrace(ctx, metrics.RequestDuration.WithLabelValues(info.FullMethod), duration.Seconds())
 
 		return resp, err
 	}
 }
 
-// UnaryClientInterceptor creates a gRPC unary client interceptor for observability
-func UnaryClientInterceptor(serviceName string, logger *zap.Logger) grpc.UnaryClientInterceptor {
-	tracer := otel.Tracer(serviceName)
-
+// UnaryClientInterceptor creates a gRPC unary client interceptor for
+// observability. It logs and counts calls, retries included; the span of each
+// attempt is started by the connection's stats handler, see ClientStatsHandler.
+func UnaryClientInterceptor(metrics *Metrics, logger *zap.Logger) grpc.UnaryClientInterceptor {
 	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
 		start := time.Now()
 
-		// Start tracing span
-		ctx, span := tracer.Start(ctx, method,
-			trace.WithAttributes(
-				attribute.String("rpc.system", "grpc"),
-				attribute.String("rpc.service", serviceName),
-				attribute.String("rpc.method", method),
-				attribute.String("rpc.target", cc.Target()),
-			),
-		)
-		defer span.End()
-
 		// Add trace context to logger
 		contextLogger := LoggerWithTraceContext(ctx, logger)
 		contextLogger.Debug("gRPC client request started", zap.String("method", method))
@@ -87,83 +61,153 @@ func UnaryClientInterceptor(serviceName string, logger *zap.Logger) grpc.UnaryCl
 		// Call the invoker
 		err := invoker(ctx, method, req, reply, cc, opts...)
 
-		// Record metrics and tracing
+		// Record metrics and logs
 		duration := time.Since(start)
-		statusCode := "success"
+		code := status.Code(err)
 		if err != nil {
-			statusCode = "error"
-			span.RecordError(err)
-			span.SetStatus(codes.Error, err.Error())
 			contextLogger.Error("gRPC client request failed", 
 				zap.String("method", method),
 				zap.Error(err),
 				zap.Duration("duration", duration))
 		} else {
-			span.SetStatus(codes.Ok, "")
 			contextLogger.Debug("gRPC client request completed", 
 				zap.String("method", method),
 				zap.Duration("duration", duration))
 		}
 
 		// Record Prometheus metrics
-		RequestsTotal.WithLabelValues(serviceName+"-client", method, statusCode).Inc()
-		RequestDuration.WithLabelValues(serviceName+"-client", method).Observe(duration.Seconds())
+		metrics.ClientRequestsTotal.WithLabelValues(method, code.String()).Inc()
+		ObserveWithTrace(ctx, metrics.ClientRequestDuration.WithLabelValues(method), duration.Seconds())
 
 		return err
 	}
 }
 
-// StreamServerInterceptor creates a gRPC stream server interceptor for observability
-func StreamServerInterceptor(serviceName string, logger *zap.Logger) grpc.StreamServerInterceptor {
-	tracer := otel.Tracer(serviceName)
-
+// StreamServerInterceptor creates a gRPC stream server interceptor for
+// observability, the streaming counterpart of UnaryServerInterceptor
+func StreamServerInterceptor(metrics *Metrics, logger *zap.Logger) grpc.StreamServerInterceptor {
 	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
 		start := time.Now()
-
-		// Start tracing span
-		ctx, span := tracer.Start(ss.Context(), info.FullMethod,
-			trace.WithAttributes(
-				attribute.String("rpc.system", "grpc"),
-				attribute.String("rpc.service", serviceName),
-				attribute.String("rpc.method", info.FullMethod),
-				attribute.Bool("rpc.streaming", true),
-			),
-		)
-		defer span.End()
+		ctx := ss.Context()
 
 		// Add trace context to logger
 		contextLogger := LoggerWithTraceContext(ctx, logger)
 		contextLogger.Info("gRPC stream started", zap.String("method", info.FullMethod))
 
 		// Call the handler
-		err := handler(srv, &wrappedServerStream{ServerStream: ss, ctx: ctx})
+		err := handler(srv, ss)
 
-		// Record metrics and tracing
+		// Record metrics and logs
 		duration := time.Since(start)
-		statusCode := "success"
+		code := status.Code(err)
 		if err != nil {
-			statusCode = "error"
-			span.RecordError(err)
-			span.SetStatus(codes.Error, err.Error())
 			contextLogger.Error("gRPC stream failed", 
 				zap.String("method", info.FullMethod),
 				zap.Error(err),
 				zap.Duration("duration", duration))
 		} else {
-			span.SetStatus(codes.Ok, "")
 			contextLogger.Info("gRPC stream completed", 
 				zap.String("method", info.FullMethod),
 				zap.Duration("duration", duration))
 		}
 
 		// Record Prometheus metrics
-		RequestsTotal.WithLabelValues(serviceName, info.FullMethod, statusCode).Inc()
-		RequestDuration.WithLabelValues(serviceName, info.FullMethod).Observe(duration.Seconds())
+		metrics.RequestsTotal.WithLabelValues(info.FullMethod, code.String()).Inc()
+		ObserveWithTrace(ctx, metrics.RequestDuration.WithLabelValues(info.FullMethod), duration.Seconds())
 
 		return err
 	}
 }
 
+// StreamClientInterceptor creates a gRPC stream client interceptor for
+// observability. The call is recorded once the stream ends: when a receive
+// fails, including with io.EOF at the end of the stream, when a send fails,
+// after the single response of a call that does not stream responses, or
+// when the caller cancels the call without reading to the end. Spans are
+// left to the connection's stats handler, as for unary calls.
+func StreamClientInterceptor(metrics *Metrics, logger *zap.Logger) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+
+		// Add trace context to logger
+		contextLogger := LoggerWithTraceContext(ctx, logger)
+		contextLogger.Debug("gRPC client stream started", zap.String("method", method))
+
+		finish := func(err error) {
+			// Record metrics and logs
+			duration := time.Since(start)
+			code := status.Code(err)
+			if err != nil {
+				contextLogger.Error("gRPC client stream failed",
+					zap.String("method", method),
+					zap.Error(err),
+					zap.Duration("duration", duration))
+			} else {
+				contextLogger.Debug("gRPC client stream completed",
+					zap.String("method", method),
+					zap.Duration("duration", duration))
+			}
+
+			// Record Prometheus metrics
+			metrics.ClientRequestsTotal.WithLabelValues(method, code.String()).Inc()
+			ObserveWithTrace(ctx, metrics.ClientRequestDuration.WithLabelValues(method), duration.Seconds())
+		}
+
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			finish(err)
+			return nil, err
+		}
+		wrapped := &wrappedClientStream{ClientStream: stream, serverStreams: desc.ServerStreams, finish: finish, done: make(chan struct{})}
+		go func() {
+			select {
+			case <-ctx.Done():
+				wrapped.end(status.FromContextError(ctx.Err()).Err())
+			case <-wrapped.done:
+			}
+		}()
+		return wrapped, nil
+	}
+}
+
+// wrappedClientStream wraps grpc.ClientStream to record the call when it ends
+type wrappedClientStream struct {
+	grpc.ClientStream
+	serverStreams bool
+	once          sync.Once
+	finish        func(err error)
+	done          chan struct{}
+}
+
+func (w *wrappedClientStream) SendMsg(m interface{}) error {
+	err := w.ClientStream.SendMsg(m)
+	if err != nil && err != io.EOF {
+		// io.EOF means the server ended the stream; RecvMsg returns its status
+		w.end(err)
+	}
+	return err
+}
+
+func (w *wrappedClientStream) RecvMsg(m interface{}) error {
+	err := w.ClientStream.RecvMsg(m)
+	switch {
+	case err == io.EOF:
+		w.end(nil)
+	case err != nil:
+		w.end(err)
+	case !w.serverStreams:
+		w.end(nil)
+	}
+	return err
+}
+
+func (w *wrappedClientStream) end(err error) {
+	w.once.Do(func() {
+		w.finish(err)
+		close(w.done)
+	})
+}
+
 // wrappedServerStream wraps grpc.ServerStream to inject context
 type wrappedServerStream struct {
 	grpc.ServerStream
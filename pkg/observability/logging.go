@@ -2,23 +2,318 @@ package observability
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
 )
 
-// NewLogger creates a new structured logger with observability context
-func NewLogger(serviceName string, level zapcore.Level) (*zap.Logger, error) {
+// redacted replaces the value of a redacted field
+const redacted = "[REDACTED]"
+
+// FieldPolicy says how a log field's value is written
+type FieldPolicy string
+
+const (
+	// FieldKeep writes the value as is
+	FieldKeep FieldPolicy = "keep"
+	// FieldRedact replaces the value with "[REDACTED]"
+	FieldRedact FieldPolicy = "redact"
+	// FieldHash replaces the value with a keyed hash, so entries about the
+	// same customer or token can still be correlated
+	FieldHash FieldPolicy = "hash"
+	// FieldDrop leaves the field out
+	FieldDrop FieldPolicy = "drop"
+)
+
+// LogPolicy maps log field keys to the policy applied to their values
+type LogPolicy struct {
+	Fields map[string]FieldPolicy
+	// HashKey keys the hashes written by FieldHash, and is required when any
+	// field is hashed. Services must share it to write the same hash for the
+	// same value, which is what correlates their entries.
+	HashKey []byte
+}
+
+// DefaultLogPolicy hashes customer identifiers and vault tokens and redacts
+// amounts and contact details
+func DefaultLogPolicy() LogPolicy {
+	return LogPolicy{
+		Fields: map[string]FieldPolicy{
+			"customer_id":  FieldHash,
+			"token":        FieldHash,
+			"email":        FieldHash,
+			"ip_address":   FieldRedact,
+			"amount":       FieldRedact,
+			"total_amount": FieldRedact,
+		},
+	}
+}
+
+// ParseFields overrides field policies from a comma-separated list of
+// key=policy pairs, e.g. "customer_id=keep,reviewer=hash"
+func (p LogPolicy) ParseFields(spec string) (LogPolicy, error) {
+	fields := make(map[string]FieldPolicy, len(p.Fields))
+	for key, policy := range p.Fields {
+		fields[key] = policy
+	}
+
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, value, found := strings.Cut(pair, "=")
+		if !found {
+			return p, fmt.Errorf("invalid field policy %q, expected key=policy", pair)
+		}
+		policy := FieldPolicy(strings.ToLower(strings.TrimSpace(value)))
+		switch policy {
+		case FieldKeep, FieldRedact, FieldHash, FieldDrop:
+		default:
+			return p, fmt.Errorf("unknown policy %q for field %s", value, key)
+		}
+		fields[strings.TrimSpace(key)] = policy
+	}
+
+	p.Fields = fields
+	return p, nil
+}
+
+// apply returns the value written for a field under the policy, and false if
+// the field is left out. Without a hash key hashed fields are redacted.
+func (p LogPolicy) apply(key, value string) (string, bool) {
+	switch p.Fields[key] {
+	case FieldRedact:
+		return redacted, true
+	case FieldHash:
+		if len(p.HashKey) == 0 {
+			return redacted, true
+		}
+		return p.hash(value), true
+	case FieldDrop:
+		return "", false
+	}
+	return value, true
+}
+
+// validate reports a policy that hashes fields without a key to hash them with
+func (p LogPolicy) validate() error {
+	if len(p.HashKey) > 0 {
+		return nil
+	}
+	for key, policy := range p.Fields {
+		if policy == FieldHash {
+			return fmt.Errorf("field %s is hashed but the log policy has no hash key", key)
+		}
+	}
+	return nil
+}
+
+// hash returns a short keyed hash of value
+func (p LogPolicy) hash(value string) string {
+	mac := hmac.New(sha256.New, p.HashKey)
+	mac.Write([]byte(value))
+	return "h:" + hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// LogLevels holds the minimum level of a service's logs, overall and per
+// logger name, and can be changed while the service runs
+type LogLevels struct {
+	mutex   sync.RWMutex
+	level   zapcore.Level
+	loggers map[string]zapcore.Level
+	// minimum is the lowest level in use, checked before any entry is built
+	minimum atomic.Int32
+}
+
+// NewLogLevels creates log levels with level for every logger
+func NewLogLevels(level zapcore.Level) *LogLevels {
+	l := &LogLevels{level: level, loggers: make(map[string]zapcore.Level)}
+	l.minimum.Store(int32(level))
+	return l
+}
+
+// SetLevel sets the level of loggers without a level of their own
+func (l *LogLevels) SetLevel(level zapcore.Level) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.level = level
+	l.updateMinimum()
+}
+
+// SetLoggerLevel sets the level of a named logger and of the loggers named
+// under it, e.g. "discovery" also covers "discovery.srv"
+func (l *LogLevels) SetLoggerLevel(name string, level zapcore.Level) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.loggers[name] = level
+	l.updateMinimum()
+}
+
+// ClearLoggerLevel makes a named logger follow the overall level again
+func (l *LogLevels) ClearLoggerLevel(name string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	delete(l.loggers, name)
+	l.updateMinimum()
+}
+
+func (l *LogLevels) updateMinimum() {
+	minimum := l.level
+	for _, level := range l.loggers {
+		if level < minimum {
+			minimum = level
+		}
+	}
+	l.minimum.Store(int32(minimum))
+}
+
+// enabled reports whether an entry of a named logger is written; the most
+// specific logger name with a level of its own decides
+func (l *LogLevels) enabled(name string, level zapcore.Level) bool {
+	if level < zapcore.Level(l.minimum.Load()) {
+		return false
+	}
+
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	for prefix := name; prefix != ""; {
+		if loggerLevel, exists := l.loggers[prefix]; exists {
+			return level >= loggerLevel
+		}
+		dot := strings.LastIndexByte(prefix, '.')
+		if dot < 0 {
+			break
+		}
+		prefix = prefix[:dot]
+	}
+	return level >= l.level
+}
+
+// logLevelsState is the body accepted and served by the log level endpoint
+type logLevelsState struct {
+	Level   string            `json:"level"`
+	Loggers map[string]string `json:"loggers"`
+}
+
+// logLevelChange is the body accepted by the log level endpoint; with Logger
+// set it changes that logger, otherwise the overall level
+type logLevelChange struct {
+	Logger string `json:"logger,omitempty"`
+	Level  string `json:"level"`
+}
+
+// Handler serves the log levels: GET lists them, PUT sets the overall level
+// or, with a logger name, one logger's level, and DELETE with a logger query
+// parameter makes that logger follow the overall level again
+func (l *LogLevels) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var change logLevelChange
+			if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+				http.Error(w, "invalid level change: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			level, err := zapcore.ParseLevel(change.Level)
+			if err != nil {
+				http.Error(w, "invalid level change: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if change.Logger == "" {
+				l.SetLevel(level)
+			} else {
+				l.SetLoggerLevel(change.Logger, level)
+			}
+		case http.MethodDelete:
+			name := r.URL.Query().Get("logger")
+			if name == "" {
+				http.Error(w, "logger query parameter is required", http.StatusBadRequest)
+				return
+			}
+			l.ClearLoggerLevel(name)
+		default:
+			w.Header().Set("Allow", "GET, PUT, DELETE")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		l.mutex.RLock()
+		state := logLevelsState{Level: l.level.String(), Loggers: make(map[string]string, len(l.loggers))}
+		for name, level := range l.loggers {
+			state.Loggers[name] = level.String()
+		}
+		l.mutex.RUnlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(state)
+	})
+}
+
+// levelCore writes the entries that the log levels enable for their logger
+type levelCore struct {
+	zapcore.Core
+	levels *LogLevels
+}
+
+func (c *levelCore) Enabled(level zapcore.Level) bool {
+	return level >= zapcore.Level(c.levels.minimum.Load())
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), levels: c.levels}
+}
+
+func (c *levelCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.levels.enabled(entry.LoggerName, entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+// NewLogger creates a new structured logger with observability context. Its
+// levels can be changed at runtime through levels, and field values are
+// written according to policy, which must have a hash key if it hashes any
+// field. Pass the same policy to the stats handlers so spans carry the same
+// values as logs.
+func NewLogger(serviceName string, levels *LogLevels, policy LogPolicy) (*zap.Logger, error) {
+	if err := policy.validate(); err != nil {
+		return nil, err
+	}
+
 	config := zap.NewProductionConfig()
-	config.Level = zap.NewAtomicLevelAt(level)
+	// Levels are checked per logger name by levelCore, so the encoding core
+	// accepts every entry it is given
+	config.Level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+	// Sampling wraps the other cores instead, which check entries themselves
+	sampling := config.Sampling
+	config.Sampling = nil
 	
 	// Add service name to all log entries
 	config.InitialFields = map[string]interface{}{
 		"service": serviceName,
 	}
 
-	logger, err := config.Build()
+	logger, err := config.Build(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return wrapCore(core, levels, policy, sampling)
+	}))
 	if err != nil {
 		return nil, err
 	}
@@ -26,8 +321,152 @@ func NewLogger(serviceName string, level zapcore.Level) (*zap.Logger, error) {
 	return logger, nil
 }
 
-// LoggerWithTraceContext adds trace context to logger
+// wrapCore applies the policy to the fields written to core and the levels
+// to its entries, sampling the entries that pass. Each wrapper checks entries
+// itself, so the sampler must be outermost to see them.
+func wrapCore(core zapcore.Core, levels *LogLevels, policy LogPolicy, sampling *zap.SamplingConfig) zapcore.Core {
+	core = &redactingCore{Core: core, policy: policy}
+	core = &levelCore{Core: core, levels: levels}
+	return zapcore.NewSamplerWithOptions(core, time.Second, sampling.Initial, sampling.Thereafter)
+}
+
+// redactingCore applies a log policy to every field before it is encoded,
+// including fields added with Logger.With
+type redactingCore struct {
+	zapcore.Core
+	policy LogPolicy
+}
+
+func (c *redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactingCore{Core: c.Core.With(c.redact(fields)), policy: c.policy}
+}
+
+func (c *redactingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *redactingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(entry, c.redact(fields))
+}
+
+// redact returns fields with the policy applied, leaving fields untouched
+func (c *redactingCore) redact(fields []zapcore.Field) []zapcore.Field {
+	var result []zapcore.Field
+	for i, field := range fields {
+		policy, exists := c.policy.Fields[field.Key]
+		if !exists || policy == FieldKeep {
+			if result != nil {
+				result = append(result, field)
+			}
+			continue
+		}
+		if result == nil {
+			result = append(make([]zapcore.Field, 0, len(fields)), fields[:i]...)
+		}
+
+		switch policy {
+		case FieldRedact:
+			result = append(result, zap.String(field.Key, redacted))
+		case FieldHash:
+			value, ok := fieldValue(field)
+			if !ok {
+				result = append(result, zap.String(field.Key, redacted))
+				continue
+			}
+			result = append(result, zap.String(field.Key, c.policy.hash(value)))
+		}
+	}
+	if result == nil {
+		return fields
+	}
+	return result
+}
+
+// fieldValue returns the value of a scalar field as a string
+func fieldValue(field zapcore.Field) (string, bool) {
+	switch field.Type {
+	case zapcore.StringType:
+		return field.String, true
+	case zapcore.Int64Type, zapcore.Int32Type, zapcore.Int16Type, zapcore.Int8Type:
+		return fmt.Sprint(field.Integer), true
+	case zapcore.Uint64Type, zapcore.Uint32Type, zapcore.Uint16Type, zapcore.Uint8Type:
+		return fmt.Sprint(uint64(field.Integer)), true
+	case zapcore.Float64Type:
+		return fmt.Sprint(math.Float64frombits(uint64(field.Integer))), true
+	case zapcore.StringerType:
+		return field.Interface.(fmt.Stringer).String(), true
+	}
+	return "", false
+}
+
+// Proto logs a proto message as a JSON object, with every field marked
+// [debug_redact = true] in its proto definition, at any depth, redacted
+func Proto(key string, message proto.Message) zap.Field {
+	if message == nil {
+		return zap.Skip()
+	}
+	clone := proto.Clone(message)
+	redactMessage(clone.ProtoReflect())
+
+	data, err := protojson.Marshal(clone)
+	if err != nil {
+		return zap.String(key, redacted)
+	}
+	var object map[string]interface{}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return zap.String(key, redacted)
+	}
+	return zap.Any(key, object)
+}
+
+// redactMessage replaces or clears the sensitive fields of a message in place
+func redactMessage(message protoreflect.Message) {
+	message.Range(func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+		if options, ok := field.Options().(*descriptorpb.FieldOptions); ok && options.GetDebugRedact() {
+			if field.Kind() == protoreflect.StringKind && !field.IsList() && !field.IsMap() {
+				message.Set(field, protoreflect.ValueOfString(redacted))
+			} else {
+				message.Clear(field)
+			}
+			return true
+		}
+
+		if field.Kind() != protoreflect.MessageKind && field.Kind() != protoreflect.GroupKind {
+			return true
+		}
+		switch {
+		case field.IsList():
+			list := value.List()
+			for i := 0; i < list.Len(); i++ {
+				redactMessage(list.Get(i).Message())
+			}
+		case field.IsMap():
+			if field.MapValue().Kind() == protoreflect.MessageKind {
+				value.Map().Range(func(_ protoreflect.MapKey, entry protoreflect.Value) bool {
+					redactMessage(entry.Message())
+					return true
+				})
+			}
+		default:
+			redactMessage(value.Message())
+		}
+		return true
+	})
+}
+
+// LoggerWithTraceContext adds trace context, the request ID and the business
+// context passed in baggage to logger
 func LoggerWithTraceContext(ctx context.Context, logger *zap.Logger) *zap.Logger {
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		logger = LoggerWithRequestID(logger, requestID)
+	}
+	if fields := businessFields(ctx); len(fields) > 0 {
+		logger = logger.With(fields...)
+	}
+
 	span := trace.SpanFromContext(ctx)
 	if !span.IsRecording() {
 		return logger
@@ -54,4 +493,3 @@ func LoggerWithOrderID(logger *zap.Logger, orderID string) *zap.Logger {
 func LoggerWithCustomerID(logger *zap.Logger, customerID string) *zap.Logger {
 	return logger.With(zap.String("customer_id", customerID))
 }
-
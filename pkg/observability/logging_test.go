@@ -0,0 +1,207 @@
+package observability
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	paymentpb "github.com/your-org/order-processing-system/pkg/pb/payment"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// newObservedLogger returns a logger wrapped like NewLogger's, writing to an
+// observer instead of stderr
+func newObservedLogger(level zapcore.Level, policy LogPolicy, sampling *zap.SamplingConfig) (*zap.Logger, *observer.ObservedLogs) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	return zap.New(wrapCore(core, NewLogLevels(level), policy, sampling)), logs
+}
+
+func testPolicy() LogPolicy {
+	policy := DefaultLogPolicy()
+	policy.Fields["secret"] = FieldDrop
+	policy.HashKey = []byte("test-key")
+	return policy
+}
+
+func TestLoggerSamplesEntries(t *testing.T) {
+	logger, logs := newObservedLogger(zapcore.InfoLevel, testPolicy(), &zap.SamplingConfig{Initial: 2, Thereafter: 3})
+
+	for i := 0; i < 10; i++ {
+		logger.Info("repeated")
+	}
+	// The first two entries, then every third: the 5th and the 8th
+	if got := logs.Len(); got != 4 {
+		t.Errorf("wrote %d of 10 repeated entries, want 4", got)
+	}
+
+	logger.Debug("below level")
+	if got := logs.FilterMessage("below level").Len(); got != 0 {
+		t.Errorf("wrote %d entries below the level", got)
+	}
+}
+
+func TestLoggerAppliesPolicy(t *testing.T) {
+	policy := testPolicy()
+	logger, logs := newObservedLogger(zapcore.InfoLevel, policy, &zap.SamplingConfig{Initial: 100, Thereafter: 100})
+	hash := policy.hash("customer-1")
+
+	logger.With(zap.String("customer_id", "customer-1"), zap.Float64("amount", 12.5)).
+		Info("with fields", zap.String("order_id", "order-1"))
+	logger.Info("entry fields",
+		zap.String("customer_id", "customer-1"),
+		zap.Float64("amount", 12.5),
+		zap.String("secret", "hunter2"),
+		zap.String("order_id", "order-1"))
+
+	for _, entry := range logs.All() {
+		fields := entry.ContextMap()
+		if got := fields["customer_id"]; got != hash {
+			t.Errorf("%s: customer_id = %v, want %s", entry.Message, got, hash)
+		}
+		if got := fields["amount"]; got != redacted {
+			t.Errorf("%s: amount = %v, want %s", entry.Message, got, redacted)
+		}
+		if _, exists := fields["secret"]; exists {
+			t.Errorf("%s: dropped field written", entry.Message)
+		}
+		if got := fields["order_id"]; got != "order-1" {
+			t.Errorf("%s: order_id = %v, want it kept", entry.Message, got)
+		}
+	}
+	if logs.Len() != 2 {
+		t.Errorf("wrote %d entries, want 2", logs.Len())
+	}
+
+	// Hashes depend on the key, so services sharing it can correlate entries
+	other := LogPolicy{HashKey: []byte("other-key")}
+	if other.hash("customer-1") == hash {
+		t.Error("hash does not depend on the key")
+	}
+}
+
+func TestNewLoggerRequiresHashKey(t *testing.T) {
+	if _, err := NewLogger("test-service", NewLogLevels(zapcore.InfoLevel), DefaultLogPolicy()); err == nil {
+		t.Error("NewLogger() hashing fields without a key succeeded")
+	}
+	if _, err := NewLogger("test-service", NewLogLevels(zapcore.InfoLevel), testPolicy()); err != nil {
+		t.Errorf("NewLogger() with a hash key error = %v", err)
+	}
+
+	// A policy that hashes nothing needs no key
+	policy, err := DefaultLogPolicy().ParseFields("customer_id=keep,token=redact,email=drop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewLogger("test-service", NewLogLevels(zapcore.InfoLevel), policy); err != nil {
+		t.Errorf("NewLogger() without hashed fields error = %v", err)
+	}
+}
+
+func TestProtoRedactsDebugRedactFields(t *testing.T) {
+	logger, logs := newObservedLogger(zapcore.DebugLevel, testPolicy(), &zap.SamplingConfig{Initial: 100, Thereafter: 100})
+
+	logger.Debug("charging", Proto("method", &paymentpb.PaymentMethod{Method: &paymentpb.PaymentMethod_Card{Card: &paymentpb.CardDetails{
+		Number:      "4111111111111111",
+		ExpiryMonth: 12,
+		ExpiryYear:  2030,
+		Cvv:         "123",
+		HolderName:  "Jane Doe",
+	}}}))
+
+	method, ok := logs.All()[0].ContextMap()["method"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("method = %v, want an object", logs.All()[0].ContextMap()["method"])
+	}
+	card := method["card"].(map[string]interface{})
+	for _, key := range []string{"number", "cvv", "holderName"} {
+		if card[key] != redacted {
+			t.Errorf("card %s = %v, want %s", key, card[key], redacted)
+		}
+	}
+	if card["expiryMonth"] != float64(12) {
+		t.Errorf("card expiryMonth = %v, want 12", card["expiryMonth"])
+	}
+}
+
+func TestLogLevelsHandler(t *testing.T) {
+	levels := NewLogLevels(zapcore.InfoLevel)
+	handler := levels.Handler()
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		wantStatus int
+		want       logLevelsState
+	}{
+		{"list", http.MethodGet, "/admin/loglevel", "", http.StatusOK, logLevelsState{Level: "info", Loggers: map[string]string{}}},
+		{"overall", http.MethodPut, "/admin/loglevel", `{"level":"warn"}`, http.StatusOK, logLevelsState{Level: "warn", Loggers: map[string]string{}}},
+		{"one logger", http.MethodPut, "/admin/loglevel", `{"logger":"discovery","level":"debug"}`, http.StatusOK, logLevelsState{Level: "warn", Loggers: map[string]string{"discovery": "debug"}}},
+		{"unknown level", http.MethodPut, "/admin/loglevel", `{"level":"loud"}`, http.StatusBadRequest, logLevelsState{}},
+		{"malformed", http.MethodPut, "/admin/loglevel", `{"level":`, http.StatusBadRequest, logLevelsState{}},
+		{"clear without logger", http.MethodDelete, "/admin/loglevel", "", http.StatusBadRequest, logLevelsState{}},
+		{"clear", http.MethodDelete, "/admin/loglevel?logger=discovery", "", http.StatusOK, logLevelsState{Level: "warn", Loggers: map[string]string{}}},
+		{"method not allowed", http.MethodPost, "/admin/loglevel", `{"level":"debug"}`, http.StatusMethodNotAllowed, logLevelsState{}},
+	}
+
+	for _, tt := range tests {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body)))
+
+		if recorder.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, recorder.Code, tt.wantStatus)
+			continue
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+		var got logLevelsState
+		if err := json.NewDecoder(recorder.Body).Decode(&got); err != nil {
+			t.Fatalf("%s: decoding response: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: levels = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	// Failed changes leave the levels as they were
+	if levels.enabled("gateway", zapcore.InfoLevel) || !levels.enabled("gateway", zapcore.WarnLevel) {
+		t.Error("overall level is not warn after the failed changes")
+	}
+}
+
+func TestLogLevelsEnabled(t *testing.T) {
+	levels := NewLogLevels(zapcore.InfoLevel)
+	levels.SetLoggerLevel("discovery", zapcore.DebugLevel)
+	levels.SetLoggerLevel("discovery.balancer", zapcore.ErrorLevel)
+
+	tests := []struct {
+		logger string
+		level  zapcore.Level
+		want   bool
+	}{
+		{"", zapcore.InfoLevel, true},
+		{"", zapcore.DebugLevel, false},
+		{"discovery", zapcore.DebugLevel, true},
+		{"discovery.srv", zapcore.DebugLevel, true},
+		{"discovery.balancer", zapcore.WarnLevel, false},
+		{"discovery.balancer.picker", zapcore.ErrorLevel, true},
+		{"discoveryx", zapcore.DebugLevel, false},
+	}
+	for _, tt := range tests {
+		if got := levels.enabled(tt.logger, tt.level); got != tt.want {
+			t.Errorf("enabled(%q, %s) = %v, want %v", tt.logger, tt.level, got, tt.want)
+		}
+	}
+
+	levels.ClearLoggerLevel("discovery")
+	if levels.enabled("discovery.srv", zapcore.DebugLevel) {
+		t.Error("cleared logger still logs below the overall level")
+	}
+}
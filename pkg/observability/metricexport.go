@@ -0,0 +1,120 @@
+package observability
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	promBridge "go.opentelemetry.io/contrib/bridges/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.uber.org/zap"
+)
+
+// MetricsExportConfig selects whether metrics are also pushed over OTLP, in
+// addition to being served for Prometheus to scrape
+type MetricsExportConfig struct {
+	// Exporter is "otlp" or "none"
+	Exporter string
+	// Protocol is the OTLP protocol, "grpc" or "http/protobuf". The endpoint,
+	// headers and TLS settings come from the standard OTEL_EXPORTER_OTLP_* variables,
+	// and the push interval from OTEL_METRIC_EXPORT_INTERVAL.
+	Protocol string
+}
+
+// DefaultMetricsExportConfig returns a config that only serves metrics for scraping
+func DefaultMetricsExportConfig() MetricsExportConfig {
+	return MetricsExportConfig{
+		Exporter: "none",
+		Protocol: "grpc",
+	}
+}
+
+// MetricsExportConfigFromEnv reads the metrics export config from the standard
+// OTEL_METRICS_EXPORTER and OTEL_EXPORTER_OTLP_PROTOCOL variables
+func MetricsExportConfigFromEnv() MetricsExportConfig {
+	config := DefaultMetricsExportConfig()
+	if value := os.Getenv("OTEL_METRICS_EXPORTER"); value != "" {
+		config.Exporter = value
+	}
+	if value := os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL"); value != "" {
+		config.Protocol = value
+	}
+	if value := os.Getenv("OTEL_EXPORTER_OTLP_METRICS_PROTOCOL"); value != "" {
+		config.Protocol = value
+	}
+	return config
+}
+
+// withoutSummaries gathers from gatherer without its summaries, which have no
+// OTLP equivalent; the Go runtime's GC pause summary is the only one
+func withoutSummaries(gatherer prometheus.Gatherer) prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		families, err := gatherer.Gather()
+		kept := families[:0]
+		for _, family := range families {
+			if family.GetType() != dto.MetricType_SUMMARY {
+				kept = append(kept, family)
+			}
+		}
+		return kept, err
+	})
+}
+
+// newMetricExporter builds the OTLP exporter for a checked config
+func newMetricExporter(ctx context.Context, config MetricsExportConfig) (sdkmetric.Exporter, error) {
+	if strings.ToLower(config.Protocol) == "grpc" {
+		return otlpmetricgrpc.New(ctx)
+	}
+	return otlpmetrichttp.New(ctx)
+}
+
+// InitMetricsExport starts pushing a service's metrics over
+// OTLP, exemplars included, through an OpenTelemetry meter provider that is
+// also set as the global one. Like InitTracing it fails only on an invalid
+// config; with the "none" exporter it does nothing.
+func InitMetricsExport(serviceName string, metrics *Metrics, config MetricsExportConfig, logger *zap.Logger) (func(), error) {
+	switch strings.ToLower(config.Exporter) {
+	case "none":
+		return func() {}, nil
+	case "otlp":
+		switch strings.ToLower(config.Protocol) {
+		case "grpc", "http/protobuf", "http":
+		default:
+			return nil, fmt.Errorf("invalid metrics export config: unknown OTLP protocol %q", config.Protocol)
+		}
+	default:
+		return nil, fmt.Errorf("invalid metrics export config: unknown exporter %q", config.Exporter)
+	}
+
+	exp, err := newMetricExporter(context.Background(), config)
+	if err != nil {
+		logger.Warn("Failed to create metric exporter, metrics will only be scraped", zap.Error(err))
+		return func() {}, nil
+	}
+
+	reader := sdkmetric.NewPeriodicReader(exp,
+		sdkmetric.WithProducer(promBridge.NewMetricProducer(promBridge.WithGatherer(withoutSummaries(metrics.Gatherer())))),
+	)
+	mp := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(reader),
+		sdkmetric.WithResource(newResource(serviceName, logger)),
+	)
+	otel.SetMeterProvider(mp)
+
+	logger.Info("Metrics export initialized",
+		zap.String("service", serviceName),
+		zap.String("exporter", config.Exporter),
+		zap.String("protocol", config.Protocol))
+
+	return func() {
+		if err := mp.Shutdown(context.Background()); err != nil {
+			logger.Error("Failed to shutdown meter provider", zap.Error(err))
+		}
+	}, nil
+}
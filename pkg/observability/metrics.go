@@ -1,88 +1,387 @@
 package observability
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/trace"
 )
 
-var (
-	// HTTP/gRPC request metrics
-	RequestsTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "requests_total",
-			Help: "Total number of requests",
-		},
-		[]string{"service", "method", "status"},
-	)
+// RequestDurationBuckets are the buckets of the request duration histograms;
+// latency SLO thresholds must be one of them
+var RequestDurationBuckets = prometheus.DefBuckets
 
-	RequestDuration = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "request_duration_seconds",
-			Help:    "Request duration in seconds",
-			Buckets: prometheus.DefBuckets,
-		},
-		[]string{"service", "method"},
-	)
+// messageSizeBuckets spans 64 bytes to 4 MiB, gRPC's default maximum message size
+var messageSizeBuckets = prometheus.ExponentialBuckets(64, 4, 9)
+
+// Metrics holds one service's Prometheus metrics and the registry they are
+// registered with. Every series carries a constant service label.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	// HTTP/gRPC request metrics
+	RequestsTotal                *prometheus.CounterVec
+	RequestDuration              *prometheus.HistogramVec
+	RequestsInFlight             *prometheus.GaugeVec
+	RequestSize                  *prometheus.HistogramVec
+	ResponseSize                 *prometheus.HistogramVec
+	StreamMessagesReceived       *prometheus.CounterVec
+	StreamMessagesSent           *prometheus.CounterVec
+	ClientRequestsTotal          *prometheus.CounterVec
+	ClientRequestDuration        *prometheus.HistogramVec
+	ClientRequestsInFlight       *prometheus.GaugeVec
+	ClientRequestSize            *prometheus.HistogramVec
+	ClientResponseSize           *prometheus.HistogramVec
+	ClientStreamMessagesReceived *prometheus.CounterVec
+	ClientStreamMessagesSent     *prometheus.CounterVec
+	ClientRetries                *prometheus.CounterVec
+	ClientRetriesThrottled       *prometheus.CounterVec
+	CircuitBreakerState          *prometheus.GaugeVec
+	CircuitBreakerTransitions    *prometheus.CounterVec
+	DownstreamEndpoints          *prometheus.GaugeVec
+	DownstreamConnections        *prometheus.GaugeVec
+	OutlierEjections             *prometheus.CounterVec
+	FaultsInjected               *prometheus.CounterVec
 
 	// Business metrics
-	OrdersCreated = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "orders_created_total",
-			Help: "Total number of orders created",
-		},
-		[]string{"status"},
-	)
+	OrdersCreated         *prometheus.CounterVec
+	FraudDecisions        *prometheus.CounterVec
+	DeferredPayments      *prometheus.CounterVec
+	PendingPaymentOrders  prometheus.Gauge
+	PaymentsProcessed     *prometheus.CounterVec
+	ReconciliationEntries *prometheus.CounterVec
+	InventoryReservations *prometheus.CounterVec
+	CurrentStock          *prometheus.GaugeVec
 
-	PaymentsProcessed = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "payments_processed_total",
-			Help: "Total number of payments processed",
-		},
-		[]string{"status"},
-	)
+	// System metrics
+	ActiveConnections prometheus.Gauge
+}
 
-	InventoryReservations = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "inventory_reservations_total",
-			Help: "Total number of inventory reservations",
-		},
-		[]string{"product_id", "status"},
-	)
+// NewMetrics creates a service's metrics on a registry of its own, together
+// with Go runtime and process collectors. Services in one process each get
+// their own, so constructing it more than once never conflicts.
+func NewMetrics(serviceName string) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
 
-	CurrentStock = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "current_stock",
-			Help: "Current stock levels for products",
-		},
-		[]string{"product_id", "product_name"},
-	)
+		RequestsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "requests_total",
+				Help: "Total number of requests, by gRPC status code",
+			},
+			[]string{"method", "code"},
+		),
 
-	// System metrics
-	ActiveConnections = prometheus.NewGauge(
-		prometheus.GaugeOpts{
-			Name: "active_connections",
-			Help: "Number of active gRPC connections",
-		},
-	)
-)
+		RequestDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "request_duration_seconds",
+				Help:    "Request duration in seconds",
+				Buckets: RequestDurationBuckets,
+			},
+			[]string{"method"},
+		),
+
+		RequestsInFlight: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "requests_in_flight",
+				Help: "Number of requests being handled",
+			},
+			[]string{"method"},
+		),
+
+		RequestSize: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "request_size_bytes",
+				Help:    "Size of received request messages in bytes",
+				Buckets: messageSizeBuckets,
+			},
+			[]string{"method"},
+		),
+
+		ResponseSize: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "response_size_bytes",
+				Help:    "Size of sent response messages in bytes",
+				Buckets: messageSizeBuckets,
+			},
+			[]string{"method"},
+		),
+
+		StreamMessagesReceived: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "stream_messages_received_total",
+				Help: "Total number of messages received on streaming requests",
+			},
+			[]string{"method"},
+		),
+
+		StreamMessagesSent: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "stream_messages_sent_total",
+				Help: "Total number of messages sent on streaming requests",
+			},
+			[]string{"method"},
+		),
+
+		ClientRequestsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "client_requests_total",
+				Help: "Total number of gRPC client calls to downstream services, by gRPC status code",
+			},
+			[]string{"method", "code"},
+		),
+
+		ClientRequestDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "client_request_duration_seconds",
+				Help:    "gRPC client call duration in seconds",
+				Buckets: RequestDurationBuckets,
+			},
+			[]string{"method"},
+		),
+
+		ClientRequestsInFlight: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "client_requests_in_flight",
+				Help: "Number of gRPC client calls waiting on downstream services",
+			},
+			[]string{"method"},
+		),
+
+		ClientRequestSize: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "client_request_size_bytes",
+				Help:    "Size of request messages sent to downstream services in bytes",
+				Buckets: messageSizeBuckets,
+			},
+			[]string{"method"},
+		),
 
-// InitMetrics initializes and registers all metrics
-func InitMetrics() {
-	prometheus.MustRegister(
-		RequestsTotal,
-		RequestDuration,
-		OrdersCreated,
-		PaymentsProcessed,
-		InventoryReservations,
-		CurrentStock,
-		ActiveConnections,
+		ClientResponseSize: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "client_response_size_bytes",
+				Help:    "Size of response messages received from downstream services in bytes",
+				Buckets: messageSizeBuckets,
+			},
+			[]string{"method"},
+		),
+
+		ClientStreamMessagesReceived: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "client_stream_messages_received_total",
+				Help: "Total number of messages received on streaming gRPC client calls",
+			},
+			[]string{"method"},
+		),
+
+		ClientStreamMessagesSent: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "client_stream_messages_sent_total",
+				Help: "Total number of messages sent on streaming gRPC client calls",
+			},
+			[]string{"method"},
+		),
+
+		ClientRetries: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "client_retries_total",
+				Help: "Total number of retried gRPC client calls",
+			},
+			[]string{"method", "code"},
+		),
+
+		ClientRetriesThrottled: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "client_retries_throttled_total",
+				Help: "Total number of gRPC client retries suppressed by the retry budget",
+			},
+			[]string{"method"},
+		),
+
+		CircuitBreakerState: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "circuit_breaker_state",
+				Help: "Circuit breaker state per downstream (0=closed, 1=open, 2=half-open)",
+			},
+			[]string{"name"},
+		),
+
+		CircuitBreakerTransitions: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "circuit_breaker_transitions_total",
+				Help: "Total number of circuit breaker state transitions",
+			},
+			[]string{"name", "state"},
+		),
+
+		DownstreamEndpoints: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "downstream_endpoints",
+				Help: "Number of addresses currently resolved for a downstream target",
+			},
+			[]string{"target"},
+		),
+
+		DownstreamConnections: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "downstream_connections",
+				Help: "Number of downstream subchannels per connectivity state",
+			},
+			[]string{"target", "state"},
+		),
+
+		OutlierEjections: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "outlier_ejections_total",
+				Help: "Total number of downstream endpoints ejected for consecutive failures",
+			},
+			[]string{"target"},
+		),
+
+		FaultsInjected: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "faults_injected_total",
+				Help: "Total number of faults injected into gRPC calls for chaos testing",
+			},
+			[]string{"method", "code"},
+		),
+
+		OrdersCreated: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "orders_created_total",
+				Help: "Total number of orders created",
+			},
+			[]string{"status"},
+		),
+
+		FraudDecisions: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "fraud_decisions_total",
+				Help: "Total number of fraud screening decisions",
+			},
+			[]string{"action"},
+		),
+
+		DeferredPayments: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "deferred_payments_total",
+				Help: "Total number of orders accepted without payment, by outcome",
+			},
+			[]string{"outcome"},
+		),
+
+		PendingPaymentOrders: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "pending_payment_orders",
+				Help: "Number of orders waiting for a deferred payment",
+			},
+		),
+
+		PaymentsProcessed: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "payments_processed_total",
+				Help: "Total number of payments processed",
+			},
+			[]string{"status"},
+		),
+
+		ReconciliationEntries: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "reconciliation_entries_total",
+				Help: "Total number of settlement reconciliation entries",
+			},
+			[]string{"status"},
+		),
+
+		InventoryReservations: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "inventory_reservations_total",
+				Help: "Total number of inventory reservations",
+			},
+			[]string{"product_id", "status"},
+		),
+
+		CurrentStock: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "current_stock",
+				Help: "Current stock levels for products",
+			},
+			[]string{"product_id", "product_name"},
+		),
+
+		ActiveConnections: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "active_connections",
+				Help: "Number of open gRPC server connections",
+			},
+		),
+	}
+
+	registerer := prometheus.WrapRegistererWith(prometheus.Labels{"service": serviceName}, m.registry)
+	registerer.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.RequestsTotal,
+		m.RequestDuration,
+		m.RequestsInFlight,
+		m.RequestSize,
+		m.ResponseSize,
+		m.StreamMessagesReceived,
+		m.StreamMessagesSent,
+		m.ClientRequestsTotal,
+		m.ClientRequestDuration,
+		m.ClientRequestsInFlight,
+		m.ClientRequestSize,
+		m.ClientResponseSize,
+		m.ClientStreamMessagesReceived,
+		m.ClientStreamMessagesSent,
+		m.ClientRetries,
+		m.ClientRetriesThrottled,
+		m.CircuitBreakerState,
+		m.CircuitBreakerTransitions,
+		m.DownstreamEndpoints,
+		m.DownstreamConnections,
+		m.OutlierEjections,
+		m.FaultsInjected,
+		m.OrdersCreated,
+		m.FraudDecisions,
+		m.DeferredPayments,
+		m.PendingPaymentOrders,
+		m.PaymentsProcessed,
+		m.ReconciliationEntries,
+		m.InventoryReservations,
+		m.CurrentStock,
+		m.ActiveConnections,
 	)
+	return m
+}
+
+// Gatherer returns the registry the metrics are registered with
+func (m *Metrics) Gatherer() prometheus.Gatherer {
+	return m.registry
 }
 
-// MetricsHandler returns an HTTP handler for Prometheus metrics
-func MetricsHandler() http.Handler {
-	return promhttp.Handler()
+// Handler returns an HTTP handler for the service's metrics. It serves the
+// OpenMetrics format to scrapers that ask for it, since only that format
+// carries exemplars.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.InstrumentMetricHandler(
+		m.registry,
+		promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{EnableOpenMetrics: true}),
+	)
 }
 
+// ObserveWithTrace records a value, attaching the trace ID of the sampled span
+// in ctx as an exemplar so a latency bucket links to a trace that landed in it
+func ObserveWithTrace(ctx context.Context, observer prometheus.Observer, value float64) {
+	spanContext := trace.SpanContextFromContext(ctx)
+	exemplarObserver, ok := observer.(prometheus.ExemplarObserver)
+	if !ok || !spanContext.IsSampled() {
+		observer.Observe(value)
+		return
+	}
+	exemplarObserver.ObserveWithExemplar(value, prometheus.Labels{"trace_id": spanContext.TraceID().String()})
+}
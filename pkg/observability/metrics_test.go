@@ -0,0 +1,128 @@
+package observability
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// spanContext returns ctx carrying a remote span context with the given sampling
+func spanContext(ctx context.Context, sampled bool) (context.Context, trace.SpanContext) {
+	config := trace.SpanContextConfig{
+		TraceID: trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:  trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+	}
+	if sampled {
+		config.TraceFlags = trace.FlagsSampled
+	}
+	sc := trace.NewSpanContext(config)
+	return trace.ContextWithSpanContext(ctx, sc), sc
+}
+
+// exemplars returns the trace IDs of the exemplars on a histogram's buckets
+func exemplars(t *testing.T, histogram prometheus.Histogram) []string {
+	t.Helper()
+	var metric dto.Metric
+	if err := histogram.Write(&metric); err != nil {
+		t.Fatal(err)
+	}
+
+	var traceIDs []string
+	for _, bucket := range metric.GetHistogram().GetBucket() {
+		for _, label := range bucket.GetExemplar().GetLabel() {
+			if label.GetName() == "trace_id" {
+				traceIDs = append(traceIDs, label.GetValue())
+			}
+		}
+	}
+	return traceIDs
+}
+
+func TestObserveWithTrace(t *testing.T) {
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_seconds", Buckets: RequestDurationBuckets})
+
+	ctx, sc := spanContext(context.Background(), true)
+	ObserveWithTrace(ctx, histogram, 0.02)
+	if got := exemplars(t, histogram); len(got) != 1 || got[0] != sc.TraceID().String() {
+		t.Errorf("exemplars = %v, want the sampled trace %s", got, sc.TraceID())
+	}
+
+	unsampled := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_seconds", Buckets: RequestDurationBuckets})
+	ctx, _ = spanContext(context.Background(), false)
+	ObserveWithTrace(ctx, unsampled, 0.02)
+	ObserveWithTrace(context.Background(), unsampled, 0.02)
+	if got := exemplars(t, unsampled); len(got) != 0 {
+		t.Errorf("exemplars = %v, want none for unsampled or untraced observations", got)
+	}
+
+	var metric dto.Metric
+	unsampled.Write(&metric)
+	if count := metric.GetHistogram().GetSampleCount(); count != 2 {
+		t.Errorf("sample count = %d, want observations without exemplars recorded", count)
+	}
+}
+
+func TestNewMetricsRegistriesAreIsolated(t *testing.T) {
+	// Constructing the same service's metrics twice must not conflict
+	first, second := NewMetrics("order-service"), NewMetrics("order-service")
+	first.OrdersCreated.WithLabelValues("created").Inc()
+
+	if got := testutil.ToFloat64(second.OrdersCreated.WithLabelValues("created")); got != 0 {
+		t.Errorf("second registry counted %v orders, want 0", got)
+	}
+	count, err := testutil.GatherAndCount(second.Gatherer(), "orders_created_total")
+	if err != nil || count != 1 {
+		t.Errorf("second registry has %d orders_created_total series, error %v; want only its own", count, err)
+	}
+}
+
+func TestNewMetricsServiceLabel(t *testing.T) {
+	metrics := NewMetrics("payment-service")
+	metrics.PaymentsProcessed.WithLabelValues("success").Inc()
+
+	families, err := metrics.Gatherer().Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	runtime := false
+	for _, family := range families {
+		runtime = runtime || family.GetName() == "go_goroutines"
+		for _, metric := range family.GetMetric() {
+			service := ""
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "service" {
+					service = label.GetValue()
+				}
+			}
+			if service != "payment-service" {
+				t.Errorf("%s has service label %q, want payment-service", family.GetName(), service)
+			}
+		}
+	}
+	if !runtime {
+		t.Error("Go runtime metrics are not registered")
+	}
+}
+
+func TestMetricsHandlerServesExemplars(t *testing.T) {
+	metrics := NewMetrics("order-service")
+	ctx, sc := spanContext(context.Background(), true)
+	ObserveWithTrace(ctx, metrics.RequestDuration.WithLabelValues("/order.OrderService/CreateOrder"), 0.02)
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	recorder := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(recorder, req)
+
+	want := `# {trace_id="` + sc.TraceID().String() + `"} 0.02`
+	if body := recorder.Body.String(); !strings.Contains(body, want) {
+		t.Errorf("OpenMetrics response has no exemplar %s", want)
+	}
+}
@@ -0,0 +1,319 @@
+package observability_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/your-org/order-processing-system/pkg/apierrors"
+	"github.com/your-org/order-processing-system/pkg/chaos"
+	"github.com/your-org/order-processing-system/pkg/observability"
+	inventrypb "github.com/your-org/order-processing-system/pkg/pb/inventory"
+	orderpb "github.com/your-org/order-processing-system/pkg/pb/order"
+	paymentpb "github.com/your-org/order-processing-system/pkg/pb/payment"
+	"github.com/your-org/order-processing-system/pkg/resilience"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newRecordingProvider installs a global tracer provider that records every
+// span, with the propagators InitTracing sets, until the test ends
+func newRecordingProvider(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+		provider.Shutdown(context.Background())
+	})
+	return recorder
+}
+
+// newServer creates a server with the interceptor chain the services use;
+// request validation is left out as it does not touch the context
+func newServer(serviceName string) *grpc.Server {
+	logger := zap.NewNop()
+	metrics := observability.NewMetrics(serviceName)
+	requests := observability.NewRequestTracker(10)
+	faults := chaos.NewInjector(metrics, logger)
+
+	return grpc.NewServer(
+		grpc.StatsHandler(observability.ServerStatsHandler(serviceName, observability.DefaultLogPolicy(), metrics, requests)),
+		grpc.ChainUnaryInterceptor(
+			observability.UnaryServerRequestIDInterceptor(),
+			observability.UnaryServerInterceptor(metrics, logger),
+			chaos.UnaryServerInterceptor(faults),
+			apierrors.UnaryServerInterceptor(logger),
+		),
+		grpc.ChainStreamInterceptor(
+			observability.StreamServerRequestIDInterceptor(),
+			observability.StreamServerInterceptor(metrics, logger),
+		),
+	)
+}
+
+// serve starts server on an in-memory listener and returns a connection to it
+// with the interceptor chain the order service uses for its downstreams
+func serve(t *testing.T, server *grpc.Server, clientName string) *grpc.ClientConn {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	logger := zap.NewNop()
+	metrics := observability.NewMetrics(clientName)
+	requests := observability.NewRequestTracker(10)
+	breaker := resilience.NewCircuitBreaker("downstream", resilience.DefaultBreakerConfig(), metrics, logger)
+	faults := chaos.NewInjector(metrics, logger)
+
+	conn, err := grpc.Dial("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(observability.ClientStatsHandler(clientName, observability.DefaultLogPolicy(), metrics, requests)),
+		grpc.WithChainUnaryInterceptor(
+			observability.UnaryClientRequestIDInterceptor(),
+			observability.UnaryClientInterceptor(metrics, logger),
+			resilience.UnaryClientBreakerInterceptor(breaker),
+			resilience.UnaryClientRetryInterceptor(resilience.DefaultRetryConfig(), metrics, logger),
+			resilience.UnaryClientTimeoutInterceptor(resilience.DefaultTimeoutConfig()),
+			chaos.UnaryClientInterceptor(faults),
+		),
+		grpc.WithChainStreamInterceptor(
+			observability.StreamClientRequestIDInterceptor(),
+			observability.StreamClientInterceptor(metrics, logger),
+		),
+	)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+type paymentServer struct {
+	paymentpb.UnimplementedPaymentServiceServer
+}
+
+func (s *paymentServer) ProcessPayment(ctx context.Context, req *paymentpb.PaymentRequest) (*paymentpb.PaymentResponse, error) {
+	return &paymentpb.PaymentResponse{Status: paymentpb.PaymentStatus_PAYMENT_STATUS_SUCCESS}, nil
+}
+
+// inventoryServer charges for every reservation, so a reservation makes one
+// more hop
+type inventoryServer struct {
+	inventrypb.UnimplementedInventoryServiceServer
+	payment paymentpb.PaymentServiceClient
+}
+
+func (s *inventoryServer) ReserveStock(ctx context.Context, req *inventrypb.ReserveStockRequest) (*inventrypb.ReserveStockResponse, error) {
+	if _, err := s.payment.ProcessPayment(ctx, &paymentpb.PaymentRequest{OrderId: req.OrderId}); err != nil {
+		return nil, err
+	}
+	return &inventrypb.ReserveStockResponse{Success: true}, nil
+}
+
+type orderServer struct {
+	orderpb.UnimplementedOrderServiceServer
+	inventory inventrypb.InventoryServiceClient
+}
+
+func (s *orderServer) CreateOrder(ctx context.Context, req *orderpb.CreateOrderRequest) (*orderpb.CreateOrderResponse, error) {
+	if _, err := s.inventory.ReserveStock(ctx, &inventrypb.ReserveStockRequest{OrderId: "order-1"}); err != nil {
+		return nil, err
+	}
+	return &orderpb.CreateOrderResponse{}, nil
+}
+
+// healthServer answers Watch with the first status of the next service's
+// Watch stream, if there is one, so a Watch call streams through every hop
+type healthServer struct {
+	healthpb.UnimplementedHealthServer
+	next healthpb.HealthClient
+}
+
+func (s *healthServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	resp := &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}
+	if s.next != nil {
+		var err error
+		if resp, err = watchOnce(stream.Context(), s.next); err != nil {
+			return err
+		}
+	}
+	return stream.Send(resp)
+}
+
+// watchOnce reads a Watch stream that sends one status to its end
+func watchOnce(ctx context.Context, client healthpb.HealthClient) (*healthpb.HealthCheckResponse, error) {
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stream.Recv(); err != io.EOF {
+		return nil, fmt.Errorf("stream did not end: %v", err)
+	}
+	return resp, nil
+}
+
+// startServices starts payment, inventory and order, each calling the next,
+// and returns a test client's connection to order
+func startServices(t *testing.T) *grpc.ClientConn {
+	paymentGRPC := newServer("payment-service")
+	paymentpb.RegisterPaymentServiceServer(paymentGRPC, &paymentServer{})
+	healthpb.RegisterHealthServer(paymentGRPC, &healthServer{})
+	paymentConn := serve(t, paymentGRPC, "inventory-service")
+
+	inventoryGRPC := newServer("inventory-service")
+	inventrypb.RegisterInventoryServiceServer(inventoryGRPC, &inventoryServer{payment: paymentpb.NewPaymentServiceClient(paymentConn)})
+	healthpb.RegisterHealthServer(inventoryGRPC, &healthServer{next: healthpb.NewHealthClient(paymentConn)})
+	inventoryConn := serve(t, inventoryGRPC, "order-service")
+
+	orderGRPC := newServer("order-service")
+	orderpb.RegisterOrderServiceServer(orderGRPC, &orderServer{inventory: inventrypb.NewInventoryServiceClient(inventoryConn)})
+	healthpb.RegisterHealthServer(orderGRPC, &healthServer{next: healthpb.NewHealthClient(inventoryConn)})
+	return serve(t, orderGRPC, "test-client")
+}
+
+// hop identifies a span by the service that recorded it, its kind and name
+type hop struct {
+	service string
+	kind    trace.SpanKind
+	method  string
+}
+
+// assertChain checks that the recorder holds one span per hop, all in the
+// trace of root, each the child of the span before it
+func assertChain(t *testing.T, recorder *tracetest.SpanRecorder, root trace.SpanContext, hops []hop) {
+	t.Helper()
+	spans := recorder.Ended()
+	for _, span := range spans {
+		if span.SpanContext().TraceID() != root.TraceID() {
+			t.Errorf("span %s of %s has trace ID %s, want %s",
+				span.Name(), span.InstrumentationScope().Name, span.SpanContext().TraceID(), root.TraceID())
+		}
+	}
+
+	parent := root
+	for _, h := range hops {
+		var found sdktrace.ReadOnlySpan
+		for _, span := range spans {
+			if span.InstrumentationScope().Name == h.service && span.SpanKind() == h.kind && span.Name() == h.method {
+				found = span
+				break
+			}
+		}
+		if found == nil {
+			t.Fatalf("no %s span %s recorded by %s", h.kind, h.method, h.service)
+		}
+		if got := found.Parent().SpanID(); got != parent.SpanID() {
+			t.Errorf("%s span %s of %s has parent %s, want %s", h.kind, h.method, h.service, got, parent.SpanID())
+		}
+		// Server spans continue a trace that arrived over the wire
+		if remote := h.kind == trace.SpanKindServer; found.Parent().IsRemote() != remote {
+			t.Errorf("%s span %s of %s: parent remote = %v, want %v", h.kind, h.method, h.service, found.Parent().IsRemote(), remote)
+		}
+		parent = found.SpanContext()
+	}
+}
+
+func TestTraceContextPropagationUnary(t *testing.T) {
+	recorder := newRecordingProvider(t)
+	conn := startServices(t)
+
+	ctx, root := otel.Tracer("test").Start(context.Background(), "root")
+	_, err := orderpb.NewOrderServiceClient(conn).CreateOrder(ctx, &orderpb.CreateOrderRequest{})
+	root.End()
+	if err != nil {
+		t.Fatalf("CreateOrder() error = %v", err)
+	}
+
+	const (
+		createOrder    = "/order.OrderService/CreateOrder"
+		reserveStock   = "/inventory.InventoryService/ReserveStock"
+		processPayment = "/payment.PaymentService/ProcessPayment"
+	)
+	assertChain(t, recorder, root.SpanContext(), []hop{
+		{"test-client", trace.SpanKindClient, createOrder},
+		{"order-service", trace.SpanKindServer, createOrder},
+		{"order-service", trace.SpanKindClient, reserveStock},
+		{"inventory-service", trace.SpanKindServer, reserveStock},
+		{"inventory-service", trace.SpanKindClient, processPayment},
+		{"payment-service", trace.SpanKindServer, processPayment},
+	})
+}
+
+func TestTraceContextPropagationStreaming(t *testing.T) {
+	recorder := newRecordingProvider(t)
+	conn := startServices(t)
+
+	ctx, root := otel.Tracer("test").Start(context.Background(), "root")
+	_, err := watchOnce(ctx, healthpb.NewHealthClient(conn))
+	root.End()
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+
+	const watch = "/grpc.health.v1.Health/Watch"
+	assertChain(t, recorder, root.SpanContext(), []hop{
+		{"test-client", trace.SpanKindClient, watch},
+		{"order-service", trace.SpanKindServer, watch},
+		{"order-service", trace.SpanKindClient, watch},
+		{"inventory-service", trace.SpanKindServer, watch},
+		{"inventory-service", trace.SpanKindClient, watch},
+		{"payment-service", trace.SpanKindServer, watch},
+	})
+}
+
+func TestSpansRecordOutcome(t *testing.T) {
+	recorder := newRecordingProvider(t)
+	conn := startServices(t)
+
+	// The health servers leave Check unimplemented
+	_, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if status.Code(err) != codes.Unimplemented {
+		t.Fatalf("Check() error = %v, want Unimplemented", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("recorded %d spans, want the client's and the server's", len(spans))
+	}
+	for _, span := range spans {
+		if span.Status().Code != otelcodes.Error {
+			t.Errorf("%v span status = %v, want Error", span.SpanKind(), span.Status())
+		}
+		attributes := map[attribute.Key]attribute.Value{}
+		for _, kv := range span.Attributes() {
+			attributes[kv.Key] = kv.Value
+		}
+		if code := attributes["rpc.grpc.status_code"].AsInt64(); code != int64(codes.Unimplemented) {
+			t.Errorf("%v span status code = %d, want %d", span.SpanKind(), code, codes.Unimplemented)
+		}
+		if attributes["rpc.request_id"].AsString() == "" {
+			t.Errorf("%v span has no request ID", span.SpanKind())
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package observability
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDHeader is the metadata key carrying a request's ID between services
+// and back to the caller
+const RequestIDHeader = "x-request-id"
+
+// maxRequestIDLength bounds the request IDs accepted from callers
+const maxRequestIDLength = 128
+
+// requestIDKey is the context key for the request ID
+type requestIDKey struct{}
+
+// ContextWithRequestID returns ctx carrying a request ID, which client calls
+// made with ctx pass on to downstream services
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID in ctx, or "" when it has none
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// NewRequestID generates a request ID
+func NewRequestID() string {
+	return uuid.New().String()
+}
+
+// validRequestID reports whether a caller's request ID can be used as is; it
+// ends up in logs and headers, so only short printable ASCII IDs are accepted
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(requestID); i++ {
+		if requestID[i] < 0x21 || requestID[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// incomingRequestID returns the caller's request ID, or a new one if it sent
+// none or an invalid one
+func incomingRequestID(ctx context.Context) string {
+	if values := metadata.ValueFromIncomingContext(ctx, RequestIDHeader); len(values) > 0 && validRequestID(values[0]) {
+		return values[0]
+	}
+	return NewRequestID()
+}
+
+// withRequestIDDetail adds the request ID to a status error as a RequestInfo
+// detail, so callers can quote it when reporting the failure. Errors passed
+// on from a downstream service already carry it.
+func withRequestIDDetail(err error, requestID string) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	for _, detail := range st.Details() {
+		if _, ok := detail.(*errdetails.RequestInfo); ok {
+			return err
+		}
+	}
+	detailed, detailErr := st.WithDetails(&errdetails.RequestInfo{RequestId: requestID})
+	if detailErr != nil {
+		return err
+	}
+	return detailed.Err()
+}
+
+// setRequestIDAttribute records the request ID in ctx on a span
+func setRequestIDAttribute(ctx context.Context, span trace.Span) {
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		span.SetAttributes(attribute.String("rpc.request_id", requestID))
+	}
+}
+
+// UnaryServerRequestIDInterceptor creates a gRPC unary server interceptor that
+// takes the caller's request ID, or generates one, puts it in the handler's
+// context, records it on the request's span, returns it in the response
+// headers and adds it to error details. It must come before the
+// observability interceptors so their logs carry the ID.
+func UnaryServerRequestIDInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		requestID := incomingRequestID(ctx)
+		ctx = ContextWithRequestID(ctx, requestID)
+		setRequestIDAttribute(ctx, trace.SpanFromContext(ctx))
+		grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, requestID))
+
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return resp, withRequestIDDetail(err, requestID)
+		}
+		return resp, nil
+	}
+}
+
+// StreamServerRequestIDInterceptor creates a gRPC stream server interceptor
+// that does for streams what UnaryServerRequestIDInterceptor does for unary calls
+func StreamServerRequestIDInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		requestID := incomingRequestID(ss.Context())
+		ctx := ContextWithRequestID(ss.Context(), requestID)
+		setRequestIDAttribute(ctx, trace.SpanFromContext(ctx))
+		ss.SetHeader(metadata.Pairs(RequestIDHeader, requestID))
+
+		if err := handler(srv, &wrappedServerStream{ServerStream: ss, ctx: ctx}); err != nil {
+			return withRequestIDDetail(err, requestID)
+		}
+		return nil
+	}
+}
+
+// outgoingRequestID returns ctx with a request ID that is also sent to the
+// downstream service: the one in ctx, one the caller already put in the
+// outgoing metadata, or a new one for calls made outside any request
+func outgoingRequestID(ctx context.Context) context.Context {
+	if md, ok := metadata.FromOutgoingContext(ctx); ok {
+		if values := md.Get(RequestIDHeader); len(values) > 0 {
+			return ContextWithRequestID(ctx, values[0])
+		}
+	}
+
+	requestID := RequestIDFromContext(ctx)
+	if requestID == "" {
+		requestID = NewRequestID()
+		ctx = ContextWithRequestID(ctx, requestID)
+	}
+	return metadata.AppendToOutgoingContext(ctx, RequestIDHeader, requestID)
+}
+
+// UnaryClientRequestIDInterceptor creates a gRPC unary client interceptor that
+// passes the request ID on to the downstream service. It must come before the
+// observability interceptors so their logs and spans carry the ID.
+func UnaryClientRequestIDInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(outgoingRequestID(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientRequestIDInterceptor creates a gRPC stream client interceptor
+// that passes the request ID on to the downstream service
+func StreamClientRequestIDInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(outgoingRequestID(ctx), desc, cc, method, opts...)
+	}
+}
@@ -0,0 +1,120 @@
+package observability
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// requestIDHealthServer records the request ID each Check runs with and
+// fails checks of unknown services
+type requestIDHealthServer struct {
+	healthpb.UnimplementedHealthServer
+	requestID string
+}
+
+func (s *requestIDHealthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	s.requestID = RequestIDFromContext(ctx)
+	if req.Service != "" {
+		return nil, status.Error(codes.NotFound, "unknown service")
+	}
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+// startRequestIDServer serves health with the request ID interceptors on both sides
+func startRequestIDServer(t *testing.T) (*requestIDHealthServer, healthpb.HealthClient) {
+	t.Helper()
+	health := &requestIDHealthServer{}
+	server := grpc.NewServer(grpc.UnaryInterceptor(UnaryServerRequestIDInterceptor()))
+	healthpb.RegisterHealthServer(server, health)
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(UnaryClientRequestIDInterceptor()),
+	)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return health, healthpb.NewHealthClient(conn)
+}
+
+func TestRequestIDPropagation(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  context.Context
+		// want is the ID the server should see, or "" for a generated one
+		want string
+	}{
+		{"generated", context.Background(), ""},
+		{"from context", ContextWithRequestID(context.Background(), "req-123"), "req-123"},
+		{"from metadata", metadata.AppendToOutgoingContext(context.Background(), RequestIDHeader, "req-456"), "req-456"},
+		{"invalid replaced", metadata.AppendToOutgoingContext(context.Background(), RequestIDHeader, "bad id"), ""},
+		{"too long replaced", metadata.AppendToOutgoingContext(context.Background(), RequestIDHeader, strings.Repeat("a", maxRequestIDLength+1)), ""},
+	}
+
+	for _, tt := range tests {
+		health, client := startRequestIDServer(t)
+
+		var header metadata.MD
+		if _, err := client.Check(tt.ctx, &healthpb.HealthCheckRequest{}, grpc.Header(&header)); err != nil {
+			t.Fatalf("%s: Check() error = %v", tt.name, err)
+		}
+
+		switch {
+		case tt.want != "" && health.requestID != tt.want:
+			t.Errorf("%s: server saw request ID %q, want %q", tt.name, health.requestID, tt.want)
+		case tt.want == "" && !validRequestID(health.requestID):
+			t.Errorf("%s: server saw request ID %q, want a generated one", tt.name, health.requestID)
+		}
+		if echoed := header.Get(RequestIDHeader); len(echoed) != 1 || echoed[0] != health.requestID {
+			t.Errorf("%s: response header %s = %v, want [%s]", tt.name, RequestIDHeader, echoed, health.requestID)
+		}
+	}
+}
+
+// requestInfo returns the request ID in an error's RequestInfo details
+func requestInfo(err error) []string {
+	var requestIDs []string
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.RequestInfo); ok {
+			requestIDs = append(requestIDs, info.RequestId)
+		}
+	}
+	return requestIDs
+}
+
+func TestRequestIDInErrorDetails(t *testing.T) {
+	health, client := startRequestIDServer(t)
+
+	ctx := ContextWithRequestID(context.Background(), "req-789")
+	_, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "unknown"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("Check() error = %v, want NotFound", err)
+	}
+	if got := requestInfo(err); len(got) != 1 || got[0] != health.requestID {
+		t.Errorf("error details carry request IDs %v, want [%s]", got, health.requestID)
+	}
+
+	// An error passed on from downstream keeps the ID it already carries
+	downstream := withRequestIDDetail(status.Error(codes.Unavailable, "down"), "downstream-id")
+	if got := requestInfo(withRequestIDDetail(downstream, "upstream-id")); len(got) != 1 || got[0] != "downstream-id" {
+		t.Errorf("passed on error carries request IDs %v, want [downstream-id]", got)
+	}
+}
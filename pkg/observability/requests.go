@@ -0,0 +1,144 @@
+package observability
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultRecentRequests is how many finished calls a request tracker keeps
+const DefaultRecentRequests = 100
+
+// trackedRequest is a call seen by a stats handler
+type trackedRequest struct {
+	method   string
+	kind     string
+	peer     string
+	traceID  string
+	start    time.Time
+	duration time.Duration
+	code     string
+}
+
+// RequestRecord describes a call on the /debug/requests page
+type RequestRecord struct {
+	Method  string    `json:"method"`
+	Kind    string    `json:"kind"`
+	Peer    string    `json:"peer,omitempty"`
+	TraceID string    `json:"trace_id,omitempty"`
+	Start   time.Time `json:"start"`
+	// Duration is the time taken so far for calls still in flight
+	Duration string `json:"duration"`
+	Code     string `json:"code,omitempty"`
+}
+
+// RequestTracker keeps the calls a service is serving or making, and the most
+// recently finished ones, for debugging a live service
+type RequestTracker struct {
+	mutex    sync.Mutex
+	inFlight map[*trackedRequest]struct{}
+	// recent is a ring of finished calls; next is where the next one goes
+	recent []*trackedRequest
+	next   int
+}
+
+// NewRequestTracker creates a request tracker that keeps the last recent
+// finished calls
+func NewRequestTracker(recent int) *RequestTracker {
+	if recent <= 0 {
+		recent = DefaultRecentRequests
+	}
+	return &RequestTracker{
+		inFlight: make(map[*trackedRequest]struct{}),
+		recent:   make([]*trackedRequest, 0, recent),
+	}
+}
+
+// begin starts tracking a call
+func (t *RequestTracker) begin(request *trackedRequest) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.inFlight[request] = struct{}{}
+}
+
+// setPeer records the other end of a call once its headers are seen
+func (t *RequestTracker) setPeer(request *trackedRequest, peer string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	request.peer = peer
+}
+
+// end moves a call from in flight to the recently finished calls
+func (t *RequestTracker) end(request *trackedRequest, code string, end time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.inFlight, request)
+	request.duration = end.Sub(request.start)
+	request.code = code
+
+	if len(t.recent) < cap(t.recent) {
+		t.recent = append(t.recent, request)
+		return
+	}
+	t.recent[t.next] = request
+	t.next = (t.next + 1) % len(t.recent)
+}
+
+// record describes a tracked call; must be called with the mutex held
+func (r *trackedRequest) record(now time.Time) RequestRecord {
+	duration := r.duration
+	if r.code == "" {
+		duration = now.Sub(r.start)
+	}
+	return RequestRecord{
+		Method:   r.method,
+		Kind:     r.kind,
+		Peer:     r.peer,
+		TraceID:  r.traceID,
+		Start:    r.start,
+		Duration: duration.String(),
+		Code:     r.code,
+	}
+}
+
+// Snapshot returns the calls in flight, oldest first, and the recently
+// finished calls, newest first
+func (t *RequestTracker) Snapshot() (inFlight, recent []RequestRecord) {
+	now := time.Now()
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	inFlight = make([]RequestRecord, 0, len(t.inFlight))
+	for request := range t.inFlight {
+		inFlight = append(inFlight, request.record(now))
+	}
+	sort.Slice(inFlight, func(i, j int) bool {
+		return inFlight[i].Start.Before(inFlight[j].Start)
+	})
+
+	recent = make([]RequestRecord, 0, len(t.recent))
+	for i := len(t.recent) - 1; i >= 0; i-- {
+		recent = append(recent, t.recent[(t.next+i)%len(t.recent)].record(now))
+	}
+	return inFlight, recent
+}
+
+// Handler serves the calls in flight and the recently finished calls as JSON
+func (t *RequestTracker) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		inFlight, recent := t.Snapshot()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			InFlight []RequestRecord `json:"in_flight"`
+			Recent   []RequestRecord `json:"recent"`
+		}{inFlight, recent})
+	})
+}
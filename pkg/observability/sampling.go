@@ -0,0 +1,208 @@
+package observability
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// rateLimitedSampler samples at most a fixed number of traces per second,
+// refilling a token bucket that holds one second's worth of traces
+type rateLimitedSampler struct {
+	perSecond float64
+	now       func() time.Time
+	mutex     sync.Mutex
+	tokens    float64
+	last      time.Time
+}
+
+// RateLimitedSampler returns a sampler that samples at most perSecond traces per second
+func RateLimitedSampler(perSecond float64) sdktrace.Sampler {
+	return newRateLimitedSampler(perSecond, time.Now)
+}
+
+func newRateLimitedSampler(perSecond float64, now func() time.Time) *rateLimitedSampler {
+	return &rateLimitedSampler{perSecond: perSecond, now: now, tokens: perSecond, last: now()}
+}
+
+func (s *rateLimitedSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	s.mutex.Lock()
+	now := s.now()
+	s.tokens += now.Sub(s.last).Seconds() * s.perSecond
+	if s.tokens > s.perSecond {
+		s.tokens = s.perSecond
+	}
+	s.last = now
+
+	decision := sdktrace.Drop
+	if s.tokens >= 1 {
+		s.tokens--
+		decision = sdktrace.RecordAndSample
+	}
+	s.mutex.Unlock()
+
+	return sdktrace.SamplingResult{
+		Decision:   decision,
+		Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+	}
+}
+
+func (s *rateLimitedSampler) Description() string {
+	return fmt.Sprintf("RateLimited{%g}", s.perSecond)
+}
+
+// recordAllSampler records the spans its wrapped sampler would drop, without
+// sampling them, so errorKeepingProcessor can still export them if they fail
+type recordAllSampler struct {
+	sdktrace.Sampler
+}
+
+func (s recordAllSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	result := s.Sampler.ShouldSample(p)
+	if result.Decision == sdktrace.Drop {
+		result.Decision = sdktrace.RecordOnly
+	}
+	return result
+}
+
+func (s recordAllSampler) Description() string {
+	return fmt.Sprintf("RecordAll{%s}", s.Sampler.Description())
+}
+
+// errorKeepingProcessor buffers the spans of unsampled traces until the
+// trace's local root span ends, then passes them on as sampled if any of them
+// recorded an error. Spans that end after their local root, such as those of
+// calls left running in the background, follow the decision already made for
+// their trace. Sampled spans are passed on at once.
+type errorKeepingProcessor struct {
+	next      sdktrace.SpanProcessor
+	maxTraces int
+	maxSpans  int
+	mutex     sync.Mutex
+	traces    map[trace.TraceID]*bufferedTrace
+	order     []trace.TraceID
+	// decided holds whether recently decided traces failed, the oldest
+	// first in decidedOrder
+	decided      map[trace.TraceID]bool
+	decidedOrder []trace.TraceID
+}
+
+// bufferedTrace holds the ended spans of one unsampled trace
+type bufferedTrace struct {
+	spans  []sdktrace.ReadOnlySpan
+	failed bool
+}
+
+// newErrorKeepingProcessor wraps next, buffering at most maxTraces traces of
+// at most maxSpans spans each and remembering the decision for as many; the
+// oldest trace is dropped when the buffer is full
+func newErrorKeepingProcessor(next sdktrace.SpanProcessor, maxTraces, maxSpans int) *errorKeepingProcessor {
+	return &errorKeepingProcessor{
+		next:      next,
+		maxTraces: maxTraces,
+		maxSpans:  maxSpans,
+		traces:    make(map[trace.TraceID]*bufferedTrace),
+		decided:   make(map[trace.TraceID]bool),
+	}
+}
+
+func (p *errorKeepingProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	p.next.OnStart(parent, s)
+}
+
+func (p *errorKeepingProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if s.SpanContext().IsSampled() {
+		p.next.OnEnd(s)
+		return
+	}
+
+	traceID := s.SpanContext().TraceID()
+	localRoot := !s.Parent().IsValid() || s.Parent().IsRemote()
+
+	p.mutex.Lock()
+	if failed, decided := p.decided[traceID]; decided && !localRoot {
+		p.mutex.Unlock()
+		if failed {
+			p.next.OnEnd(keptSpan{ReadOnlySpan: s})
+		}
+		return
+	}
+
+	buffered, exists := p.traces[traceID]
+	if !exists {
+		if len(p.order) >= p.maxTraces {
+			delete(p.traces, p.order[0])
+			p.order = p.order[1:]
+		}
+		buffered = &bufferedTrace{}
+		p.traces[traceID] = buffered
+		p.order = append(p.order, traceID)
+	}
+	if len(buffered.spans) < p.maxSpans {
+		buffered.spans = append(buffered.spans, s)
+	}
+	if s.Status().Code == codes.Error {
+		buffered.failed = true
+	}
+	if !localRoot {
+		p.mutex.Unlock()
+		return
+	}
+
+	delete(p.traces, traceID)
+	for i, id := range p.order {
+		if id == traceID {
+			p.order = append(p.order[:i], p.order[i+1:]...)
+			break
+		}
+	}
+	p.decide(traceID, buffered.failed)
+	p.mutex.Unlock()
+
+	if !buffered.failed {
+		return
+	}
+	for _, span := range buffered.spans {
+		p.next.OnEnd(keptSpan{ReadOnlySpan: span})
+	}
+}
+
+// decide records whether a trace failed once one of its local roots ended;
+// a trace stays failed if another local root already failed it. It must be
+// called with the mutex held.
+func (p *errorKeepingProcessor) decide(traceID trace.TraceID, failed bool) {
+	if previous, exists := p.decided[traceID]; exists {
+		p.decided[traceID] = previous || failed
+		return
+	}
+	if len(p.decidedOrder) >= p.maxTraces {
+		delete(p.decided, p.decidedOrder[0])
+		p.decidedOrder = p.decidedOrder[1:]
+	}
+	p.decided[traceID] = failed
+	p.decidedOrder = append(p.decidedOrder, traceID)
+}
+
+func (p *errorKeepingProcessor) Shutdown(ctx context.Context) error {
+	return p.next.Shutdown(ctx)
+}
+
+func (p *errorKeepingProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+// keptSpan marks a recorded span of a failed trace as sampled, so exporting
+// span processors pass it on
+type keptSpan struct {
+	sdktrace.ReadOnlySpan
+}
+
+func (s keptSpan) SpanContext() trace.SpanContext {
+	sc := s.ReadOnlySpan.SpanContext()
+	return sc.WithTraceFlags(sc.TraceFlags().WithSampled(true))
+}
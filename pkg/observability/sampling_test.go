@@ -0,0 +1,168 @@
+package observability
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRateLimitedSampler(t *testing.T) {
+	now := time.Unix(0, 0)
+	sampler := newRateLimitedSampler(2, func() time.Time { return now })
+	sample := func() bool {
+		return sampler.ShouldSample(sdktrace.SamplingParameters{ParentContext: context.Background()}).Decision == sdktrace.RecordAndSample
+	}
+
+	for i, want := range []bool{true, true, false} {
+		if got := sample(); got != want {
+			t.Errorf("call %d: sampled = %v, want %v", i, got, want)
+		}
+	}
+
+	// Half a second refills one token
+	now = now.Add(500 * time.Millisecond)
+	if !sample() || sample() {
+		t.Error("after 500ms: want exactly one more sampled trace")
+	}
+
+	// The bucket holds no more than one second's worth
+	now = now.Add(time.Minute)
+	for i, want := range []bool{true, true, false} {
+		if got := sample(); got != want {
+			t.Errorf("after a minute, call %d: sampled = %v, want %v", i, got, want)
+		}
+	}
+}
+
+// newKeepingProvider traces with sampler through an errorKeepingProcessor
+// that passes spans on to the returned recorder
+func newKeepingProvider(sampler sdktrace.Sampler, maxTraces int) (*sdktrace.TracerProvider, *errorKeepingProcessor, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	processor := newErrorKeepingProcessor(recorder, maxTraces, 10)
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(recordAllSampler{Sampler: sampler}),
+		sdktrace.WithSpanProcessor(processor),
+	)
+	return provider, processor, recorder
+}
+
+// endedNames returns the names of the spans the recorder received, failing
+// the test if any of them is not sampled
+func endedNames(t *testing.T, recorder *tracetest.SpanRecorder) []string {
+	t.Helper()
+	var names []string
+	for _, span := range recorder.Ended() {
+		if !span.SpanContext().IsSampled() {
+			t.Errorf("span %s passed on unsampled", span.Name())
+		}
+		names = append(names, span.Name())
+	}
+	return names
+}
+
+func TestErrorKeepingProcessor(t *testing.T) {
+	tests := []struct {
+		name    string
+		sampler sdktrace.Sampler
+		fail    bool
+		want    []string
+	}{
+		{name: "unsampled trace is dropped", sampler: sdktrace.NeverSample(), want: nil},
+		{name: "unsampled failed trace is kept", sampler: sdktrace.NeverSample(), fail: true, want: []string{"child", "root"}},
+		{name: "sampled trace is passed on", sampler: sdktrace.AlwaysSample(), want: []string{"child", "root"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, processor, recorder := newKeepingProvider(tt.sampler, 10)
+			tracer := provider.Tracer("test")
+
+			ctx, root := tracer.Start(context.Background(), "root")
+			_, child := tracer.Start(ctx, "child")
+			if tt.fail {
+				child.SetStatus(codes.Error, "failed")
+			}
+			child.End()
+			root.End()
+
+			if got := endedNames(t, recorder); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("passed on %v, want %v", got, tt.want)
+			}
+			if len(processor.traces) != 0 {
+				t.Errorf("%d traces still buffered", len(processor.traces))
+			}
+		})
+	}
+}
+
+func TestErrorKeepingProcessorEvictsOldestTrace(t *testing.T) {
+	provider, processor, recorder := newKeepingProvider(sdktrace.NeverSample(), 1)
+	tracer := provider.Tracer("test")
+
+	firstCtx, first := tracer.Start(context.Background(), "first")
+	_, firstChild := tracer.Start(firstCtx, "first-child")
+	firstChild.End()
+
+	// Buffering a second trace evicts the first one's child
+	secondCtx, second := tracer.Start(context.Background(), "second")
+	_, secondChild := tracer.Start(secondCtx, "second-child")
+	secondChild.End()
+	if _, buffered := processor.traces[first.SpanContext().TraceID()]; buffered {
+		t.Error("first trace still buffered")
+	}
+
+	first.SetStatus(codes.Error, "failed")
+	first.End()
+	if got := endedNames(t, recorder); len(got) != 1 || got[0] != "first" {
+		t.Errorf("passed on %v, want only the first root", got)
+	}
+	second.End()
+}
+
+func TestErrorKeepingProcessorLateSpans(t *testing.T) {
+	for _, failed := range []bool{false, true} {
+		provider, processor, recorder := newKeepingProvider(sdktrace.NeverSample(), 10)
+		tracer := provider.Tracer("test")
+
+		ctx, root := tracer.Start(context.Background(), "root")
+		_, late := tracer.Start(ctx, "late")
+		if failed {
+			root.SetStatus(codes.Error, "failed")
+		}
+		root.End()
+		late.End()
+
+		var want []string
+		if failed {
+			want = []string{"root", "late"}
+		}
+		if got := endedNames(t, recorder); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("failed %v: passed on %v, want %v", failed, got, want)
+		}
+		if len(processor.traces) != 0 {
+			t.Errorf("failed %v: late span was buffered", failed)
+		}
+		if decided, exists := processor.decided[trace.SpanContextFromContext(ctx).TraceID()]; !exists || decided != failed {
+			t.Errorf("failed %v: decision = %v, %v", failed, decided, exists)
+		}
+	}
+}
+
+func TestErrorKeepingProcessorForgetsOldDecisions(t *testing.T) {
+	provider, processor, _ := newKeepingProvider(sdktrace.NeverSample(), 2)
+	tracer := provider.Tracer("test")
+
+	for i := 0; i < 5; i++ {
+		_, root := tracer.Start(context.Background(), "root")
+		root.End()
+	}
+	if len(processor.decided) != 2 || len(processor.decidedOrder) != 2 {
+		t.Errorf("remembered %d decisions, want 2", len(processor.decided))
+	}
+}
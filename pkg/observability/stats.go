@@ -0,0 +1,226 @@
+package observability
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+)
+
+// rpcMetrics are the metrics a stats handler records for one side of a call
+type rpcMetrics struct {
+	inFlight     *prometheus.GaugeVec
+	requestSize  *prometheus.HistogramVec
+	responseSize *prometheus.HistogramVec
+	received     *prometheus.CounterVec
+	sent         *prometheus.CounterVec
+}
+
+// statsHandler traces calls and records in-flight calls, message sizes,
+// streamed messages and, on servers, open connections, and tracks calls for
+// /debug/requests
+type statsHandler struct {
+	serviceName string
+	tracer      trace.Tracer
+	policy      LogPolicy
+	metrics     *Metrics
+	rpc         rpcMetrics
+	requests    *RequestTracker
+	client      bool
+}
+
+// rpcInfoKey is the context key for the call being handled
+type rpcInfoKey struct{}
+
+// rpcInfo is what the handler knows about a call between its events
+type rpcInfo struct {
+	method    string
+	span      trace.Span
+	streaming atomic.Bool
+	// request is the tracked call, if the handler has a request tracker
+	request *trackedRequest
+}
+
+// ServerStatsHandler returns a gRPC stats handler for a server. Install it
+// with grpc.StatsHandler; it traces each request, continuing the caller's
+// trace, and complements the interceptors, which log requests and record
+// their counts by status code and latency. policy is the service's log
+// policy, applied to the business context on spans. Calls are also tracked
+// by requests, if it is not nil.
+func ServerStatsHandler(serviceName string, policy LogPolicy, metrics *Metrics, requests *RequestTracker) stats.Handler {
+	return &statsHandler{
+		serviceName: serviceName,
+		tracer:      otel.Tracer(serviceName),
+		policy:      policy,
+		metrics:     metrics,
+		requests:    requests,
+		rpc: rpcMetrics{
+			inFlight:     metrics.RequestsInFlight,
+			requestSize:  metrics.RequestSize,
+			responseSize: metrics.ResponseSize,
+			received:     metrics.StreamMessagesReceived,
+			sent:         metrics.StreamMessagesSent,
+		},
+	}
+}
+
+// ClientStatsHandler returns a gRPC stats handler for client connections.
+// Install it with grpc.WithStatsHandler; it traces each call attempt and
+// passes the trace context on to the downstream service.
+func ClientStatsHandler(serviceName string, policy LogPolicy, metrics *Metrics, requests *RequestTracker) stats.Handler {
+	return &statsHandler{
+		serviceName: serviceName,
+		tracer:      otel.Tracer(serviceName),
+		policy:      policy,
+		metrics:     metrics,
+		requests:    requests,
+		rpc: rpcMetrics{
+			inFlight:     metrics.ClientRequestsInFlight,
+			requestSize:  metrics.ClientRequestSize,
+			responseSize: metrics.ClientResponseSize,
+			received:     metrics.ClientStreamMessagesReceived,
+			sent:         metrics.ClientStreamMessagesSent,
+		},
+		client: true,
+	}
+}
+
+func (h *statsHandler) TagRPC(ctx context.Context, tag *stats.RPCTagInfo) context.Context {
+	ctx, span := h.startSpan(ctx, tag.FullMethodName)
+	info := &rpcInfo{method: tag.FullMethodName, span: span}
+	if h.requests != nil {
+		info.request = &trackedRequest{method: tag.FullMethodName, kind: "server"}
+		if h.client {
+			info.request.kind = "client"
+		}
+		if spanCtx := span.SpanContext(); spanCtx.HasTraceID() {
+			info.request.traceID = spanCtx.TraceID().String()
+		}
+	}
+	return context.WithValue(ctx, rpcInfoKey{}, info)
+}
+
+// startSpan starts the span of a call. Servers continue the trace read from
+// the incoming metadata; clients pass theirs on in the outgoing metadata, so
+// one trace follows a request through every service.
+func (h *statsHandler) startSpan(ctx context.Context, method string) (context.Context, trace.Span) {
+	kind := trace.SpanKindClient
+	if !h.client {
+		kind = trace.SpanKindServer
+		ctx = extractContext(ctx)
+	}
+
+	ctx, span := h.tracer.Start(ctx, method,
+		trace.WithSpanKind(kind),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "grpc"),
+			attribute.String("rpc.service", h.serviceName),
+			attribute.String("rpc.method", method),
+		),
+	)
+	// Servers only learn the request ID in their interceptors, which add it then
+	setContextAttributes(ctx, span, h.policy)
+	if h.client {
+		ctx = injectContext(ctx)
+	}
+	return ctx, span
+}
+
+// endSpan records the outcome of a call on its span and ends it
+func endSpan(span trace.Span, event *stats.End) {
+	code := status.Code(event.Error)
+	span.SetAttributes(attribute.Int64("rpc.grpc.status_code", int64(code)))
+	if event.Error != nil {
+		span.RecordError(event.Error)
+		span.SetStatus(codes.Error, event.Error.Error())
+	} else {
+		span.SetStatus(codes.Ok, "")
+	}
+	span.End(trace.WithTimestamp(event.EndTime))
+}
+
+func (h *statsHandler) HandleRPC(ctx context.Context, rs stats.RPCStats) {
+	info, ok := ctx.Value(rpcInfoKey{}).(*rpcInfo)
+	if !ok {
+		return
+	}
+
+	switch event := rs.(type) {
+	case *stats.Begin:
+		info.streaming.Store(event.IsClientStream || event.IsServerStream)
+		if info.streaming.Load() {
+			info.span.SetAttributes(attribute.Bool("rpc.streaming", true))
+		}
+		h.rpc.inFlight.WithLabelValues(info.method).Inc()
+		if info.request != nil {
+			info.request.start = event.BeginTime
+			h.requests.begin(info.request)
+		}
+	case *stats.End:
+		h.rpc.inFlight.WithLabelValues(info.method).Dec()
+		if info.request != nil {
+			h.requests.end(info.request, status.Code(event.Error).String(), event.EndTime)
+		}
+		endSpan(info.span, event)
+	case *stats.InHeader:
+		// Servers learn the caller's address from its headers
+		if event.RemoteAddr != nil {
+			h.setPeer(info, event.RemoteAddr.String())
+		}
+	case *stats.OutHeader:
+		if event.RemoteAddr != nil {
+			h.setPeer(info, event.RemoteAddr.String())
+		}
+	case *stats.InPayload:
+		// Servers receive requests and clients receive responses
+		size := h.rpc.requestSize
+		if h.client {
+			size = h.rpc.responseSize
+		}
+		size.WithLabelValues(info.method).Observe(float64(event.Length))
+		if info.streaming.Load() {
+			h.rpc.received.WithLabelValues(info.method).Inc()
+		}
+	case *stats.OutPayload:
+		size := h.rpc.responseSize
+		if h.client {
+			size = h.rpc.requestSize
+		}
+		size.WithLabelValues(info.method).Observe(float64(event.Length))
+		if info.streaming.Load() {
+			h.rpc.sent.WithLabelValues(info.method).Inc()
+		}
+	}
+}
+
+// setPeer records the address at the other end of a call
+func (h *statsHandler) setPeer(info *rpcInfo, peer string) {
+	info.span.SetAttributes(attribute.String("net.sock.peer.addr", peer))
+	if info.request != nil {
+		h.requests.setPeer(info.request, peer)
+	}
+}
+
+func (h *statsHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+// HandleConn counts open server connections; downstream connections are
+// counted per subchannel by the load balancer instead
+func (h *statsHandler) HandleConn(_ context.Context, cs stats.ConnStats) {
+	if h.client {
+		return
+	}
+	switch cs.(type) {
+	case *stats.ConnBegin:
+		h.metrics.ActiveConnections.Inc()
+	case *stats.ConnEnd:
+		h.metrics.ActiveConnections.Dec()
+	}
+}
@@ -0,0 +1,145 @@
+package observability
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const checkMethod = "/grpc.health.v1.Health/Check"
+
+// blockingHealthServer holds each Check until release is closed, and fails
+// checks of unknown services
+type blockingHealthServer struct {
+	healthpb.UnimplementedHealthServer
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingHealthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if req.Service != "" {
+		return nil, status.Error(codes.NotFound, "unknown service")
+	}
+	s.entered <- struct{}{}
+	<-s.release
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+// startInstrumented serves health on an in-memory listener with the stats
+// handlers and interceptors installed on both sides
+func startInstrumented(t *testing.T, health healthpb.HealthServer) (server, client *Metrics, conn *grpc.ClientConn) {
+	t.Helper()
+	logger := zap.NewNop()
+	server, client = NewMetrics("inventory-service"), NewMetrics("order-service")
+
+	grpcServer := grpc.NewServer(
+		grpc.StatsHandler(ServerStatsHandler("inventory-service", LogPolicy{}, server, nil)),
+		grpc.UnaryInterceptor(UnaryServerInterceptor(server, logger)),
+	)
+	healthpb.RegisterHealthServer(grpcServer, health)
+	listener := bufconn.Listen(1 << 20)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.Dial("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(ClientStatsHandler("order-service", LogPolicy{}, client, nil)),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(client, logger)),
+	)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return server, client, conn
+}
+
+// eventually polls until condition holds, as connection events arrive asynchronously
+func eventually(t *testing.T, condition func() bool) bool {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if condition() {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRequestMetricsByCode(t *testing.T) {
+	health := &blockingHealthServer{entered: make(chan struct{}, 1), release: make(chan struct{})}
+	close(health.release)
+	server, client, conn := startInstrumented(t, health)
+	healthClient := healthpb.NewHealthClient(conn)
+
+	if _, err := healthClient.Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if _, err := healthClient.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "unknown"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("Check(unknown) error = %v, want NotFound", err)
+	}
+
+	for _, code := range []codes.Code{codes.OK, codes.NotFound} {
+		if got := testutil.ToFloat64(server.RequestsTotal.WithLabelValues(checkMethod, code.String())); got != 1 {
+			t.Errorf("server requests_total{code=%q} = %v, want 1", code, got)
+		}
+		if got := testutil.ToFloat64(client.ClientRequestsTotal.WithLabelValues(checkMethod, code.String())); got != 1 {
+			t.Errorf("client_requests_total{code=%q} = %v, want 1", code, got)
+		}
+	}
+	if count := testutil.CollectAndCount(server.RequestSize); count != 1 {
+		t.Errorf("%d request_size_bytes series, want 1", count)
+	}
+	if count := testutil.CollectAndCount(client.ClientResponseSize); count != 1 {
+		t.Errorf("%d client_response_size_bytes series, want 1", count)
+	}
+}
+
+func TestInFlightAndConnectionGauges(t *testing.T) {
+	health := &blockingHealthServer{entered: make(chan struct{}), release: make(chan struct{})}
+	server, client, conn := startInstrumented(t, health)
+
+	done := make(chan error)
+	go func() {
+		_, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+		done <- err
+	}()
+	<-health.entered
+
+	if got := testutil.ToFloat64(server.RequestsInFlight.WithLabelValues(checkMethod)); got != 1 {
+		t.Errorf("requests_in_flight = %v during the call, want 1", got)
+	}
+	if got := testutil.ToFloat64(client.ClientRequestsInFlight.WithLabelValues(checkMethod)); got != 1 {
+		t.Errorf("client_requests_in_flight = %v during the call, want 1", got)
+	}
+	if got := testutil.ToFloat64(server.ActiveConnections); got != 1 {
+		t.Errorf("active_connections = %v, want 1", got)
+	}
+
+	close(health.release)
+	if err := <-done; err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if !eventually(t, func() bool { return testutil.ToFloat64(server.RequestsInFlight.WithLabelValues(checkMethod)) == 0 }) {
+		t.Error("requests_in_flight did not return to 0")
+	}
+	if got := testutil.ToFloat64(client.ClientRequestsInFlight.WithLabelValues(checkMethod)); got != 0 {
+		t.Errorf("client_requests_in_flight = %v after the call, want 0", got)
+	}
+
+	conn.Close()
+	if !eventually(t, func() bool { return testutil.ToFloat64(server.ActiveConnections) == 0 }) {
+		t.Error("active_connections did not return to 0 after the client disconnected")
+	}
+}
@@ -3,9 +3,14 @@ package observability
 import (
 	"context"
 	"fmt"
+	"os"
+	"strconv"
+	"strings"
 
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/exporters/jaeger"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
@@ -14,15 +19,130 @@ import (
 	"go.uber.org/zap"
 )
 
-// InitTracing initializes OpenTelemetry tracing
-func InitTracing(serviceName, jaegerEndpoint string, logger *zap.Logger) (func(), error) {
-	// Create Jaeger exporter
-	exp, err := jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(jaegerEndpoint)))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create Jaeger exporter: %w", err)
+// Trace buffer limits used when failed traces are kept regardless of sampling
+const (
+	keptTracesBuffer = 2048
+	keptSpansBuffer  = 256
+)
+
+// TracingConfig selects where spans are exported and which traces are sampled
+type TracingConfig struct {
+	// Exporter is "otlp", "stdout" (or "console") or "none"
+	Exporter string
+	// Protocol is the OTLP protocol, "grpc" or "http/protobuf". The endpoint,
+	// headers and TLS settings come from the standard OTEL_EXPORTER_OTLP_* variables.
+	Protocol string
+	// Sampler is "always_on", "always_off", "traceidratio" or "ratelimited",
+	// optionally prefixed with "parentbased_" to follow the caller's decision
+	Sampler string
+	// SamplerArg is the ratio for traceidratio and the traces per second for ratelimited
+	SamplerArg float64
+	// KeepErrors exports every trace with a failed span, even if it was not sampled
+	KeepErrors bool
+}
+
+// DefaultTracingConfig returns a config that exports every trace over OTLP gRPC
+func DefaultTracingConfig() TracingConfig {
+	return TracingConfig{
+		Exporter:   "otlp",
+		Protocol:   "grpc",
+		Sampler:    "parentbased_always_on",
+		SamplerArg: 1,
+	}
+}
+
+// TracingConfigFromEnv reads the tracing config from the standard
+// OTEL_TRACES_EXPORTER, OTEL_EXPORTER_OTLP_PROTOCOL, OTEL_TRACES_SAMPLER and
+// OTEL_TRACES_SAMPLER_ARG variables, and TRACE_KEEP_ERRORS
+func TracingConfigFromEnv() TracingConfig {
+	config := DefaultTracingConfig()
+	if value := os.Getenv("OTEL_TRACES_EXPORTER"); value != "" {
+		config.Exporter = value
+	}
+	if value := os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL"); value != "" {
+		config.Protocol = value
+	}
+	if value := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL"); value != "" {
+		config.Protocol = value
+	}
+	if value := os.Getenv("OTEL_TRACES_SAMPLER"); value != "" {
+		config.Sampler = value
 	}
+	if value, err := strconv.ParseFloat(os.Getenv("OTEL_TRACES_SAMPLER_ARG"), 64); err == nil {
+		config.SamplerArg = value
+	}
+	if value, err := strconv.ParseBool(os.Getenv("TRACE_KEEP_ERRORS")); err == nil {
+		config.KeepErrors = value
+	}
+	return config
+}
+
+// newSampler builds the sampler named by the config
+func newSampler(config TracingConfig) (sdktrace.Sampler, error) {
+	name := strings.ToLower(config.Sampler)
+	parentBased := strings.HasPrefix(name, "parentbased_")
+	name = strings.TrimPrefix(name, "parentbased_")
+
+	var sampler sdktrace.Sampler
+	switch name {
+	case "always_on":
+		sampler = sdktrace.AlwaysSample()
+	case "always_off":
+		sampler = sdktrace.NeverSample()
+	case "traceidratio":
+		if config.SamplerArg < 0 || config.SamplerArg > 1 {
+			return nil, fmt.Errorf("traceidratio sampler needs a ratio between 0 and 1, got %v", config.SamplerArg)
+		}
+		sampler = sdktrace.TraceIDRatioBased(config.SamplerArg)
+	case "ratelimited":
+		if config.SamplerArg <= 0 {
+			return nil, fmt.Errorf("ratelimited sampler needs a positive rate, got %v", config.SamplerArg)
+		}
+		sampler = RateLimitedSampler(config.SamplerArg)
+	default:
+		return nil, fmt.Errorf("unknown sampler %q", config.Sampler)
+	}
+
+	if parentBased {
+		sampler = sdktrace.ParentBased(sampler)
+	}
+	return sampler, nil
+}
 
-	// Create resource with service information
+// checkExporter reports whether the config names a known exporter and protocol
+func checkExporter(config TracingConfig) error {
+	switch strings.ToLower(config.Exporter) {
+	case "otlp":
+		switch strings.ToLower(config.Protocol) {
+		case "grpc", "http/protobuf", "http":
+			return nil
+		}
+		return fmt.Errorf("unknown OTLP protocol %q", config.Protocol)
+	case "stdout", "console", "none":
+		return nil
+	}
+	return fmt.Errorf("unknown exporter %q", config.Exporter)
+}
+
+// newExporter builds the exporter named by a checked config; it returns nil for "none"
+func newExporter(ctx context.Context, config TracingConfig) (sdktrace.SpanExporter, error) {
+	switch strings.ToLower(config.Exporter) {
+	case "otlp":
+		switch strings.ToLower(config.Protocol) {
+		case "grpc":
+			return otlptracegrpc.New(ctx)
+		default:
+			return otlptracehttp.New(ctx)
+		}
+	case "stdout", "console":
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return nil, nil
+	}
+}
+
+// newResource describes the service to tracing and metrics backends
+func newResource(serviceName string, logger *zap.Logger) *resource.Resource {
 	res, err := resource.New(
 		context.Background(),
 		resource.WithAttributes(
@@ -36,15 +156,45 @@ func InitTracing(serviceName, jaegerEndpoint string, logger *zap.Logger) (func()
 		resource.WithHost(),
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create resource: %w", err)
+		// A partial resource is still usable
+		logger.Warn("Failed to detect some resource attributes", zap.Error(err))
 	}
+	return res
+}
+
+// InitTracing initializes OpenTelemetry tracing. It fails only on an invalid
+// config; an exporter that cannot be created, e.g. with no collector
+// configured, is logged and tracing carries on without exporting.
+func InitTracing(serviceName string, config TracingConfig, logger *zap.Logger) (func(), error) {
+	sampler, err := newSampler(config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tracing config: %w", err)
+	}
+
+	if err := checkExporter(config); err != nil {
+		return nil, fmt.Errorf("invalid tracing config: %w", err)
+	}
+
+	exp, err := newExporter(context.Background(), config)
+	if err != nil {
+		logger.Warn("Failed to create trace exporter, spans will not be exported",
+			zap.String("exporter", config.Exporter), zap.Error(err))
+		exp = nil
+	}
+
+	opts := []sdktrace.TracerProviderOption{sdktrace.WithResource(newResource(serviceName, logger))}
+	if exp != nil {
+		var processor sdktrace.SpanProcessor = sdktrace.NewBatchSpanProcessor(exp)
+		if config.KeepErrors {
+			processor = newErrorKeepingProcessor(processor, keptTracesBuffer, keptSpansBuffer)
+			sampler = recordAllSampler{Sampler: sampler}
+		}
+		opts = append(opts, sdktrace.WithSpanProcessor(processor))
+	}
+	opts = append(opts, sdktrace.WithSampler(sampler))
 
 	// Create trace provider
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exp),
-		sdktrace.WithResource(res),
-		sdktrace.WithSampler(sdktrace.AlwaysSample()),
-	)
+	tp := sdktrace.NewTracerProvider(opts...)
 
 	// Set global trace provider
 	otel.SetTracerProvider(tp)
@@ -55,7 +205,11 @@ func InitTracing(serviceName, jaegerEndpoint string, logger *zap.Logger) (func()
 		propagation.Baggage{},
 	))
 
-	logger.Info("Tracing initialized", zap.String("service", serviceName), zap.String("jaeger_endpoint", jaegerEndpoint))
+	logger.Info("Tracing initialized",
+		zap.String("service", serviceName),
+		zap.String("exporter", config.Exporter),
+		zap.String("sampler", sampler.Description()),
+		zap.Bool("keep_errors", config.KeepErrors))
 
 	// Return cleanup function
 	return func() {
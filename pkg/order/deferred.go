@@ -0,0 +1,241 @@
+package order
+
+import (
+	"context"
+	"time"
+
+	"github.com/your-org/order-processing-system/pkg/observability"
+	orderpb "github.com/your-org/order-processing-system/pkg/pb/order"
+	paymentpb "github.com/your-org/order-processing-system/pkg/pb/payment"
+	"github.com/your-org/order-processing-system/pkg/resilience"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DeferredPaymentConfig controls accepting orders whose payment cannot be taken yet
+type DeferredPaymentConfig struct {
+	// Enabled accepts orders in PENDING_PAYMENT while the payment service is unavailable
+	Enabled bool
+	// MaxAttempts is the number of background charge attempts before the order is cancelled
+	MaxAttempts int
+	// InitialBackoff is the wait before the first background attempt; it doubles
+	// after every attempt up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// PollInterval is how often the worker looks for orders due an attempt
+	PollInterval time.Duration
+	// AttemptTimeout bounds each background attempt, of which the payment
+	// call gets the Deadlines.PaymentShare
+	AttemptTimeout time.Duration
+}
+
+// DefaultDeferredPaymentConfig returns the retry schedule used for deferred
+// payments, which gives the payment service about six minutes to recover
+func DefaultDeferredPaymentConfig() DeferredPaymentConfig {
+	return DeferredPaymentConfig{
+		MaxAttempts:    8,
+		InitialBackoff: 5 * time.Second,
+		MaxBackoff:     2 * time.Minute,
+		PollInterval:   time.Second,
+		AttemptTimeout: 10 * time.Second,
+	}
+}
+
+// withDefaults fills in unset fields, keeping Enabled as given
+func (c DeferredPaymentConfig) withDefaults() DeferredPaymentConfig {
+	defaults := DefaultDeferredPaymentConfig()
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = defaults.MaxAttempts
+	}
+	if c.InitialBackoff <= 0 {
+		c.InitialBackoff = defaults.InitialBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = defaults.MaxBackoff
+	}
+	if c.PollInterval <= 0 {
+		c.PollInterval = defaults.PollInterval
+	}
+	if c.AttemptTimeout <= 0 {
+		c.AttemptTimeout = defaults.AttemptTimeout
+	}
+	return c
+}
+
+// deferredPayment is a payment waiting for the payment service to come back
+type deferredPayment struct {
+	request *paymentpb.PaymentRequest
+	// business is the business context of the request that created the order,
+	// passed on again with every attempt
+	business    observability.BusinessContext
+	attempts    int
+	backoff     time.Duration
+	nextAttempt time.Time
+}
+
+// paymentUnavailable reports whether a payment call failed because the
+// payment service could not take it right now. The call may still have
+// reached the service, but trying again cannot bill twice: the payment
+// service charges each order ID once and answers repeats with the original
+// outcome, or with Aborted while that charge is still in progress. Timeouts
+// are not deferred, as the order cannot wait that long for an answer.
+func paymentUnavailable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.Aborted:
+		return true
+	}
+	return false
+}
+
+// deferrable reports whether a payment may wait in memory for a later
+// attempt. Card details are never held: the CVV must not be kept past
+// authorization, so only saved cards, paid by token, are deferred.
+func deferrable(method *paymentpb.PaymentMethod) bool {
+	return method.GetCard() == nil
+}
+
+// deferPayment stores an order as PENDING_PAYMENT, with its stock still held,
+// and queues its payment for the retry worker
+func (s *service) deferPayment(ctx context.Context, order *orderpb.Order, paymentReq *paymentpb.PaymentRequest) {
+	backoff := s.config.DeferredPayments.InitialBackoff
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	order.Status = orderpb.OrderStatus_ORDER_STATUS_PENDING_PAYMENT
+	order.UpdatedAt = time.Now().Format(time.RFC3339)
+	s.orders[order.Id] = order
+	s.deferredPayments[order.Id] = &deferredPayment{
+		request:     paymentReq,
+		business:    observability.BusinessContextFromContext(ctx),
+		backoff:     backoff,
+		nextAttempt: time.Now().Add(backoff),
+	}
+
+	s.metrics.DeferredPayments.WithLabelValues("deferred").Inc()
+	s.metrics.PendingPaymentOrders.Set(float64(len(s.deferredPayments)))
+}
+
+// RetryDeferredPayments charges orders accepted in PENDING_PAYMENT until ctx
+// is done. Orders whose payment succeeds move to PROCESSING; orders whose
+// payment is declined, fails outright or is still unavailable after the last
+// attempt are cancelled and their stock released. It returns at once if
+// deferred payments are disabled.
+func (s *service) RetryDeferredPayments(ctx context.Context) {
+	if !s.config.DeferredPayments.Enabled {
+		return
+	}
+
+	ticker := time.NewTicker(s.config.DeferredPayments.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, orderID := range s.duePayments(time.Now()) {
+			if ctx.Err() != nil {
+				return
+			}
+			s.retryPayment(ctx, orderID)
+		}
+	}
+}
+
+// duePayments returns the orders whose next attempt is due
+func (s *service) duePayments(now time.Time) []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var due []string
+	for orderID, deferred := range s.deferredPayments {
+		if !now.Before(deferred.nextAttempt) {
+			due = append(due, orderID)
+		}
+	}
+	return due
+}
+
+// retryPayment makes one attempt at charging a deferred order
+func (s *service) retryPayment(ctx context.Context, orderID string) {
+	s.mutex.Lock()
+	order, deferred := s.orders[orderID], s.deferredPayments[orderID]
+	if order == nil || deferred == nil {
+		s.mutex.Unlock()
+		return
+	}
+	deferred.attempts++
+	attempts := deferred.attempts
+	s.mutex.Unlock()
+
+	ctx = observability.ContextWithBusinessContext(ctx, deferred.business)
+	logger := s.logger.With(zap.String("order_id", orderID), zap.Int("attempt", attempts))
+
+	// The worker's context has no deadline, so each attempt gets its own
+	attemptCtx, cancelAttempt := context.WithTimeout(ctx, s.config.DeferredPayments.AttemptTimeout)
+	defer cancelAttempt()
+	paymentCtx, cancelPayment := resilience.StepContext(attemptCtx, s.config.Deadlines.PaymentShare, s.config.Deadlines.MinStep)
+	defer cancelPayment()
+
+	paymentResp, err := s.paymentClient.ProcessPayment(paymentCtx, deferred.request)
+	switch {
+	case err == nil && paymentResp.Status == paymentpb.PaymentStatus_PAYMENT_STATUS_SUCCESS:
+		logger.Info("Deferred payment succeeded", zap.String("payment_id", paymentResp.PaymentId))
+		s.resolveDeferred(order, orderpb.OrderStatus_ORDER_STATUS_PROCESSING, "charged")
+
+	case err == nil:
+		logger.Warn("Deferred payment declined", zap.String("message", paymentResp.Message))
+		s.cancelDeferred(ctx, order, "declined")
+
+	case ctx.Err() != nil:
+		// Shutting down; the attempt does not count
+		s.mutex.Lock()
+		deferred.attempts--
+		s.mutex.Unlock()
+
+	// Unlike a caller, the worker can wait for an attempt that timed out, and
+	// trying again cannot bill twice, so a timeout is retried like unavailability
+	case !paymentUnavailable(err) && status.Code(err) != codes.DeadlineExceeded:
+		logger.Error("Deferred payment failed", zap.Error(err))
+		s.cancelDeferred(ctx, order, "failed")
+
+	case attempts >= s.config.DeferredPayments.MaxAttempts:
+		logger.Error("Payment service still unavailable after last attempt, cancelling order", zap.Error(err))
+		s.cancelDeferred(ctx, order, "exhausted")
+
+	default:
+		s.mutex.Lock()
+		deferred.backoff *= 2
+		if deferred.backoff > s.config.DeferredPayments.MaxBackoff {
+			deferred.backoff = s.config.DeferredPayments.MaxBackoff
+		}
+		backoff := deferred.backoff
+		deferred.nextAttempt = time.Now().Add(backoff)
+		s.mutex.Unlock()
+
+		logger.Warn("Payment service still unavailable, will retry", zap.Duration("backoff", backoff), zap.Error(err))
+	}
+}
+
+// cancelDeferred gives up on a deferred order, releasing its stock
+func (s *service) cancelDeferred(ctx context.Context, order *orderpb.Order, outcome string) {
+	s.releaseStockForOrder(ctx, order.Id, order.Items)
+	s.resolveDeferred(order, orderpb.OrderStatus_ORDER_STATUS_CANCELLED, outcome)
+}
+
+// resolveDeferred moves a deferred order to its final status and forgets its payment
+func (s *service) resolveDeferred(order *orderpb.Order, status orderpb.OrderStatus, outcome string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	order.Status = status
+	order.UpdatedAt = time.Now().Format(time.RFC3339)
+	delete(s.deferredPayments, order.Id)
+
+	s.metrics.DeferredPayments.WithLabelValues(outcome).Inc()
+	s.metrics.PendingPaymentOrders.Set(float64(len(s.deferredPayments)))
+}
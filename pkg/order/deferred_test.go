@@ -0,0 +1,208 @@
+package order
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/your-org/order-processing-system/pkg/observability"
+	orderpb "github.com/your-org/order-processing-system/pkg/pb/order"
+	paymentpb "github.com/your-org/order-processing-system/pkg/pb/payment"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakePaymentClient answers ProcessPayment with err while it is set and
+// approves the payment otherwise
+type fakePaymentClient struct {
+	paymentpb.PaymentServiceClient
+
+	mutex sync.Mutex
+	err   error
+	calls int
+	// hang makes calls wait for their deadline
+	hang      bool
+	deadlines []time.Time
+}
+
+func (c *fakePaymentClient) ProcessPayment(ctx context.Context, req *paymentpb.PaymentRequest, opts ...grpc.CallOption) (*paymentpb.PaymentResponse, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.calls++
+	deadline, _ := ctx.Deadline()
+	c.deadlines = append(c.deadlines, deadline)
+	if c.hang {
+		<-ctx.Done()
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &paymentpb.PaymentResponse{PaymentId: "payment-1", Status: paymentpb.PaymentStatus_PAYMENT_STATUS_SUCCESS}, nil
+}
+
+func (c *fakePaymentClient) setErr(err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.err = err
+}
+
+func newDeferringService(inventory *fakeInventoryClient, payment *fakePaymentClient) *service {
+	config := DeferredPaymentConfig{Enabled: true, MaxAttempts: 3}
+	return &service{
+		orders:           make(map[string]*orderpb.Order),
+		logger:           zap.NewNop(),
+		metrics:          observability.NewMetrics("order-service"),
+		inventoryClient:  inventory,
+		paymentClient:    payment,
+		pendingPayments:  make(map[string]*paymentpb.PaymentRequest),
+		deferredPayments: make(map[string]*deferredPayment),
+		config: Config{
+			Deadlines:        DefaultDeadlineBudget(),
+			DeferredPayments: config.withDefaults(),
+		},
+	}
+}
+
+func paymentRequest(method *paymentpb.PaymentMethod) *paymentpb.PaymentRequest {
+	return &paymentpb.PaymentRequest{OrderId: "order-1", CustomerId: "customer-1", Amount: 10, Currency: "USD", Method: method}
+}
+
+func tokenMethod() *paymentpb.PaymentMethod {
+	return &paymentpb.PaymentMethod{Method: &paymentpb.PaymentMethod_Token{Token: "pm_1"}}
+}
+
+func TestChargeOrderDefersTokenPayments(t *testing.T) {
+	for _, code := range []codes.Code{codes.Unavailable, codes.Aborted} {
+		inventory := &fakeInventoryClient{}
+		s := newDeferringService(inventory, &fakePaymentClient{err: status.Error(code, "payment down")})
+		order := &orderpb.Order{Id: "order-1", Items: orderItems("product-1")}
+
+		deferred, err := s.chargeOrder(context.Background(), order, paymentRequest(tokenMethod()))
+		if err != nil || !deferred {
+			t.Fatalf("%v: chargeOrder() = %v, %v, want deferred", code, deferred, err)
+		}
+		if order.Status != orderpb.OrderStatus_ORDER_STATUS_PENDING_PAYMENT {
+			t.Errorf("%v: order status = %v, want PENDING_PAYMENT", code, order.Status)
+		}
+		if s.deferredPayments["order-1"] == nil {
+			t.Errorf("%v: payment was not queued", code)
+		}
+		if len(inventory.released) != 0 {
+			t.Errorf("%v: released %v, want stock held", code, inventory.released)
+		}
+	}
+}
+
+func TestChargeOrderNeverDefersCards(t *testing.T) {
+	inventory := &fakeInventoryClient{}
+	s := newDeferringService(inventory, &fakePaymentClient{err: status.Error(codes.Unavailable, "payment down")})
+	order := &orderpb.Order{Id: "order-1", Items: orderItems("product-1")}
+	card := &paymentpb.PaymentMethod{Method: &paymentpb.PaymentMethod_Card{Card: &paymentpb.CardDetails{Number: "4111111111111111", Cvv: "123"}}}
+
+	deferred, err := s.chargeOrder(context.Background(), order, paymentRequest(card))
+	if deferred || status.Code(err) != codes.Unavailable {
+		t.Fatalf("chargeOrder() = %v, %v, want Unavailable", deferred, err)
+	}
+	if len(s.deferredPayments) != 0 {
+		t.Errorf("card payment was queued")
+	}
+	if len(inventory.released) != 1 {
+		t.Errorf("released %v, want the order's stock", inventory.released)
+	}
+}
+
+func TestRetryPayment(t *testing.T) {
+	inventory := &fakeInventoryClient{}
+	payment := &fakePaymentClient{err: status.Error(codes.Unavailable, "payment down")}
+	s := newDeferringService(inventory, payment)
+	order := &orderpb.Order{Id: "order-1", Items: orderItems("product-1")}
+	if deferred, err := s.chargeOrder(context.Background(), order, paymentRequest(tokenMethod())); !deferred {
+		t.Fatalf("chargeOrder() = %v, %v, want deferred", deferred, err)
+	}
+
+	s.retryPayment(context.Background(), "order-1")
+	deferred := s.deferredPayments["order-1"]
+	if deferred.attempts != 1 || deferred.backoff != 2*s.config.DeferredPayments.InitialBackoff {
+		t.Errorf("after unavailable attempt: attempts = %d, backoff = %v", deferred.attempts, deferred.backoff)
+	}
+
+	payment.setErr(nil)
+	s.retryPayment(context.Background(), "order-1")
+	if order.Status != orderpb.OrderStatus_ORDER_STATUS_PROCESSING {
+		t.Errorf("order status = %v, want PROCESSING", order.Status)
+	}
+	if len(s.deferredPayments) != 0 || len(inventory.released) != 0 {
+		t.Errorf("deferred = %v, released = %v, want neither", s.deferredPayments, inventory.released)
+	}
+}
+
+func TestRetryPaymentCancelsWhenExhausted(t *testing.T) {
+	inventory := &fakeInventoryClient{}
+	s := newDeferringService(inventory, &fakePaymentClient{err: status.Error(codes.Unavailable, "payment down")})
+	order := &orderpb.Order{Id: "order-1", Items: orderItems("product-1")}
+	s.chargeOrder(context.Background(), order, paymentRequest(tokenMethod()))
+
+	for i := 0; i < s.config.DeferredPayments.MaxAttempts; i++ {
+		s.retryPayment(context.Background(), "order-1")
+	}
+	if order.Status != orderpb.OrderStatus_ORDER_STATUS_CANCELLED {
+		t.Errorf("order status = %v, want CANCELLED", order.Status)
+	}
+	if len(inventory.released) != 1 {
+		t.Errorf("released %v, want the order's stock", inventory.released)
+	}
+}
+
+func TestRetryPaymentIsSafeAlongsideTheWorker(t *testing.T) {
+	payment := &fakePaymentClient{err: status.Error(codes.Unavailable, "payment down")}
+	s := newDeferringService(&fakeInventoryClient{}, payment)
+	s.chargeOrder(context.Background(), &orderpb.Order{Id: "order-1", Items: orderItems("product-1")}, paymentRequest(tokenMethod()))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			s.retryPayment(context.Background(), "order-1")
+		}()
+		go func() {
+			defer wg.Done()
+			s.duePayments(time.Now().Add(time.Hour))
+		}()
+	}
+	wg.Wait()
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if attempts := s.deferredPayments["order-1"].attempts; attempts != 2 {
+		t.Errorf("attempts = %d, want 2", attempts)
+	}
+}
+
+func TestRetryPaymentBoundsEachAttempt(t *testing.T) {
+	payment := &fakePaymentClient{err: status.Error(codes.Unavailable, "payment down")}
+	s := newDeferringService(&fakeInventoryClient{}, payment)
+	s.config.DeferredPayments.AttemptTimeout = 20 * time.Millisecond
+	order := &orderpb.Order{Id: "order-1", Items: orderItems("product-1")}
+	s.chargeOrder(context.Background(), order, paymentRequest(tokenMethod()))
+
+	payment.hang = true
+	start := time.Now()
+	s.retryPayment(context.Background(), "order-1")
+
+	deadline := payment.deadlines[len(payment.deadlines)-1]
+	if deadline.IsZero() || deadline.Sub(start) > 30*time.Millisecond {
+		t.Errorf("attempt deadline = %v, want within the 20ms attempt timeout", deadline)
+	}
+	// A timed-out attempt is retried rather than cancelling the order
+	if order.Status != orderpb.OrderStatus_ORDER_STATUS_PENDING_PAYMENT {
+		t.Errorf("order status = %v, want PENDING_PAYMENT", order.Status)
+	}
+	if deferred := s.deferredPayments["order-1"]; deferred == nil || deferred.attempts != 1 {
+		t.Errorf("deferred = %+v, want one attempt counted", deferred)
+	}
+}
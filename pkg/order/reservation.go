@@ -0,0 +1,100 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	inventrypb "github.com/your-org/order-processing-system/pkg/pb/inventory"
+	orderpb "github.com/your-org/order-processing-system/pkg/pb/order"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultReserveConcurrency is the number of ReserveStock calls an order keeps in flight
+const DefaultReserveConcurrency = 8
+
+// ReserveItems reserves stock for every line of an order with at most
+// concurrency calls in flight. The first failure cancels the calls still in
+// flight and stops new ones from being sent.
+//
+// It returns the lines whose stock may be held, which must be released if the
+// order does not go ahead: every line that was reserved, plus lines whose call
+// ended without a definite answer, such as calls cancelled by the first
+// failure. Lines that were rejected or never sent are not returned. On failure
+// it also returns the line that failed first.
+func ReserveItems(ctx context.Context, client inventrypb.InventoryServiceClient, orderID string, items []*orderpb.OrderItem, concurrency int) (held []*orderpb.OrderItem, failed *orderpb.OrderItem, err error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg    sync.WaitGroup
+		mutex sync.Mutex
+	)
+	holds := make([]bool, len(items))
+	slots := make(chan struct{}, concurrency)
+	sent := 0
+
+dispatch:
+	for i, item := range items {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			break dispatch
+		}
+		// A slot and a cancellation can be ready at once
+		if ctx.Err() != nil {
+			break
+		}
+
+		sent++
+		wg.Add(1)
+		go func(i int, item *orderpb.OrderItem) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			_, callErr := client.ReserveStock(ctx, &inventrypb.ReserveStockRequest{
+				ProductId: item.ProductId,
+				Quantity:  item.Quantity,
+				OrderId:   orderID,
+			})
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			holds[i] = callErr == nil || outcomeUnknown(callErr)
+			if callErr != nil && err == nil {
+				failed, err = item, callErr
+				cancel()
+			}
+		}(i, item)
+	}
+	wg.Wait()
+
+	// The caller gave up before every line was sent
+	if err == nil && sent < len(items) {
+		err = ctx.Err()
+	}
+
+	for i, item := range items {
+		if holds[i] {
+			held = append(held, item)
+		}
+	}
+	return held, failed, err
+}
+
+// outcomeUnknown reports whether a failed call may still have been applied by the server
+func outcomeUnknown(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	switch status.Code(err) {
+	case codes.Canceled, codes.DeadlineExceeded, codes.Unknown, codes.Internal:
+		return true
+	}
+	return false
+}
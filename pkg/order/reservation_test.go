@@ -0,0 +1,178 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	inventrypb "github.com/your-org/order-processing-system/pkg/pb/inventory"
+	orderpb "github.com/your-org/order-processing-system/pkg/pb/order"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeInventoryClient answers ReserveStock after a fixed latency; it embeds
+// the client interface only to satisfy the methods the tests never call
+type fakeInventoryClient struct {
+	inventrypb.InventoryServiceClient
+	latency time.Duration
+	// errors fails the reservation of a product
+	errors map[string]error
+	// blocked products only answer once their call is cancelled
+	blocked map[string]bool
+
+	mutex    sync.Mutex
+	calls    []string
+	released []string
+}
+
+func (c *fakeInventoryClient) ReserveStock(ctx context.Context, req *inventrypb.ReserveStockRequest, opts ...grpc.CallOption) (*inventrypb.ReserveStockResponse, error) {
+	c.mutex.Lock()
+	c.calls = append(c.calls, req.ProductId)
+	c.mutex.Unlock()
+
+	if c.blocked[req.ProductId] {
+		<-ctx.Done()
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	if c.latency > 0 {
+		timer := time.NewTimer(c.latency)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		case <-timer.C:
+		}
+	}
+	if err := c.errors[req.ProductId]; err != nil {
+		return nil, err
+	}
+	return &inventrypb.ReserveStockResponse{Success: true, ReservedQuantity: req.Quantity}, nil
+}
+
+func (c *fakeInventoryClient) ReleaseStock(ctx context.Context, req *inventrypb.ReleaseStockRequest, opts ...grpc.CallOption) (*inventrypb.ReleaseStockResponse, error) {
+	c.mutex.Lock()
+	c.released = append(c.released, req.ProductId)
+	c.mutex.Unlock()
+	return &inventrypb.ReleaseStockResponse{Success: true}, nil
+}
+
+func orderItems(productIDs ...string) []*orderpb.OrderItem {
+	items := make([]*orderpb.OrderItem, len(productIDs))
+	for i, productID := range productIDs {
+		items[i] = &orderpb.OrderItem{ProductId: productID, Quantity: 1}
+	}
+	return items
+}
+
+func productIDs(items []*orderpb.OrderItem) []string {
+	var ids []string
+	for _, item := range items {
+		ids = append(ids, item.ProductId)
+	}
+	return ids
+}
+
+func TestReserveItems(t *testing.T) {
+	client := &fakeInventoryClient{}
+	items := orderItems("product-1", "product-2", "product-3", "product-4", "product-5")
+
+	held, failed, err := ReserveItems(context.Background(), client, "order-1", items, 2)
+	if err != nil || failed != nil {
+		t.Fatalf("ReserveItems() failed %v with %v", failed, err)
+	}
+	if got := fmt.Sprint(productIDs(held)); got != fmt.Sprint(productIDs(items)) {
+		t.Errorf("held = %s, want every line", got)
+	}
+}
+
+func TestReserveItemsHoldsOnlyWhatMayBeReserved(t *testing.T) {
+	rejected := status.Error(codes.ResourceExhausted, "insufficient stock")
+	client := &fakeInventoryClient{
+		errors:  map[string]error{"product-2": rejected},
+		blocked: map[string]bool{"product-1": true},
+	}
+	// product-1 is in flight when product-2 is rejected, so its outcome is
+	// unknown; product-3 waits for a slot and is never sent
+	items := orderItems("product-1", "product-2", "product-3")
+
+	held, failed, err := ReserveItems(context.Background(), client, "order-1", items, 2)
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("ReserveItems() error = %v, want ResourceExhausted", err)
+	}
+	if failed.GetProductId() != "product-2" {
+		t.Errorf("failed = %v, want product-2", failed)
+	}
+	if got := productIDs(held); len(got) != 1 || got[0] != "product-1" {
+		t.Errorf("held = %v, want [product-1]", got)
+	}
+	for _, productID := range client.calls {
+		if productID == "product-3" {
+			t.Error("product-3 was reserved after the order failed")
+		}
+	}
+}
+
+func TestReserveItemsRejectedLinesAreNotHeld(t *testing.T) {
+	for _, code := range []codes.Code{codes.NotFound, codes.ResourceExhausted, codes.FailedPrecondition, codes.Unavailable} {
+		client := &fakeInventoryClient{errors: map[string]error{"product-1": status.Error(code, "rejected")}}
+
+		held, _, err := ReserveItems(context.Background(), client, "order-1", orderItems("product-1"), 1)
+		if status.Code(err) != code {
+			t.Errorf("%v: ReserveItems() error = %v", code, err)
+		}
+		if len(held) != 0 {
+			t.Errorf("%v: held = %v, want none", code, productIDs(held))
+		}
+	}
+
+	for _, code := range []codes.Code{codes.DeadlineExceeded, codes.Canceled, codes.Unknown, codes.Internal} {
+		client := &fakeInventoryClient{errors: map[string]error{"product-1": status.Error(code, "no answer")}}
+
+		held, _, _ := ReserveItems(context.Background(), client, "order-1", orderItems("product-1"), 1)
+		if len(held) != 1 {
+			t.Errorf("%v: held = %v, want [product-1]", code, productIDs(held))
+		}
+	}
+}
+
+func TestReserveItemsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	client := &fakeInventoryClient{}
+
+	held, failed, err := ReserveItems(ctx, client, "order-1", orderItems("product-1", "product-2"), 1)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("ReserveItems() error = %v, want context.Canceled", err)
+	}
+	if failed != nil || len(held) != 0 || len(client.calls) != 0 {
+		t.Errorf("ReserveItems() = held %v, failed %v after %d calls, want nothing", productIDs(held), failed, len(client.calls))
+	}
+}
+
+// BenchmarkReserveItems compares sequential and concurrent reservation as the
+// number of order lines grows, against an inventory service that answers in 1ms
+func BenchmarkReserveItems(b *testing.B) {
+	client := &fakeInventoryClient{latency: time.Millisecond}
+
+	for _, count := range []int{1, 2, 5, 10, 25, 50} {
+		items := make([]*orderpb.OrderItem, count)
+		for i := range items {
+			items[i] = &orderpb.OrderItem{ProductId: fmt.Sprintf("product-%d", i), Quantity: 1}
+		}
+
+		for _, concurrency := range []int{1, DefaultReserveConcurrency} {
+			b.Run(fmt.Sprintf("items=%d/concurrency=%d", count, concurrency), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if _, _, err := ReserveItems(context.Background(), client, "bench-order", items, concurrency); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -2,23 +2,87 @@ package order
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/your-org/order-processing-system/pkg/apierrors"
+	"github.com/your-org/order-processing-system/pkg/currency"
+	"github.com/your-org/order-processing-system/pkg/fraud"
+	"github.com/your-org/order-processing-system/pkg/health"
+	"github.com/your-org/order-processing-system/pkg/observability"
+	"github.com/your-org/order-processing-system/pkg/payment"
+	"github.com/your-org/order-processing-system/pkg/resilience"
 	orderpb "github.com/your-org/order-processing-system/pkg/pb/order"
 	inventrypb "github.com/your-org/order-processing-system/pkg/pb/inventory"
 	paymentpb "github.com/your-org/order-processing-system/pkg/pb/payment"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// DefaultCurrency is used for orders that do not specify a currency
+const DefaultCurrency = "USD"
+
 // Service defines the core order service interface
 type Service interface {
-	CreateOrder(ctx context.Context, customerID string, items []*orderpb.OrderItem) (*orderpb.Order, error)
+	CreateOrder(ctx context.Context, req *orderpb.CreateOrderRequest) (*orderpb.Order, error)
 	GetOrder(ctx context.Context, orderID string) (*orderpb.Order, error)
 	UpdateOrderStatus(ctx context.Context, orderID string, status orderpb.OrderStatus) (*orderpb.Order, error)
+	ConvertTotal(ctx context.Context, order *orderpb.Order, currencyCode string) (float64, error)
+	ReviewOrder(ctx context.Context, orderID string, approve bool, reviewer string) (*orderpb.Order, error)
+	Ping(ctx context.Context) error
+	RetryDeferredPayments(ctx context.Context)
+}
+
+// fraudDecisions maps screening actions to their order record representation
+var fraudDecisions = map[fraud.Action]orderpb.FraudDecision{
+	fraud.ActionApprove: orderpb.FraudDecision_FRAUD_DECISION_APPROVE,
+	fraud.ActionReview:  orderpb.FraudDecision_FRAUD_DECISION_REVIEW,
+	fraud.ActionReject:  orderpb.FraudDecision_FRAUD_DECISION_REJECT,
+}
+
+// Config holds order service settings
+type Config struct {
+	// SettlementCurrency is the currency order totals are settled in
+	SettlementCurrency string
+	// PaymentBreaker, when set, lets CreateOrder fail fast while payment is unavailable
+	PaymentBreaker *resilience.CircuitBreaker
+	// Deadlines splits the caller's deadline across the steps of CreateOrder
+	Deadlines DeadlineBudget
+	// ReserveConcurrency bounds the ReserveStock calls one order keeps in flight
+	ReserveConcurrency int
+	// DeferredPayments, when enabled, accepts orders while the payment service
+	// is unavailable and charges them in the background
+	DeferredPayments DeferredPaymentConfig
+}
+
+// DeadlineBudget controls how much of the remaining deadline each saga step may use,
+// so a slow step fails on its own rather than starving the ones after it
+type DeadlineBudget struct {
+	// ReserveShare is the fraction of the remaining time given to stock reservation
+	ReserveShare float64
+	// PaymentShare is the fraction of the time left after reservation given to payment
+	PaymentShare float64
+	// MinStep is the least share a step is given when its fraction of the
+	// remaining time is shorter; a step still ends at the caller's deadline
+	MinStep time.Duration
+	// CompensationTimeout bounds stock releases, which run even after the caller's deadline
+	CompensationTimeout time.Duration
+}
+
+// DefaultDeadlineBudget returns the deadline split used when none is configured
+func DefaultDeadlineBudget() DeadlineBudget {
+	return DeadlineBudget{
+		ReserveShare:        0.4,
+		PaymentShare:        0.9,
+		MinStep:             50 * time.Millisecond,
+		CompensationTimeout: 5 * time.Second,
+	}
 }
 
 // service implements the Service interface
@@ -26,101 +90,377 @@ type service struct {
 	orders           map[string]*orderpb.Order
 	mutex            sync.RWMutex
 	logger           *zap.Logger
+	metrics          *observability.Metrics
 	inventoryClient  inventrypb.InventoryServiceClient
 	paymentClient    paymentpb.PaymentServiceClient
+	rates            currency.RateProvider
+	screener         fraud.Screener
+	pendingPayments  map[string]*paymentpb.PaymentRequest
+	deferredPayments map[string]*deferredPayment
+	config           Config
 }
 
 // NewService creates a new order service instance
-func NewService(logger *zap.Logger, inventoryConn, paymentConn *grpc.ClientConn) Service {
+func NewService(logger *zap.Logger, metrics *observability.Metrics, inventoryConn, paymentConn *grpc.ClientConn, rates currency.RateProvider, screener fraud.Screener, config Config) Service {
+	if config.SettlementCurrency == "" {
+		config.SettlementCurrency = DefaultCurrency
+	}
+	if config.Deadlines == (DeadlineBudget{}) {
+		config.Deadlines = DefaultDeadlineBudget()
+	}
+	if config.ReserveConcurrency <= 0 {
+		config.ReserveConcurrency = DefaultReserveConcurrency
+	}
+	config.DeferredPayments = config.DeferredPayments.withDefaults()
+
 	return &service{
-		orders:          make(map[string]*orderpb.Order),
-		logger:          logger,
-		inventoryClient: inventrypb.NewInventoryServiceClient(inventoryConn),
-		paymentClient:   paymentpb.NewPaymentServiceClient(paymentConn),
+		orders:           make(map[string]*orderpb.Order),
+		logger:           logger,
+		metrics:          metrics,
+		inventoryClient:  inventrypb.NewInventoryServiceClient(inventoryConn),
+		paymentClient:    paymentpb.NewPaymentServiceClient(paymentConn),
+		rates:            rates,
+		screener:         screener,
+		pendingPayments:  make(map[string]*paymentpb.PaymentRequest),
+		deferredPayments: make(map[string]*deferredPayment),
+		config:           config,
 	}
 }
 
 // CreateOrder creates a new order
-func (s *service) CreateOrder(ctx context.Context, customerID string, items []*orderpb.OrderItem) (*orderpb.Order, error) {
+func (s *service) CreateOrder(ctx context.Context, req *orderpb.CreateOrderRequest) (*orderpb.Order, error) {
+	customerID, items := req.CustomerId, req.Items
 	s.logger.Info("Creating new order", zap.String("customer_id", customerID), zap.Int("items_count", len(items)))
 
-	// Generate order ID
+	orderCurrency, err := s.validateCurrency(req)
+	if err != nil {
+		s.logger.Warn("Invalid order currency", zap.String("customer_id", customerID), zap.Error(err))
+		return nil, err
+	}
+
+	// Reject unusable payment methods before any stock is reserved
+	if err := payment.ValidatePaymentMethod(req.PaymentMethod, time.Now()); err != nil {
+		s.logger.Warn("Invalid payment method", zap.String("customer_id", customerID), zap.Error(err))
+		return nil, apierrors.InvalidField("payment_method", err)
+	}
+
+	// Fail fast rather than reserve stock that would only be released again,
+	// unless the order can wait for payment to come back
+	canDefer := s.config.DeferredPayments.Enabled && deferrable(req.PaymentMethod)
+	if breaker := s.config.PaymentBreaker; breaker != nil && breaker.State() == resilience.StateOpen && !canDefer {
+		s.logger.Warn("Rejecting order while payment service is unavailable", zap.String("customer_id", customerID))
+		return nil, apierrors.Unavailable(apierrors.ReasonServiceUnavailable, map[string]string{"service": breaker.Name()},
+			"payment service is unavailable, please retry later")
+	}
+
+	// Generate order ID and pass it on to the inventory and payment services
 	orderID := uuid.New().String()
+	ctx = observability.ContextWithBusinessContext(ctx, observability.BusinessContext{CustomerID: customerID, OrderID: orderID})
 
-	// Calculate total amount
+	// Calculate total amount in minor-unit precision
 	var totalAmount float64
 	for _, item := range items {
 		totalAmount += item.UnitPrice * float64(item.Quantity)
 	}
+	totalAmount = orderCurrency.Round(totalAmount)
+
+	// Convert the total into the settlement currency
+	settlementAmount, err := currency.Convert(ctx, s.rates, totalAmount, orderCurrency.Code, s.config.SettlementCurrency)
+	if err != nil {
+		s.logger.Error("Failed to convert order total", zap.String("currency", orderCurrency.Code), zap.Error(err))
+		return nil, apierrors.FailedPrecondition(apierrors.ReasonExchangeRateUnavailable,
+			map[string]string{"from": orderCurrency.Code, "to": s.config.SettlementCurrency},
+			"failed to convert order total to %s: %v", s.config.SettlementCurrency, err)
+	}
 
 	// Create order object
 	order := &orderpb.Order{
-		Id:          orderID,
-		CustomerId:  customerID,
-		Items:       items,
-		TotalAmount: totalAmount,
-		Status:      orderpb.OrderStatus_ORDER_STATUS_PENDING,
-		CreatedAt:   time.Now().Format(time.RFC3339),
-		UpdatedAt:   time.Now().Format(time.RFC3339),
+		Id:                 orderID,
+		CustomerId:         customerID,
+		Items:              items,
+		TotalAmount:        totalAmount,
+		Status:             orderpb.OrderStatus_ORDER_STATUS_PENDING,
+		CreatedAt:          time.Now().Format(time.RFC3339),
+		UpdatedAt:          time.Now().Format(time.RFC3339),
+		Currency:           orderCurrency.Code,
+		SettlementAmount:   settlementAmount,
+		SettlementCurrency: s.config.SettlementCurrency,
 	}
 
-	// Reserve inventory for each item
-	for _, item := range items {
-		reserveReq := &inventrypb.ReserveStockRequest{
-			ProductId: item.ProductId,
-			Quantity:  item.Quantity,
-			OrderId:   orderID,
-		}
-
-		reserveResp, err := s.inventoryClient.ReserveStock(ctx, reserveReq)
-		if err != nil {
-			s.logger.Error("Failed to reserve stock", zap.String("order_id", orderID), zap.String("product_id", item.ProductId), zap.Error(err))
-			return nil, fmt.Errorf("failed to reserve stock for product %s: %w", item.ProductId, err)
+	// Reserve inventory for all items concurrently within the reservation share of the deadline
+	reserveCtx, cancelReserve := resilience.StepContext(ctx, s.config.Deadlines.ReserveShare, s.config.Deadlines.MinStep)
+	held, failed, err := ReserveItems(reserveCtx, s.inventoryClient, orderID, items, s.config.ReserveConcurrency)
+	cancelReserve()
+	if err != nil {
+		step := "reserving stock"
+		if failed != nil {
+			step = fmt.Sprintf("reserving stock for product %s", failed.ProductId)
 		}
-
-		if !reserveResp.Success {
-			s.logger.Warn("Stock reservation failed", zap.String("order_id", orderID), zap.String("product_id", item.ProductId), zap.String("message", reserveResp.Message))
-			return nil, fmt.Errorf("insufficient stock for product %s: %s", item.ProductId, reserveResp.Message)
+		if apierrors.HasReason(err, apierrors.ReasonInsufficientStock) || apierrors.HasReason(err, apierrors.ReasonProductNotFound) {
+			s.logger.Warn("Stock reservation rejected", zap.String("order_id", orderID), zap.String("step", step), zap.Error(err))
+		} else {
+			s.logger.Error("Failed to reserve stock", zap.String("order_id", orderID), zap.String("step", step), zap.Error(err))
 		}
+		s.releaseStockForOrder(ctx, orderID, held)
+		return nil, stepError(step, err)
 	}
 
-	// Process payment
 	paymentReq := &paymentpb.PaymentRequest{
 		OrderId:       orderID,
 		CustomerId:    customerID,
 		Amount:        totalAmount,
-		Currency:      "USD",
-		PaymentMethod: "credit_card",
+		Currency:      orderCurrency.Code,
+		PaymentMethod: payment.MethodType(req.PaymentMethod),
+		Method:        req.PaymentMethod,
 	}
 
-	paymentResp, err := s.paymentClient.ProcessPayment(ctx, paymentReq)
+	// Screen the order for fraud before taking payment
+	assessment, err := s.screenOrder(ctx, order, req)
 	if err != nil {
-		s.logger.Error("Payment processing failed", zap.String("order_id", orderID), zap.Error(err))
-		// Release reserved stock
+		s.logger.Error("Fraud screening failed", zap.String("order_id", orderID), zap.Error(err))
+		s.releaseStockForOrder(ctx, orderID, items)
+		return nil, apierrors.Unavailable(apierrors.ReasonFraudScreeningFailed, map[string]string{"order_id": orderID},
+			"fraud screening failed for order %s: %v", orderID, err)
+	}
+	order.FraudAssessment = assessment
+
+	switch assessment.Decision {
+	case orderpb.FraudDecision_FRAUD_DECISION_REJECT:
 		s.releaseStockForOrder(ctx, orderID, items)
-		return nil, fmt.Errorf("payment processing failed: %w", err)
+		s.storeOrder(order, orderpb.OrderStatus_ORDER_STATUS_CANCELLED)
+		return nil, apierrors.FailedPrecondition(apierrors.ReasonFraudRejected, map[string]string{"order_id": orderID},
+			"order %s rejected by fraud screening", orderID)
+
+	case orderpb.FraudDecision_FRAUD_DECISION_REVIEW:
+		// Keep the stock reserved and hold the payment until a reviewer decides
+		s.mutex.Lock()
+		s.pendingPayments[orderID] = paymentReq
+		s.mutex.Unlock()
+		s.storeOrder(order, orderpb.OrderStatus_ORDER_STATUS_MANUAL_REVIEW)
+
+		s.logger.Info("Order held for manual review", zap.String("order_id", orderID), zap.Int32("fraud_score", assessment.Score))
+		return order, nil
+	}
+
+	deferred, err := s.chargeOrder(ctx, order, paymentReq)
+	if err != nil {
+		return nil, err
+	}
+	if deferred {
+		s.logger.Warn("Order accepted with payment deferred", zap.String("order_id", orderID), zap.Float64("total_amount", totalAmount), zap.String("currency", orderCurrency.Code))
+		return order, nil
+	}
+
+	// Update order status to processing and store order
+	s.storeOrder(order, orderpb.OrderStatus_ORDER_STATUS_PROCESSING)
+
+	s.logger.Info("Order created successfully", zap.String("order_id", orderID), zap.Float64("total_amount", totalAmount), zap.String("currency", orderCurrency.Code))
+	return order, nil
+}
+
+// chargeOrder processes the payment for an order with reserved stock, releasing
+// the stock if the payment does not succeed. If the payment service is
+// unavailable and deferred payments are enabled, the order is stored as
+// PENDING_PAYMENT with its stock held instead, and deferred is true.
+func (s *service) chargeOrder(ctx context.Context, order *orderpb.Order, paymentReq *paymentpb.PaymentRequest) (deferred bool, err error) {
+	paymentCtx, cancel := resilience.StepContext(ctx, s.config.Deadlines.PaymentShare, s.config.Deadlines.MinStep)
+	defer cancel()
+
+	paymentResp, err := s.paymentClient.ProcessPayment(paymentCtx, paymentReq)
+	if err != nil {
+		if s.config.DeferredPayments.Enabled && paymentUnavailable(err) && deferrable(paymentReq.Method) {
+			s.logger.Warn("Payment service unavailable, deferring payment", zap.String("order_id", order.Id), zap.Error(err))
+			s.deferPayment(ctx, order, paymentReq)
+			return true, nil
+		}
+
+		s.logger.Error("Payment processing failed", zap.String("order_id", order.Id), zap.Error(err))
+		// Release reserved stock
+		s.releaseStockForOrder(ctx, order.Id, order.Items)
+		return false, stepError("processing payment", err)
 	}
 
 	if paymentResp.Status != paymentpb.PaymentStatus_PAYMENT_STATUS_SUCCESS {
-		s.logger.Warn("Payment failed", zap.String("order_id", orderID), zap.String("message", paymentResp.Message))
+		s.logger.Warn("Payment failed", zap.String("order_id", order.Id), zap.String("message", paymentResp.Message))
 		// Release reserved stock
-		s.releaseStockForOrder(ctx, orderID, items)
-		return nil, fmt.Errorf("payment failed: %s", paymentResp.Message)
+		s.releaseStockForOrder(ctx, order.Id, order.Items)
+		return false, paymentDeclined(order.Id, paymentResp)
 	}
 
-	// Update order status to processing
-	order.Status = orderpb.OrderStatus_ORDER_STATUS_PROCESSING
+	return false, nil
+}
+
+// storeOrder sets an order's status and saves it
+func (s *service) storeOrder(order *orderpb.Order, status orderpb.OrderStatus) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	order.Status = status
 	order.UpdatedAt = time.Now().Format(time.RFC3339)
+	s.orders[order.Id] = order
+}
+
+// cardBIN returns the BIN of the card an order is paid with: from the card
+// itself, or from the payment service's summary of a saved card. Other methods,
+// and saved cards whose summary cannot be read, have none.
+func (s *service) cardBIN(ctx context.Context, customerID string, method *paymentpb.PaymentMethod) string {
+	if number := payment.NormalizeCardNumber(method.GetCard().GetNumber()); len(number) >= 6 {
+		return number[:6]
+	}
+
+	token := method.GetToken()
+	if token == "" {
+		return ""
+	}
+	resp, err := s.paymentClient.ListPaymentMethods(ctx, &paymentpb.ListPaymentMethodsRequest{CustomerId: customerID})
+	if err != nil {
+		s.logger.Warn("Failed to look up saved payment method, screening without card BIN", zap.String("customer_id", customerID), zap.Error(err))
+		return ""
+	}
+	for _, saved := range resp.SavedMethods {
+		if saved.Token == token {
+			return saved.CardBin
+		}
+	}
+	return ""
+}
+
+// screenOrder runs fraud screening and converts the result for the order record
+func (s *service) screenOrder(ctx context.Context, order *orderpb.Order, req *orderpb.CreateOrderRequest) (*orderpb.FraudAssessment, error) {
+	input := &fraud.Input{
+		OrderID:           order.Id,
+		CustomerID:        order.CustomerId,
+		Email:             req.RiskContext.GetEmail(),
+		IPAddress:         req.RiskContext.GetIpAddress(),
+		IPCountry:         req.RiskContext.GetIpCountry(),
+		BillingCountry:    req.RiskContext.GetBillingCountry(),
+		ShippingCountry:   req.RiskContext.GetShippingCountry(),
+		Amount:            order.SettlementAmount,
+		PaymentMethodType: payment.MethodType(req.PaymentMethod),
+	}
+	input.CardBIN = s.cardBIN(ctx, order.CustomerId, req.PaymentMethod)
+
+	result, err := s.screener.Screen(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	assessment := &orderpb.FraudAssessment{
+		Score:      int32(result.Score),
+		Decision:   fraudDecisions[result.Action],
+		AssessedAt: result.AssessedAt.Format(time.RFC3339),
+	}
+	for _, match := range result.MatchedRules {
+		assessment.MatchedRules = append(assessment.MatchedRules, &orderpb.FraudRuleMatch{
+			Rule:   match.Rule,
+			Score:  int32(match.Score),
+			Reason: match.Reason,
+		})
+	}
+
+	s.metrics.FraudDecisions.WithLabelValues(string(result.Action)).Inc()
+	return assessment, nil
+}
+
+// ReviewOrder resolves the manual review of an order held by fraud screening,
+// charging it when approved and releasing its stock when rejected
+func (s *service) ReviewOrder(ctx context.Context, orderID string, approve bool, reviewer string) (*orderpb.Order, error) {
+	s.logger.Info("Reviewing order", zap.String("order_id", orderID), zap.Bool("approve", approve), zap.String("reviewer", reviewer))
 
-	// Store order
 	s.mutex.Lock()
-	s.orders[orderID] = order
+	order, exists := s.orders[orderID]
+	if !exists {
+		s.mutex.Unlock()
+		s.logger.Warn("Order not found for review", zap.String("order_id", orderID))
+		return nil, orderNotFound(orderID)
+	}
+	if order.Status != orderpb.OrderStatus_ORDER_STATUS_MANUAL_REVIEW {
+		s.mutex.Unlock()
+		return nil, invalidOrderStatus(order, "order %s is not awaiting review (status %s)", orderID, order.Status.String())
+	}
+
+	// Claim the order so concurrent reviews cannot both act on it
+	paymentReq := s.pendingPayments[orderID]
+	delete(s.pendingPayments, orderID)
+	order.Status = orderpb.OrderStatus_ORDER_STATUS_PENDING
+	if order.FraudAssessment != nil {
+		order.FraudAssessment.ReviewedBy = reviewer
+	}
 	s.mutex.Unlock()
+	ctx = observability.ContextWithBusinessContext(ctx, observability.BusinessContext{CustomerID: order.CustomerId, OrderID: orderID})
 
-	s.logger.Info("Order created successfully", zap.String("order_id", orderID), zap.Float64("total_amount", totalAmount))
+	if !approve {
+		s.releaseStockForOrder(ctx, orderID, order.Items)
+		s.storeOrder(order, orderpb.OrderStatus_ORDER_STATUS_CANCELLED)
+		s.logger.Info("Order rejected in review", zap.String("order_id", orderID))
+		return order, nil
+	}
+
+	deferred, err := s.chargeOrder(ctx, order, paymentReq)
+	if err != nil {
+		s.storeOrder(order, orderpb.OrderStatus_ORDER_STATUS_CANCELLED)
+		return nil, err
+	}
+	if deferred {
+		s.logger.Info("Order approved in review with payment deferred", zap.String("order_id", orderID))
+		return order, nil
+	}
+
+	s.storeOrder(order, orderpb.OrderStatus_ORDER_STATUS_PROCESSING)
+	s.logger.Info("Order approved in review", zap.String("order_id", orderID))
 	return order, nil
 }
 
+// validateCurrency resolves the order currency and checks that every item is
+// priced in it with no more precision than its minor units allow
+func (s *service) validateCurrency(req *orderpb.CreateOrderRequest) (currency.Currency, error) {
+	code := req.Currency
+	if code == "" {
+		code = DefaultCurrency
+	}
+
+	orderCurrency, err := currency.Lookup(code)
+	if err != nil {
+		return currency.Currency{}, apierrors.InvalidField("currency", err)
+	}
+
+	var violations []apierrors.FieldViolation
+	for i, item := range req.Items {
+		if item.Currency != "" && !strings.EqualFold(item.Currency, orderCurrency.Code) {
+			violations = append(violations, apierrors.FieldViolation{
+				Field:       fmt.Sprintf("items[%d].currency", i),
+				Description: fmt.Sprintf("item %s is priced in %s but the order currency is %s", item.ProductId, item.Currency, orderCurrency.Code),
+			})
+		}
+		if !orderCurrency.IsExact(item.UnitPrice) {
+			violations = append(violations, apierrors.FieldViolation{
+				Field:       fmt.Sprintf("items[%d].unit_price", i),
+				Description: fmt.Sprintf("item %s unit price %v has more than %d decimal places for %s", item.ProductId, item.UnitPrice, orderCurrency.MinorUnits, orderCurrency.Code),
+			})
+		}
+	}
+	if len(violations) > 0 {
+		return currency.Currency{}, apierrors.InvalidArgument(violations...)
+	}
+
+	return orderCurrency, nil
+}
+
+// ConvertTotal converts an order's total into another currency for display
+func (s *service) ConvertTotal(ctx context.Context, order *orderpb.Order, currencyCode string) (float64, error) {
+	from := order.Currency
+	if from == "" {
+		from = DefaultCurrency
+	}
+
+	amount, err := currency.Convert(ctx, s.rates, order.TotalAmount, from, currencyCode)
+	if err != nil {
+		return 0, apierrors.InvalidField("display_currency", fmt.Errorf("failed to convert order total to %s: %w", currencyCode, err))
+	}
+
+	return amount, nil
+}
+
 // GetOrder retrieves an order by ID
 func (s *service) GetOrder(ctx context.Context, orderID string) (*orderpb.Order, error) {
 	s.logger.Debug("Retrieving order", zap.String("order_id", orderID))
@@ -131,12 +471,17 @@ func (s *service) GetOrder(ctx context.Context, orderID string) (*orderpb.Order,
 
 	if !exists {
 		s.logger.Warn("Order not found", zap.String("order_id", orderID))
-		return nil, fmt.Errorf("order not found: %s", orderID)
+		return nil, orderNotFound(orderID)
 	}
 
 	return order, nil
 }
 
+// Ping reports whether the order store can be read
+func (s *service) Ping(ctx context.Context) error {
+	return health.LockCheck(ctx, &s.mutex)
+}
+
 // UpdateOrderStatus updates the status of an order
 func (s *service) UpdateOrderStatus(ctx context.Context, orderID string, status orderpb.OrderStatus) (*orderpb.Order, error) {
 	s.logger.Info("Updating order status", zap.String("order_id", orderID), zap.String("new_status", status.String()))
@@ -147,7 +492,14 @@ func (s *service) UpdateOrderStatus(ctx context.Context, orderID string, status
 	order, exists := s.orders[orderID]
 	if !exists {
 		s.logger.Warn("Order not found for status update", zap.String("order_id", orderID))
-		return nil, fmt.Errorf("order not found: %s", orderID)
+		return nil, orderNotFound(orderID)
+	}
+
+	if order.Status == orderpb.OrderStatus_ORDER_STATUS_MANUAL_REVIEW || status == orderpb.OrderStatus_ORDER_STATUS_MANUAL_REVIEW {
+		return nil, invalidOrderStatus(order, "order %s: manual review is managed through ReviewOrder", orderID)
+	}
+	if order.Status == orderpb.OrderStatus_ORDER_STATUS_PENDING_PAYMENT || status == orderpb.OrderStatus_ORDER_STATUS_PENDING_PAYMENT {
+		return nil, invalidOrderStatus(order, "order %s: deferred payments are managed by the payment retry worker", orderID)
 	}
 
 	order.Status = status
@@ -157,8 +509,13 @@ func (s *service) UpdateOrderStatus(ctx context.Context, orderID string, status
 	return order, nil
 }
 
-// releaseStockForOrder releases reserved stock for an order (helper function)
+// releaseStockForOrder releases reserved stock for an order (helper function).
+// It runs on a detached context so that stock is still released when the
+// caller's deadline has already passed.
 func (s *service) releaseStockForOrder(ctx context.Context, orderID string, items []*orderpb.OrderItem) {
+	ctx, cancel := resilience.Detached(ctx, s.config.Deadlines.CompensationTimeout)
+	defer cancel()
+
 	for _, item := range items {
 		releaseReq := &inventrypb.ReleaseStockRequest{
 			ProductId: item.ProductId,
@@ -173,3 +530,33 @@ func (s *service) releaseStockForOrder(ctx context.Context, orderID string, item
 	}
 }
 
+// stepError wraps a failed saga step for the caller. Timeouts are reported as
+// DeadlineExceeded whether a step deadline or a downstream deadline expired;
+// other downstream status errors keep their code and details.
+func stepError(step string, err error) error {
+	if errors.Is(err, context.DeadlineExceeded) || status.Code(err) == codes.DeadlineExceeded {
+		return status.Errorf(codes.DeadlineExceeded, "timed out %s: %v", step, err)
+	}
+	if st, ok := status.FromError(err); ok {
+		proto := st.Proto()
+		proto.Message = fmt.Sprintf("failed %s: %s", step, st.Message())
+		return status.FromProto(proto).Err()
+	}
+	return fmt.Errorf("failed %s: %w", step, err)
+}
+
+func paymentDeclined(orderID string, resp *paymentpb.PaymentResponse) error {
+	return apierrors.FailedPrecondition(apierrors.ReasonPaymentDeclined,
+		map[string]string{"order_id": orderID, "payment_id": resp.PaymentId},
+		"payment failed: %s", resp.Message)
+}
+
+func orderNotFound(orderID string) error {
+	return apierrors.NotFound(apierrors.ReasonOrderNotFound, map[string]string{"order_id": orderID}, "order not found: %s", orderID)
+}
+
+func invalidOrderStatus(order *orderpb.Order, format string, args ...interface{}) error {
+	return apierrors.FailedPrecondition(apierrors.ReasonInvalidOrderStatus,
+		map[string]string{"order_id": order.Id, "status": order.Status.String()},
+		format, args...)
+}
@@ -0,0 +1,181 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/your-org/order-processing-system/pkg/apierrors"
+	"github.com/your-org/order-processing-system/pkg/currency"
+	"github.com/your-org/order-processing-system/pkg/fraud"
+	"github.com/your-org/order-processing-system/pkg/observability"
+	orderpb "github.com/your-org/order-processing-system/pkg/pb/order"
+	paymentpb "github.com/your-org/order-processing-system/pkg/pb/payment"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// savedMethodsClient lists a fixed set of saved payment methods, or fails with err
+type savedMethodsClient struct {
+	paymentpb.PaymentServiceClient
+	saved []*paymentpb.SavedPaymentMethod
+	err   error
+}
+
+func (c *savedMethodsClient) ListPaymentMethods(ctx context.Context, req *paymentpb.ListPaymentMethodsRequest, opts ...grpc.CallOption) (*paymentpb.ListPaymentMethodsResponse, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	var methods []*paymentpb.SavedPaymentMethod
+	for _, saved := range c.saved {
+		if saved.CustomerId == req.CustomerId {
+			methods = append(methods, saved)
+		}
+	}
+	return &paymentpb.ListPaymentMethodsResponse{SavedMethods: methods}, nil
+}
+
+// recordingScreener approves every order and remembers the last input
+type recordingScreener struct {
+	last *fraud.Input
+}
+
+func (s *recordingScreener) Screen(ctx context.Context, input *fraud.Input) (*fraud.Assessment, error) {
+	s.last = input
+	return &fraud.Assessment{Action: fraud.ActionApprove}, nil
+}
+
+func TestScreenOrderCardBIN(t *testing.T) {
+	saved := []*paymentpb.SavedPaymentMethod{
+		{Token: "pm_card", CustomerId: "customer-1", Type: "card", CardBin: "411111"},
+		{Token: "pm_wallet", CustomerId: "customer-1", Type: "wallet"},
+	}
+	token := func(token string) *paymentpb.PaymentMethod {
+		return &paymentpb.PaymentMethod{Method: &paymentpb.PaymentMethod_Token{Token: token}}
+	}
+
+	tests := []struct {
+		name    string
+		method  *paymentpb.PaymentMethod
+		listErr error
+		want    string
+	}{
+		{
+			name:   "raw card",
+			method: &paymentpb.PaymentMethod{Method: &paymentpb.PaymentMethod_Card{Card: &paymentpb.CardDetails{Number: "5555 4444 3333 1111"}}},
+			want:   "555544",
+		},
+		{name: "saved card", method: token("pm_card"), want: "411111"},
+		{name: "saved wallet", method: token("pm_wallet")},
+		{name: "unknown token", method: token("pm_unknown")},
+		{name: "payment service down", method: token("pm_card"), listErr: status.Error(codes.Unavailable, "down")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			screener := &recordingScreener{}
+			s := &service{
+				logger:        zap.NewNop(),
+				metrics:       observability.NewMetrics("order-service"),
+				paymentClient: &savedMethodsClient{saved: saved, err: tt.listErr},
+				screener:      screener,
+			}
+			order := &orderpb.Order{Id: "order-1", CustomerId: "customer-1"}
+
+			if _, err := s.screenOrder(context.Background(), order, &orderpb.CreateOrderRequest{PaymentMethod: tt.method}); err != nil {
+				t.Fatalf("screenOrder() error = %v", err)
+			}
+			if screener.last.CardBIN != tt.want {
+				t.Errorf("CardBIN = %q, want %q", screener.last.CardBIN, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateCurrency(t *testing.T) {
+	item := func(currency string, unitPrice float64) *orderpb.OrderItem {
+		return &orderpb.OrderItem{ProductId: "product-1", Quantity: 1, UnitPrice: unitPrice, Currency: currency}
+	}
+
+	tests := []struct {
+		name       string
+		currency   string
+		items      []*orderpb.OrderItem
+		want       string
+		violations []string
+	}{
+		{name: "default currency", items: []*orderpb.OrderItem{item("", 19.99)}, want: "USD"},
+		{name: "lower case code", currency: "eur", items: []*orderpb.OrderItem{item("EUR", 5)}, want: "EUR"},
+		{name: "item in order currency", currency: "JPY", items: []*orderpb.OrderItem{item("jpy", 1500)}, want: "JPY"},
+		{name: "three minor units", currency: "KWD", items: []*orderpb.OrderItem{item("", 1.234)}, want: "KWD"},
+		{name: "unknown currency", currency: "XYZ", items: []*orderpb.OrderItem{item("", 1)}, violations: []string{"currency"}},
+		{name: "item in other currency", currency: "USD", items: []*orderpb.OrderItem{item("", 1), item("EUR", 1)}, violations: []string{"items[1].currency"}},
+		{name: "fractional yen", currency: "JPY", items: []*orderpb.OrderItem{item("", 1500.5)}, violations: []string{"items[0].unit_price"}},
+		{name: "sub-cent price", items: []*orderpb.OrderItem{item("", 19.999)}, violations: []string{"items[0].unit_price"}},
+		{name: "fourth decimal in KWD", currency: "KWD", items: []*orderpb.OrderItem{item("", 1.2345)}, violations: []string{"items[0].unit_price"}},
+		{
+			name:       "every violation",
+			currency:   "JPY",
+			items:      []*orderpb.OrderItem{item("USD", 1.5), item("", 3)},
+			violations: []string{"items[0].currency", "items[0].unit_price"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := (&service{}).validateCurrency(&orderpb.CreateOrderRequest{CustomerId: "customer-1", Currency: tt.currency, Items: tt.items})
+			if tt.violations == nil {
+				if err != nil || got.Code != tt.want {
+					t.Errorf("validateCurrency() = %s, %v; want %s", got.Code, err, tt.want)
+				}
+				return
+			}
+
+			if code := status.Code(err); code != codes.InvalidArgument {
+				t.Fatalf("validateCurrency() code = %v, want InvalidArgument (error %v)", code, err)
+			}
+			violations := apierrors.FieldViolations(err)
+			if len(violations) != len(tt.violations) {
+				t.Fatalf("violations = %+v, want fields %v", violations, tt.violations)
+			}
+			for i, field := range tt.violations {
+				if violations[i].Field != field {
+					t.Errorf("violation %d field = %s, want %s", i, violations[i].Field, field)
+				}
+			}
+		})
+	}
+}
+
+// failingScreener fails every screening
+type failingScreener struct{}
+
+func (failingScreener) Screen(ctx context.Context, input *fraud.Input) (*fraud.Assessment, error) {
+	return nil, errors.New("rules unavailable")
+}
+
+func TestCreateOrderFraudScreeningFailure(t *testing.T) {
+	inventory := &fakeInventoryClient{}
+	s := newDeferringService(inventory, &fakePaymentClient{})
+	s.rates = currency.NewStaticProvider("USD", nil)
+	s.screener = failingScreener{}
+	s.config.SettlementCurrency = "USD"
+	s.config.ReserveConcurrency = 1
+
+	_, err := s.CreateOrder(context.Background(), &orderpb.CreateOrderRequest{
+		CustomerId:    "customer-1",
+		Items:         []*orderpb.OrderItem{{ProductId: "product-1", Quantity: 1, UnitPrice: 10}},
+		PaymentMethod: &paymentpb.PaymentMethod{Method: &paymentpb.PaymentMethod_Wallet{Wallet: &paymentpb.WalletDetails{Provider: "paypal", WalletToken: "wallet-1"}}},
+	})
+	if code := status.Code(err); code != codes.Unavailable {
+		t.Fatalf("CreateOrder() code = %v, want Unavailable (error %v)", code, err)
+	}
+	if !apierrors.HasReason(err, apierrors.ReasonFraudScreeningFailed) {
+		t.Errorf("CreateOrder() reason = %q, want %s", apierrors.Reason(err), apierrors.ReasonFraudScreeningFailed)
+	}
+	if len(inventory.released) != 1 {
+		t.Errorf("released %v, want the reserved product", inventory.released)
+	}
+}
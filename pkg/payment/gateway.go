@@ -0,0 +1,89 @@
+package payment
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/your-org/order-processing-system/pkg/observability"
+	paymentpb "github.com/your-org/order-processing-system/pkg/pb/payment"
+	"go.uber.org/zap"
+)
+
+// ChargeResult is the outcome reported by a payment gateway
+type ChargeResult struct {
+	Approved bool
+	Message  string
+}
+
+// Gateway charges a payment through a single payment provider
+type Gateway interface {
+	Name() string
+	Charge(ctx context.Context, req *paymentpb.PaymentRequest) (*ChargeResult, error)
+}
+
+// simulatedGateway approves a fixed share of charges after a random delay
+type simulatedGateway struct {
+	name          string
+	approvalRate  float32
+	minLatency    time.Duration
+	maxLatency    time.Duration
+	declineReason string
+	logger        *zap.Logger
+	rand          *rand.Rand
+	mutex         sync.Mutex
+}
+
+// newSimulatedGateway creates a gateway that approves approvalRate of all charges
+func newSimulatedGateway(logger *zap.Logger, name string, approvalRate float32, minLatency, maxLatency time.Duration, declineReason string) Gateway {
+	return &simulatedGateway{
+		name:          name,
+		approvalRate:  approvalRate,
+		minLatency:    minLatency,
+		maxLatency:    maxLatency,
+		declineReason: declineReason,
+		logger:        logger.Named("gateway").With(zap.String("gateway", name)),
+		rand:          rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// DefaultGateways returns the simulated gateway for each supported payment method
+func DefaultGateways(logger *zap.Logger) map[string]Gateway {
+	return map[string]Gateway{
+		MethodCard:         newSimulatedGateway(logger, "card-acquirer", 0.9, 100*time.Millisecond, 600*time.Millisecond, "Payment declined by bank"),
+		MethodWallet:       newSimulatedGateway(logger, "wallet-processor", 0.95, 50*time.Millisecond, 300*time.Millisecond, "Wallet payment declined"),
+		MethodBankTransfer: newSimulatedGateway(logger, "instant-transfer", 0.97, 200*time.Millisecond, 800*time.Millisecond, "Bank transfer rejected"),
+		MethodPayLater:     newSimulatedGateway(logger, "pay-later-provider", 0.85, 150*time.Millisecond, 700*time.Millisecond, "Pay-later credit check failed"),
+	}
+}
+
+// Name returns the gateway name
+func (g *simulatedGateway) Name() string {
+	return g.name
+}
+
+// Charge simulates a call to the provider
+func (g *simulatedGateway) Charge(ctx context.Context, req *paymentpb.PaymentRequest) (*ChargeResult, error) {
+	g.mutex.Lock()
+	delay := g.minLatency + time.Duration(g.rand.Int63n(int64(g.maxLatency-g.minLatency)+1))
+	approved := g.rand.Float32() < g.approvalRate
+	g.mutex.Unlock()
+
+	g.logger.Debug("Charging payment",
+		zap.String("order_id", req.OrderId),
+		zap.Duration("latency", delay),
+		observability.Proto("method", req.Method))
+
+	// Simulate provider latency
+	select {
+	case <-time.After(delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if !approved {
+		return &ChargeResult{Approved: false, Message: g.declineReason}, nil
+	}
+	return &ChargeResult{Approved: true, Message: "Payment processed successfully"}, nil
+}
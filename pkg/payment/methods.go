@@ -0,0 +1,229 @@
+package payment
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	paymentpb "github.com/your-org/order-processing-system/pkg/pb/payment"
+)
+
+// Payment method types, as carried in PaymentRequest.payment_method
+const (
+	MethodCard         = "card"
+	MethodWallet       = "wallet"
+	MethodBankTransfer = "bank_transfer"
+	MethodPayLater     = "pay_later"
+	MethodToken        = "token"
+)
+
+var (
+	supportedWallets   = map[string]bool{"apple_pay": true, "google_pay": true, "paypal": true}
+	supportedPayLater  = map[string]bool{"klarna": true, "affirm": true, "afterpay": true}
+	allowedInstallment = map[int32]bool{3: true, 4: true, 6: true, 12: true}
+)
+
+// MethodError is a payment method validation failure
+type MethodError struct {
+	// Field is the path of the invalid field within the PaymentMethod, e.g. "card.cvv"
+	Field  string
+	Reason string
+}
+
+func (e *MethodError) Error() string { return e.Reason }
+
+// FieldPath returns the path of the invalid field within the PaymentMethod
+func (e *MethodError) FieldPath() string { return e.Field }
+
+func fieldError(field, format string, args ...interface{}) error {
+	return &MethodError{Field: field, Reason: fmt.Sprintf(format, args...)}
+}
+
+// MethodType returns the type name of a payment method, or an empty string if none is set
+func MethodType(method *paymentpb.PaymentMethod) string {
+	switch method.GetMethod().(type) {
+	case *paymentpb.PaymentMethod_Card:
+		return MethodCard
+	case *paymentpb.PaymentMethod_Wallet:
+		return MethodWallet
+	case *paymentpb.PaymentMethod_BankTransfer:
+		return MethodBankTransfer
+	case *paymentpb.PaymentMethod_PayLater:
+		return MethodPayLater
+	case *paymentpb.PaymentMethod_Token:
+		return MethodToken
+	default:
+		return ""
+	}
+}
+
+// ValidatePaymentMethod applies method-specific validation as of the given time.
+// Tokens are only checked for shape; the vault validates what they resolve to.
+func ValidatePaymentMethod(method *paymentpb.PaymentMethod, now time.Time) error {
+	switch m := method.GetMethod().(type) {
+	case *paymentpb.PaymentMethod_Card:
+		return validateCard(m.Card, now, true)
+	case *paymentpb.PaymentMethod_Wallet:
+		return validateWallet(m.Wallet)
+	case *paymentpb.PaymentMethod_BankTransfer:
+		return validateBankTransfer(m.BankTransfer)
+	case *paymentpb.PaymentMethod_PayLater:
+		return validatePayLater(m.PayLater)
+	case *paymentpb.PaymentMethod_Token:
+		if !strings.HasPrefix(m.Token, tokenPrefix) {
+			return fieldError("token", "malformed payment method token")
+		}
+		return nil
+	default:
+		return fieldError("", "payment method is required")
+	}
+}
+
+// validateSavedMethod validates a payment method resolved from the vault,
+// which keeps cards without their CVV
+func validateSavedMethod(method *paymentpb.PaymentMethod, now time.Time) error {
+	if card := method.GetCard(); card != nil {
+		return validateCard(card, now, false)
+	}
+	return ValidatePaymentMethod(method, now)
+}
+
+func validateCard(card *paymentpb.CardDetails, now time.Time, requireCVV bool) error {
+	if card == nil {
+		return fieldError("card", "card details are required")
+	}
+
+	number := NormalizeCardNumber(card.Number)
+	if len(number) < 12 || len(number) > 19 || !isDigits(number) {
+		return fieldError("card.number", "card number must be 12 to 19 digits")
+	}
+	if !luhnValid(number) {
+		return fieldError("card.number", "card number failed checksum validation")
+	}
+
+	if card.ExpiryMonth < 1 || card.ExpiryMonth > 12 {
+		return fieldError("card.expiry_month", "invalid card expiry month: %d", card.ExpiryMonth)
+	}
+	// Cards are valid through the last day of their expiry month
+	expiry := time.Date(int(card.ExpiryYear), time.Month(card.ExpiryMonth)+1, 1, 0, 0, 0, 0, time.UTC)
+	if !now.Before(expiry) {
+		return fieldError("card.expiry_year", "card expired %02d/%d", card.ExpiryMonth, card.ExpiryYear)
+	}
+
+	if requireCVV && ((len(card.Cvv) != 3 && len(card.Cvv) != 4) || !isDigits(card.Cvv)) {
+		return fieldError("card.cvv", "card CVV must be 3 or 4 digits")
+	}
+	if strings.TrimSpace(card.HolderName) == "" {
+		return fieldError("card.holder_name", "card holder name is required")
+	}
+
+	return nil
+}
+
+func validateWallet(wallet *paymentpb.WalletDetails) error {
+	if wallet == nil {
+		return fieldError("wallet", "wallet details are required")
+	}
+	if !supportedWallets[wallet.Provider] {
+		return fieldError("wallet.provider", "unsupported wallet provider: %s", wallet.Provider)
+	}
+	if wallet.WalletToken == "" {
+		return fieldError("wallet.wallet_token", "wallet token is required")
+	}
+	return nil
+}
+
+func validateBankTransfer(transfer *paymentpb.BankTransferDetails) error {
+	if transfer == nil {
+		return fieldError("bank_transfer", "bank transfer details are required")
+	}
+	if !ibanValid(transfer.Iban) {
+		return fieldError("bank_transfer.iban", "invalid IBAN")
+	}
+	if strings.TrimSpace(transfer.AccountHolder) == "" {
+		return fieldError("bank_transfer.account_holder", "account holder is required")
+	}
+	return nil
+}
+
+func validatePayLater(payLater *paymentpb.PayLaterDetails) error {
+	if payLater == nil {
+		return fieldError("pay_later", "pay-later details are required")
+	}
+	if !supportedPayLater[payLater.Provider] {
+		return fieldError("pay_later.provider", "unsupported pay-later provider: %s", payLater.Provider)
+	}
+	if !allowedInstallment[payLater.Installments] {
+		return fieldError("pay_later.installments", "unsupported number of installments: %d", payLater.Installments)
+	}
+	return nil
+}
+
+// luhnValid reports whether a digit string passes the Luhn checksum
+func luhnValid(number string) bool {
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		digit := int(number[i] - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// ibanValid checks the structure and ISO 7064 mod-97 checksum of an IBAN
+func ibanValid(iban string) bool {
+	iban = strings.ToUpper(strings.ReplaceAll(iban, " ", ""))
+	if len(iban) < 15 || len(iban) > 34 {
+		return false
+	}
+
+	// Move the country code and check digits to the end, then map letters to numbers
+	var numeric strings.Builder
+	for _, r := range iban[4:] + iban[:4] {
+		switch {
+		case r >= '0' && r <= '9':
+			numeric.WriteRune(r)
+		case r >= 'A' && r <= 'Z':
+			fmt.Fprintf(&numeric, "%d", r-'A'+10)
+		default:
+			return false
+		}
+	}
+
+	value, ok := new(big.Int).SetString(numeric.String(), 10)
+	if !ok {
+		return false
+	}
+	return new(big.Int).Mod(value, big.NewInt(97)).Int64() == 1
+}
+
+// NormalizeCardNumber strips the spaces and dashes card numbers may be entered with
+func NormalizeCardNumber(number string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(number, " ", ""), "-", "")
+}
+
+// maskCardNumber keeps only the last four digits of a card number
+func maskCardNumber(number string) string {
+	number = NormalizeCardNumber(number)
+	if len(number) <= 4 {
+		return strings.Repeat("*", len(number))
+	}
+	return strings.Repeat("*", len(number)-4) + number[len(number)-4:]
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,69 @@
+package payment
+
+import (
+	"testing"
+	"time"
+
+	paymentpb "github.com/your-org/order-processing-system/pkg/pb/payment"
+)
+
+func TestNormalizeCardNumber(t *testing.T) {
+	for _, number := range []string{"4111111111111111", "4111 1111 1111 1111", "4111-1111-1111-1111"} {
+		if got := NormalizeCardNumber(number); got != "4111111111111111" {
+			t.Errorf("NormalizeCardNumber(%q) = %q", number, got)
+		}
+	}
+}
+
+func TestValidatePaymentMethod(t *testing.T) {
+	now := time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC)
+	card := func(number string, month, year int32, cvv string) *paymentpb.PaymentMethod {
+		return &paymentpb.PaymentMethod{Method: &paymentpb.PaymentMethod_Card{Card: &paymentpb.CardDetails{
+			Number: number, ExpiryMonth: month, ExpiryYear: year, Cvv: cvv, HolderName: "Jane Doe",
+		}}}
+	}
+
+	tests := []struct {
+		name   string
+		method *paymentpb.PaymentMethod
+		field  string
+	}{
+		{"card", card("4111 1111 1111 1111", 6, 2024, "123"), ""},
+		{"card failing Luhn", card("4111 1111 1111 1112", 6, 2024, "123"), "card.number"},
+		{"expired card", card("4111111111111111", 5, 2024, "123"), "card.expiry_year"},
+		{"card without CVV", card("4111111111111111", 6, 2024, ""), "card.cvv"},
+		{"IBAN", &paymentpb.PaymentMethod{Method: &paymentpb.PaymentMethod_BankTransfer{BankTransfer: &paymentpb.BankTransferDetails{
+			Iban: "GB82 WEST 1234 5698 7654 32", AccountHolder: "Jane Doe",
+		}}}, ""},
+		{"bad IBAN", &paymentpb.PaymentMethod{Method: &paymentpb.PaymentMethod_BankTransfer{BankTransfer: &paymentpb.BankTransferDetails{
+			Iban: "GB82 WEST 1234 5698 7654 33", AccountHolder: "Jane Doe",
+		}}}, "bank_transfer.iban"},
+		{"missing", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePaymentMethod(tt.method, now)
+			if tt.name == "missing" {
+				if err == nil {
+					t.Error("ValidatePaymentMethod(nil) succeeded")
+				}
+				return
+			}
+			if tt.field == "" {
+				if err != nil {
+					t.Errorf("ValidatePaymentMethod() error = %v", err)
+				}
+				return
+			}
+			methodErr, ok := err.(*MethodError)
+			if !ok || methodErr.Field != tt.field {
+				t.Errorf("ValidatePaymentMethod() error = %v, want one on %s", err, tt.field)
+			}
+		})
+	}
+
+	saved := card("4111111111111111", 6, 2024, "")
+	if err := validateSavedMethod(saved, now); err != nil {
+		t.Errorf("validateSavedMethod() of a card without CVV error = %v", err)
+	}
+}
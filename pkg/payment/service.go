@@ -3,75 +3,237 @@ package payment
 import (
 	"context"
 	"fmt"
-	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/your-org/order-processing-system/pkg/apierrors"
+	"github.com/your-org/order-processing-system/pkg/health"
 	paymentpb "github.com/your-org/order-processing-system/pkg/pb/payment"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // Service defines the core payment service interface
 type Service interface {
 	ProcessPayment(ctx context.Context, req *paymentpb.PaymentRequest) (*paymentpb.PaymentResponse, error)
+	GetPayment(ctx context.Context, paymentID string) (*paymentpb.Payment, error)
+	ListPayments(ctx context.Context) ([]*paymentpb.Payment, error)
+	Ping(ctx context.Context) error
 }
 
 // service implements the Service interface
 type service struct {
-	logger *zap.Logger
-	rand   *rand.Rand
+	payments map[string]*paymentpb.Payment
+	charges  map[string]*orderCharge
+	mutex    sync.RWMutex
+	logger   *zap.Logger
+	gateways map[string]Gateway
+	vault    Vault
 }
 
-// NewService creates a new payment service instance
-func NewService(logger *zap.Logger) Service {
+// orderCharge is the one charge an order may get, which makes ProcessPayment
+// safe to retry: a repeated request gets the original response back
+type orderCharge struct {
+	amount   float64
+	currency string
+	// response is nil while the charge is in progress
+	response *paymentpb.PaymentResponse
+}
+
+// NewService creates a new payment service instance routing each payment
+// method type to its gateway and resolving tokens through the vault
+func NewService(logger *zap.Logger, gateways map[string]Gateway, vault Vault) Service {
 	return &service{
-		logger: logger,
-		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+		payments: make(map[string]*paymentpb.Payment),
+		charges:  make(map[string]*orderCharge),
+		logger:   logger,
+		gateways: gateways,
+		vault:    vault,
 	}
 }
 
 // ProcessPayment processes a payment request
 func (s *service) ProcessPayment(ctx context.Context, req *paymentpb.PaymentRequest) (*paymentpb.PaymentResponse, error) {
-	s.logger.Info("Processing payment", 
-		zap.String("order_id", req.OrderId), 
-		zap.String("customer_id", req.CustomerId), 
+	s.logger.Info("Processing payment",
+		zap.String("order_id", req.OrderId),
+		zap.String("customer_id", req.CustomerId),
 		zap.Float64("amount", req.Amount),
 		zap.String("currency", req.Currency),
-		zap.String("payment_method", req.PaymentMethod))
+		zap.String("payment_method", MethodType(req.Method)))
+
+	// Swap a vault token for the payment method it stands for
+	validate := ValidatePaymentMethod
+	if token := req.Method.GetToken(); token != "" {
+		method, err := s.vault.Resolve(ctx, req.CustomerId, token)
+		if err != nil {
+			s.logger.Warn("Failed to resolve payment method token", zap.String("order_id", req.OrderId), zap.String("token", token), zap.Error(err))
+			return nil, err
+		}
+		req = &paymentpb.PaymentRequest{
+			OrderId:       req.OrderId,
+			CustomerId:    req.CustomerId,
+			Amount:        req.Amount,
+			Currency:      req.Currency,
+			PaymentMethod: MethodType(method),
+			Method:        method,
+		}
+		validate = validateSavedMethod
+	}
+
+	methodType := MethodType(req.Method)
+	if err := validate(req.Method, time.Now()); err != nil {
+		s.logger.Warn("Invalid payment method", zap.String("order_id", req.OrderId), zap.Error(err))
+		return nil, apierrors.InvalidField("method", err)
+	}
+
+	gateway, exists := s.gateways[methodType]
+	if !exists {
+		s.logger.Error("No gateway configured for payment method", zap.String("payment_method", methodType))
+		return nil, apierrors.FailedPrecondition(apierrors.ReasonPaymentMethodUnavailable,
+			map[string]string{"payment_method": methodType},
+			"payment method %s is not available", methodType)
+	}
+
+	// Charge each order once, however often the request is retried
+	previous, err := s.claimCharge(req)
+	if previous != nil || err != nil {
+		return previous, err
+	}
 
 	// Generate payment ID and transaction ID
 	paymentID := uuid.New().String()
-	transactionID := fmt.Sprintf("txn_%d", time.Now().Unix())
+	transactionID := fmt.Sprintf("txn_%s", uuid.New().String())
 
-	// Simulate payment processing delay
-	time.Sleep(time.Millisecond * time.Duration(s.rand.Intn(500)+100))
+	result, err := gateway.Charge(ctx, req)
+	if err != nil {
+		s.mutex.Lock()
+		delete(s.charges, req.OrderId)
+		s.mutex.Unlock()
 
-	// Simulate payment success/failure (90% success rate)
-	success := s.rand.Float32() < 0.9
+		s.logger.Error("Payment gateway error", zap.String("gateway", gateway.Name()), zap.String("order_id", req.OrderId), zap.Error(err))
+		if ctx.Err() != nil {
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+		return nil, apierrors.Unavailable(apierrors.ReasonGatewayUnavailable,
+			map[string]string{"gateway": gateway.Name()},
+			"payment gateway %s failed: %v", gateway.Name(), err)
+	}
 
-	if success {
-		s.logger.Info("Payment processed successfully", 
-			zap.String("payment_id", paymentID), 
+	status := paymentpb.PaymentStatus_PAYMENT_STATUS_FAILED
+	if result.Approved {
+		status = paymentpb.PaymentStatus_PAYMENT_STATUS_SUCCESS
+	}
+
+	response := &paymentpb.PaymentResponse{
+		PaymentId:     paymentID,
+		Status:        status,
+		Message:       result.Message,
+		TransactionId: transactionID,
+	}
+
+	// Record the payment so it can be looked up and reconciled later
+	s.mutex.Lock()
+	if charge := s.charges[req.OrderId]; charge != nil {
+		charge.response = response
+	}
+	s.payments[paymentID] = &paymentpb.Payment{
+		Id:            paymentID,
+		OrderId:       req.OrderId,
+		CustomerId:    req.CustomerId,
+		Amount:        req.Amount,
+		Currency:      req.Currency,
+		PaymentMethod: methodType,
+		Status:        status,
+		TransactionId: transactionID,
+		CreatedAt:     time.Now().Format(time.RFC3339),
+	}
+	s.mutex.Unlock()
+
+	if result.Approved {
+		s.logger.Info("Payment processed successfully",
+			zap.String("payment_id", paymentID),
 			zap.String("transaction_id", transactionID),
+			zap.String("gateway", gateway.Name()),
 			zap.String("order_id", req.OrderId))
-
-		return &paymentpb.PaymentResponse{
-			PaymentId:     paymentID,
-			Status:        paymentpb.PaymentStatus_PAYMENT_STATUS_SUCCESS,
-			Message:       "Payment processed successfully",
-			TransactionId: transactionID,
-		}, nil
 	} else {
-		s.logger.Warn("Payment processing failed", 
-			zap.String("payment_id", paymentID), 
+		s.logger.Warn("Payment processing failed",
+			zap.String("payment_id", paymentID),
+			zap.String("gateway", gateway.Name()),
 			zap.String("order_id", req.OrderId))
+	}
+
+	return response, nil
+}
+
+// claimCharge records that an order is being charged. If it already was, it
+// returns the response of that charge instead, or an error while the charge
+// is still in progress or was for another amount. Requests without an order ID
+// are never deduplicated.
+func (s *service) claimCharge(req *paymentpb.PaymentRequest) (*paymentpb.PaymentResponse, error) {
+	if req.OrderId == "" {
+		return nil, nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	charge, exists := s.charges[req.OrderId]
+	if !exists {
+		s.charges[req.OrderId] = &orderCharge{amount: req.Amount, currency: req.Currency}
+		return nil, nil
+	}
 
-		return &paymentpb.PaymentResponse{
-			PaymentId:     paymentID,
-			Status:        paymentpb.PaymentStatus_PAYMENT_STATUS_FAILED,
-			Message:       "Payment declined by bank",
-			TransactionId: transactionID,
-		}, nil
+	metadata := map[string]string{"order_id": req.OrderId}
+	if charge.amount != req.Amount || charge.currency != req.Currency {
+		return nil, apierrors.FailedPrecondition(apierrors.ReasonPaymentConflict, metadata,
+			"order %s was already charged %.2f %s", req.OrderId, charge.amount, charge.currency)
 	}
+	if charge.response == nil {
+		return nil, apierrors.New(codes.Aborted, apierrors.ReasonPaymentInProgress, metadata,
+			"payment for order %s is already in progress", req.OrderId)
+	}
+
+	s.logger.Info("Returning earlier payment for order",
+		zap.String("order_id", req.OrderId),
+		zap.String("payment_id", charge.response.PaymentId))
+	return charge.response, nil
 }
 
+// GetPayment retrieves a recorded payment by ID
+func (s *service) GetPayment(ctx context.Context, paymentID string) (*paymentpb.Payment, error) {
+	s.logger.Debug("Retrieving payment", zap.String("payment_id", paymentID))
+
+	s.mutex.RLock()
+	payment, exists := s.payments[paymentID]
+	s.mutex.RUnlock()
+
+	if !exists {
+		s.logger.Warn("Payment not found", zap.String("payment_id", paymentID))
+		return nil, apierrors.NotFound(apierrors.ReasonPaymentNotFound, map[string]string{"payment_id": paymentID}, "payment not found: %s", paymentID)
+	}
+
+	return payment, nil
+}
+
+// ListPayments returns every recorded payment
+func (s *service) ListPayments(ctx context.Context) ([]*paymentpb.Payment, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	payments := make([]*paymentpb.Payment, 0, len(s.payments))
+	for _, payment := range s.payments {
+		payments = append(payments, payment)
+	}
+
+	return payments, nil
+}
+
+// Ping reports whether the payment store and the vault are usable
+func (s *service) Ping(ctx context.Context) error {
+	if err := health.LockCheck(ctx, &s.mutex); err != nil {
+		return err
+	}
+	return s.vault.Ping(ctx)
+}
@@ -0,0 +1,137 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/your-org/order-processing-system/pkg/apierrors"
+	paymentpb "github.com/your-org/order-processing-system/pkg/pb/payment"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// countingGateway approves charges, counting them, after waiting for release
+// if it is set, and fails them while err is set
+type countingGateway struct {
+	charges int
+	release chan struct{}
+	err     error
+}
+
+func (g *countingGateway) Name() string { return "counting" }
+
+func (g *countingGateway) Charge(ctx context.Context, req *paymentpb.PaymentRequest) (*ChargeResult, error) {
+	if g.release != nil {
+		<-g.release
+	}
+	if g.err != nil {
+		return nil, g.err
+	}
+	g.charges++
+	return &ChargeResult{Approved: true}, nil
+}
+
+func newTestService(t *testing.T, gateway Gateway) Service {
+	t.Helper()
+	return NewService(zap.NewNop(), map[string]Gateway{"card": gateway}, newTestVault(t))
+}
+
+func cardRequest(orderID string, amount float64) *paymentpb.PaymentRequest {
+	return &paymentpb.PaymentRequest{
+		OrderId:    orderID,
+		CustomerId: "customer-1",
+		Amount:     amount,
+		Currency:   "USD",
+		Method:     testCard(),
+	}
+}
+
+func TestProcessPaymentChargesOrderOnce(t *testing.T) {
+	gateway := &countingGateway{}
+	svc := newTestService(t, gateway)
+
+	first, err := svc.ProcessPayment(context.Background(), cardRequest("order-1", 10))
+	if err != nil {
+		t.Fatalf("ProcessPayment() error = %v", err)
+	}
+	second, err := svc.ProcessPayment(context.Background(), cardRequest("order-1", 10))
+	if err != nil {
+		t.Fatalf("repeated ProcessPayment() error = %v", err)
+	}
+
+	if gateway.charges != 1 {
+		t.Errorf("gateway charged %d times, want 1", gateway.charges)
+	}
+	if second.PaymentId != first.PaymentId {
+		t.Errorf("repeated ProcessPayment() payment ID = %s, want %s", second.PaymentId, first.PaymentId)
+	}
+
+	_, err = svc.ProcessPayment(context.Background(), cardRequest("order-1", 20))
+	if status.Code(err) != codes.FailedPrecondition || !apierrors.HasReason(err, apierrors.ReasonPaymentConflict) {
+		t.Errorf("ProcessPayment() with another amount error = %v, want FailedPrecondition %s", err, apierrors.ReasonPaymentConflict)
+	}
+
+	if _, err := svc.ProcessPayment(context.Background(), cardRequest("order-2", 10)); err != nil {
+		t.Fatalf("ProcessPayment() for another order error = %v", err)
+	}
+	if gateway.charges != 2 {
+		t.Errorf("gateway charged %d times, want 2", gateway.charges)
+	}
+}
+
+func TestProcessPaymentInProgress(t *testing.T) {
+	gateway := &countingGateway{release: make(chan struct{})}
+	svc := newTestService(t, gateway)
+
+	done := make(chan error)
+	go func() {
+		_, err := svc.ProcessPayment(context.Background(), cardRequest("order-1", 10))
+		done <- err
+	}()
+
+	// Wait for the first request to claim the order
+	s := svc.(*service)
+	for {
+		s.mutex.RLock()
+		_, claimed := s.charges["order-1"]
+		s.mutex.RUnlock()
+		if claimed {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	_, err := svc.ProcessPayment(context.Background(), cardRequest("order-1", 10))
+	if status.Code(err) != codes.Aborted || !apierrors.HasReason(err, apierrors.ReasonPaymentInProgress) {
+		t.Errorf("concurrent ProcessPayment() error = %v, want Aborted %s", err, apierrors.ReasonPaymentInProgress)
+	}
+
+	close(gateway.release)
+	if err := <-done; err != nil {
+		t.Fatalf("ProcessPayment() error = %v", err)
+	}
+	if gateway.charges != 1 {
+		t.Errorf("gateway charged %d times, want 1", gateway.charges)
+	}
+}
+
+func TestProcessPaymentRetriesAfterGatewayError(t *testing.T) {
+	gateway := &countingGateway{err: errors.New("connection reset")}
+	svc := newTestService(t, gateway)
+
+	_, err := svc.ProcessPayment(context.Background(), cardRequest("order-1", 10))
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("ProcessPayment() error = %v, want Unavailable", err)
+	}
+
+	gateway.err = nil
+	if _, err := svc.ProcessPayment(context.Background(), cardRequest("order-1", 10)); err != nil {
+		t.Fatalf("ProcessPayment() after gateway recovered error = %v", err)
+	}
+	if gateway.charges != 1 {
+		t.Errorf("gateway charged %d times, want 1", gateway.charges)
+	}
+}
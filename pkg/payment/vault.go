@@ -0,0 +1,376 @@
+package payment
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/your-org/order-processing-system/pkg/apierrors"
+	"github.com/your-org/order-processing-system/pkg/health"
+	paymentpb "github.com/your-org/order-processing-system/pkg/pb/payment"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+// tokenPrefix marks opaque vault tokens
+const tokenPrefix = "pm_"
+
+// vaultKeySize is the AES-256 key length in bytes
+const vaultKeySize = 32
+
+// ErrTokenNotFound is returned when a token does not exist or belongs to another customer
+var ErrTokenNotFound = apierrors.NotFound(apierrors.ReasonPaymentMethodNotFound, nil, "payment method token not found")
+
+// Vault stores payment methods encrypted at rest and hands out opaque tokens for them
+type Vault interface {
+	Save(ctx context.Context, customerID string, method *paymentpb.PaymentMethod) (*paymentpb.SavedPaymentMethod, error)
+	Resolve(ctx context.Context, customerID, token string) (*paymentpb.PaymentMethod, error)
+	List(ctx context.Context, customerID string) ([]*paymentpb.SavedPaymentMethod, error)
+	Delete(ctx context.Context, customerID, token string) error
+	Ping(ctx context.Context) error
+}
+
+// vaultEntry is a saved payment method; only the summary is kept in clear text
+type vaultEntry struct {
+	summary    *paymentpb.SavedPaymentMethod
+	ciphertext []byte
+}
+
+// persistedEntry is the on-disk form of a vaultEntry
+type persistedEntry struct {
+	Summary    []byte `json:"summary"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// vault implements the Vault interface with AES-GCM encryption
+type vault struct {
+	entries map[string]*vaultEntry
+	mutex   sync.RWMutex
+	aead    cipher.AEAD
+	path    string
+	logger  *zap.Logger
+}
+
+// NewVault creates a vault encrypting payment methods with a 32-byte key.
+// With a path, the encrypted entries are written to that file on every change
+// and loaded from it on start; without one they only live in memory.
+func NewVault(logger *zap.Logger, key []byte, path string) (Vault, error) {
+	if len(key) != vaultKeySize {
+		return nil, fmt.Errorf("vault key must be %d bytes, got %d", vaultKeySize, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vault cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vault cipher: %w", err)
+	}
+
+	v := &vault{
+		entries: make(map[string]*vaultEntry),
+		aead:    aead,
+		path:    path,
+		logger:  logger.Named("vault"),
+	}
+	if path != "" {
+		if err := v.load(); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// load reads the entries saved in the vault file, if it exists, and checks
+// that they decrypt with the vault's key
+func (v *vault) load() error {
+	data, err := os.ReadFile(v.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read vault file: %w", err)
+	}
+
+	var persisted []persistedEntry
+	if err := json.Unmarshal(data, &persisted); err != nil {
+		return fmt.Errorf("failed to parse vault file %s: %w", v.path, err)
+	}
+	for _, p := range persisted {
+		summary := &paymentpb.SavedPaymentMethod{}
+		if err := proto.Unmarshal(p.Summary, summary); err != nil {
+			return fmt.Errorf("failed to parse vault file %s: %w", v.path, err)
+		}
+		entry := &vaultEntry{summary: summary, ciphertext: p.Ciphertext}
+		if _, err := v.open(entry); err != nil {
+			return fmt.Errorf("vault file %s does not match the vault key: %w", v.path, err)
+		}
+		v.entries[summary.Token] = entry
+	}
+
+	v.logger.Info("Loaded saved payment methods", zap.String("file", v.path), zap.Int("count", len(v.entries)))
+	return nil
+}
+
+// persist writes every entry to the vault file, replacing it atomically.
+// Callers must hold the write lock.
+func (v *vault) persist() error {
+	if v.path == "" {
+		return nil
+	}
+
+	persisted := make([]persistedEntry, 0, len(v.entries))
+	for _, entry := range v.entries {
+		summary, err := proto.Marshal(entry.summary)
+		if err != nil {
+			return fmt.Errorf("failed to encode payment method summary: %w", err)
+		}
+		persisted = append(persisted, persistedEntry{Summary: summary, Ciphertext: entry.ciphertext})
+	}
+	data, err := json.Marshal(persisted)
+	if err != nil {
+		return fmt.Errorf("failed to encode vault file: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(v.path), filepath.Base(v.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write vault file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write vault file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write vault file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), v.path); err != nil {
+		return fmt.Errorf("failed to write vault file: %w", err)
+	}
+	return nil
+}
+
+// GenerateVaultKey returns a new random vault key
+func GenerateVaultKey() ([]byte, error) {
+	key := make([]byte, vaultKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate vault key: %w", err)
+	}
+	return key, nil
+}
+
+// LoadOrCreateVaultKey reads a hex-encoded vault key from path, generating and
+// writing a new one with owner-only permissions if the file does not exist
+func LoadOrCreateVaultKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil {
+			return nil, fmt.Errorf("vault key file %s is not hex encoded: %w", path, err)
+		}
+		return key, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read vault key file: %w", err)
+	}
+
+	key, err := GenerateVaultKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(key)), 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write vault key file: %w", err)
+	}
+
+	return key, nil
+}
+
+// Save validates and encrypts a payment method, returning its token. Card
+// CVVs are checked but never stored.
+func (v *vault) Save(ctx context.Context, customerID string, method *paymentpb.PaymentMethod) (*paymentpb.SavedPaymentMethod, error) {
+	if customerID == "" {
+		return nil, apierrors.InvalidField("customer_id", errors.New("customer ID is required"))
+	}
+	if method.GetToken() != "" {
+		return nil, apierrors.InvalidField("method.token", errors.New("cannot save a tokenized payment method"))
+	}
+	if err := ValidatePaymentMethod(method, time.Now()); err != nil {
+		return nil, apierrors.InvalidField("method", err)
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	stored := proto.Clone(method).(*paymentpb.PaymentMethod)
+	if card := stored.GetCard(); card != nil {
+		card.Cvv = ""
+	}
+	plaintext, err := proto.Marshal(stored)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode payment method: %w", err)
+	}
+
+	nonce := make([]byte, v.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	// Bind the ciphertext to its owner and token so entries cannot be swapped
+	ciphertext := v.aead.Seal(nonce, nonce, plaintext, associatedData(customerID, token))
+
+	summary := &paymentpb.SavedPaymentMethod{
+		Token:       token,
+		CustomerId:  customerID,
+		Type:        MethodType(method),
+		Description: describeMethod(method),
+		CreatedAt:   time.Now().Format(time.RFC3339),
+	}
+	if number := NormalizeCardNumber(method.GetCard().GetNumber()); len(number) >= 6 {
+		summary.CardBin = number[:6]
+	}
+
+	v.mutex.Lock()
+	v.entries[token] = &vaultEntry{summary: summary, ciphertext: ciphertext}
+	if err := v.persist(); err != nil {
+		delete(v.entries, token)
+		v.mutex.Unlock()
+		v.logger.Error("Failed to persist payment method", zap.String("customer_id", customerID), zap.Error(err))
+		return nil, err
+	}
+	v.mutex.Unlock()
+
+	v.logger.Info("Payment method saved",
+		zap.String("customer_id", customerID),
+		zap.String("token", token),
+		zap.String("type", summary.Type))
+
+	return summary, nil
+}
+
+// Resolve decrypts the payment method behind a customer's token. Cards come
+// back without their CVV.
+func (v *vault) Resolve(ctx context.Context, customerID, token string) (*paymentpb.PaymentMethod, error) {
+	v.mutex.RLock()
+	entry, exists := v.entries[token]
+	v.mutex.RUnlock()
+
+	if !exists || entry.summary.CustomerId != customerID {
+		return nil, ErrTokenNotFound
+	}
+
+	method, err := v.open(entry)
+	if err != nil {
+		v.logger.Error("Failed to decrypt payment method", zap.String("token", token), zap.Error(err))
+		return nil, err
+	}
+	return method, nil
+}
+
+// open decrypts and decodes an entry's payment method
+func (v *vault) open(entry *vaultEntry) (*paymentpb.PaymentMethod, error) {
+	nonceSize := v.aead.NonceSize()
+	if len(entry.ciphertext) < nonceSize {
+		return nil, errors.New("failed to decrypt payment method: ciphertext too short")
+	}
+	nonce, sealed := entry.ciphertext[:nonceSize], entry.ciphertext[nonceSize:]
+	plaintext, err := v.aead.Open(nil, nonce, sealed, associatedData(entry.summary.CustomerId, entry.summary.Token))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt payment method: %w", err)
+	}
+
+	method := &paymentpb.PaymentMethod{}
+	if err := proto.Unmarshal(plaintext, method); err != nil {
+		return nil, fmt.Errorf("failed to decode payment method: %w", err)
+	}
+	return method, nil
+}
+
+// List returns the saved payment methods of a customer, oldest first
+func (v *vault) List(ctx context.Context, customerID string) ([]*paymentpb.SavedPaymentMethod, error) {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+
+	var methods []*paymentpb.SavedPaymentMethod
+	for _, entry := range v.entries {
+		if entry.summary.CustomerId == customerID {
+			methods = append(methods, entry.summary)
+		}
+	}
+
+	sort.Slice(methods, func(i, j int) bool {
+		if methods[i].CreatedAt != methods[j].CreatedAt {
+			return methods[i].CreatedAt < methods[j].CreatedAt
+		}
+		return methods[i].Token < methods[j].Token
+	})
+	return methods, nil
+}
+
+// Delete removes a customer's saved payment method
+func (v *vault) Delete(ctx context.Context, customerID, token string) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	entry, exists := v.entries[token]
+	if !exists || entry.summary.CustomerId != customerID {
+		return ErrTokenNotFound
+	}
+	delete(v.entries, token)
+	if err := v.persist(); err != nil {
+		v.entries[token] = entry
+		v.logger.Error("Failed to persist payment method deletion", zap.String("customer_id", customerID), zap.Error(err))
+		return err
+	}
+
+	v.logger.Info("Payment method deleted", zap.String("customer_id", customerID), zap.String("token", token))
+	return nil
+}
+
+// Ping reports whether the vault store can be read
+func (v *vault) Ping(ctx context.Context) error {
+	return health.LockCheck(ctx, &v.mutex)
+}
+
+// newToken generates an opaque random token
+func newToken() (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return tokenPrefix + hex.EncodeToString(raw), nil
+}
+
+func associatedData(customerID, token string) []byte {
+	return []byte(customerID + "|" + token)
+}
+
+// describeMethod builds a masked summary safe to return to clients
+func describeMethod(method *paymentpb.PaymentMethod) string {
+	switch m := method.GetMethod().(type) {
+	case *paymentpb.PaymentMethod_Card:
+		number := maskCardNumber(m.Card.Number)
+		return fmt.Sprintf("card ending %s, expires %02d/%d", number[len(number)-4:], m.Card.ExpiryMonth, m.Card.ExpiryYear)
+	case *paymentpb.PaymentMethod_Wallet:
+		return fmt.Sprintf("%s wallet", m.Wallet.Provider)
+	case *paymentpb.PaymentMethod_BankTransfer:
+		iban := strings.ReplaceAll(m.BankTransfer.Iban, " ", "")
+		return fmt.Sprintf("bank account %s ending %s", iban[:2], iban[len(iban)-4:])
+	case *paymentpb.PaymentMethod_PayLater:
+		return fmt.Sprintf("%s in %d installments", m.PayLater.Provider, m.PayLater.Installments)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,195 @@
+package payment
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/your-org/order-processing-system/pkg/apierrors"
+	paymentpb "github.com/your-org/order-processing-system/pkg/pb/payment"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// approveGateway approves every charge and remembers the last request
+type approveGateway struct {
+	last *paymentpb.PaymentRequest
+}
+
+func (g *approveGateway) Name() string { return "approve" }
+
+func (g *approveGateway) Charge(ctx context.Context, req *paymentpb.PaymentRequest) (*ChargeResult, error) {
+	g.last = req
+	return &ChargeResult{Approved: true}, nil
+}
+
+func testCard() *paymentpb.PaymentMethod {
+	return &paymentpb.PaymentMethod{Method: &paymentpb.PaymentMethod_Card{Card: &paymentpb.CardDetails{
+		Number:      "4111 1111 1111 1111",
+		ExpiryMonth: 12,
+		ExpiryYear:  int32(time.Now().Year() + 1),
+		Cvv:         "123",
+		HolderName:  "Jane Doe",
+	}}}
+}
+
+func newTestVault(t *testing.T) Vault {
+	t.Helper()
+	key, err := GenerateVaultKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vault, err := NewVault(zap.NewNop(), key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return vault
+}
+
+func TestVaultDoesNotStoreCVV(t *testing.T) {
+	vault := newTestVault(t)
+	method := testCard()
+
+	saved, err := vault.Save(context.Background(), "customer-1", method)
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if saved.CardBin != "411111" {
+		t.Errorf("saved CardBin = %q, want 411111", saved.CardBin)
+	}
+	if method.GetCard().Cvv != "123" {
+		t.Errorf("Save() changed the caller's CVV to %q", method.GetCard().Cvv)
+	}
+
+	resolved, err := vault.Resolve(context.Background(), "customer-1", saved.Token)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if cvv := resolved.GetCard().Cvv; cvv != "" {
+		t.Errorf("resolved CVV = %q, want none", cvv)
+	}
+	want := proto.Clone(method).(*paymentpb.PaymentMethod)
+	want.GetCard().Cvv = ""
+	if !proto.Equal(resolved, want) {
+		t.Errorf("Resolve() = %v, want %v", resolved, want)
+	}
+
+	if _, err := vault.Resolve(context.Background(), "customer-2", saved.Token); err != ErrTokenNotFound {
+		t.Errorf("Resolve() for another customer error = %v, want ErrTokenNotFound", err)
+	}
+}
+
+func TestVaultSaveRequiresCVV(t *testing.T) {
+	method := testCard()
+	method.GetCard().Cvv = ""
+	if _, err := newTestVault(t).Save(context.Background(), "customer-1", method); err == nil {
+		t.Error("Save() of a card without CVV succeeded")
+	}
+}
+
+func TestProcessPaymentWithSavedCard(t *testing.T) {
+	vault := newTestVault(t)
+	saved, err := vault.Save(context.Background(), "customer-1", testCard())
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	gateway := &approveGateway{}
+	service := NewService(zap.NewNop(), map[string]Gateway{MethodCard: gateway}, vault)
+	resp, err := service.ProcessPayment(context.Background(), &paymentpb.PaymentRequest{
+		OrderId:    "order-1",
+		CustomerId: "customer-1",
+		Amount:     10,
+		Currency:   "USD",
+		Method:     &paymentpb.PaymentMethod{Method: &paymentpb.PaymentMethod_Token{Token: saved.Token}},
+	})
+	if err != nil {
+		t.Fatalf("ProcessPayment() error = %v", err)
+	}
+	if resp.Status != paymentpb.PaymentStatus_PAYMENT_STATUS_SUCCESS {
+		t.Errorf("ProcessPayment() status = %v, want success", resp.Status)
+	}
+	if gateway.last.GetMethod().GetCard() == nil {
+		t.Errorf("gateway charged %v, want the saved card", gateway.last.GetMethod())
+	}
+}
+
+func TestVaultPersistsEntries(t *testing.T) {
+	key, err := GenerateVaultKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "vault.json")
+	ctx := context.Background()
+
+	first, err := NewVault(zap.NewNop(), key, path)
+	if err != nil {
+		t.Fatalf("NewVault() error = %v", err)
+	}
+	kept, err := first.Save(ctx, "customer-1", testCard())
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	deleted, err := first.Save(ctx, "customer-1", testCard())
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := first.Delete(ctx, "customer-1", deleted.Token); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "4111") || strings.Contains(string(data), "Jane Doe") {
+		t.Errorf("vault file holds card details in clear text: %s", data)
+	}
+
+	// A vault started on the same file resolves the tokens saved before
+	second, err := NewVault(zap.NewNop(), key, path)
+	if err != nil {
+		t.Fatalf("NewVault() on the saved file error = %v", err)
+	}
+	resolved, err := second.Resolve(ctx, "customer-1", kept.Token)
+	if err != nil {
+		t.Fatalf("Resolve() after restart error = %v", err)
+	}
+	if resolved.GetCard().GetHolderName() != "Jane Doe" {
+		t.Errorf("Resolve() after restart = %v, want the saved card", resolved)
+	}
+	if _, err := second.Resolve(ctx, "customer-1", deleted.Token); err != ErrTokenNotFound {
+		t.Errorf("Resolve() of a deleted token after restart error = %v, want ErrTokenNotFound", err)
+	}
+	if methods, _ := second.List(ctx, "customer-1"); len(methods) != 1 || !proto.Equal(methods[0], kept) {
+		t.Errorf("List() after restart = %v, want %v", methods, kept)
+	}
+
+	// The file cannot be loaded with another key
+	otherKey, err := GenerateVaultKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewVault(zap.NewNop(), otherKey, path); err == nil || !strings.Contains(err.Error(), "does not match the vault key") {
+		t.Errorf("NewVault() with another key error = %v, want a key mismatch", err)
+	}
+}
+
+func TestProcessPaymentWithUnknownToken(t *testing.T) {
+	service := NewService(zap.NewNop(), map[string]Gateway{MethodCard: &approveGateway{}}, newTestVault(t))
+	_, err := service.ProcessPayment(context.Background(), &paymentpb.PaymentRequest{
+		OrderId:    "order-1",
+		CustomerId: "customer-1",
+		Amount:     10,
+		Currency:   "USD",
+		Method:     &paymentpb.PaymentMethod{Method: &paymentpb.PaymentMethod_Token{Token: "pm_unknown"}},
+	})
+	if status.Code(err) != codes.NotFound || !apierrors.HasReason(err, apierrors.ReasonPaymentMethodNotFound) {
+		t.Errorf("ProcessPayment() error = %v, want NotFound with %s", err, apierrors.ReasonPaymentMethodNotFound)
+	}
+}
@@ -0,0 +1,180 @@
+package reconciliation
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/your-org/order-processing-system/pkg/apierrors"
+	"github.com/your-org/order-processing-system/pkg/currency"
+	paymentpb "github.com/your-org/order-processing-system/pkg/pb/payment"
+	"go.uber.org/zap"
+)
+
+// PaymentStore provides the payments that settlement lines are matched against
+type PaymentStore interface {
+	ListPayments(ctx context.Context) ([]*paymentpb.Payment, error)
+}
+
+// Service defines the settlement reconciliation interface
+type Service interface {
+	Reconcile(ctx context.Context, batchID string, settlement io.Reader) (*paymentpb.ReconciliationReport, error)
+}
+
+// service implements the Service interface
+type service struct {
+	store  PaymentStore
+	logger *zap.Logger
+
+	// reconciled maps the transactions earlier batches settled to their batch
+	reconciled map[string]string
+	mutex      sync.Mutex
+}
+
+// NewService creates a new reconciliation service backed by the given payment store
+func NewService(logger *zap.Logger, store PaymentStore) Service {
+	return &service{
+		store:      store,
+		logger:     logger.Named("reconciliation"),
+		reconciled: make(map[string]string),
+	}
+}
+
+// Reconcile matches an acquirer settlement file against stored payments by
+// transaction ID and amount
+func (s *service) Reconcile(ctx context.Context, batchID string, settlement io.Reader) (*paymentpb.ReconciliationReport, error) {
+	s.logger.Info("Reconciling settlement batch", zap.String("batch_id", batchID))
+
+	lines, err := ParseSettlementCSV(settlement)
+	if err != nil {
+		s.logger.Warn("Invalid settlement file", zap.String("batch_id", batchID), zap.Error(err))
+		return nil, apierrors.InvalidField("settlement_csv", fmt.Errorf("invalid settlement file: %w", err))
+	}
+
+	payments, err := s.store.ListPayments(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list payments: %w", err)
+	}
+
+	// Only successful payments are expected to be settled
+	captured := make(map[string]*paymentpb.Payment, len(payments))
+	for _, payment := range payments {
+		if payment.Status == paymentpb.PaymentStatus_PAYMENT_STATUS_SUCCESS {
+			captured[payment.TransactionId] = payment
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	report := &paymentpb.ReconciliationReport{BatchId: batchID}
+	settled := make(map[string]bool, len(lines))
+
+	for _, line := range lines {
+		if settled[line.TransactionID] {
+			report.Entries = append(report.Entries, &paymentpb.ReconciliationEntry{
+				TransactionId: line.TransactionID,
+				Status:        paymentpb.ReconciliationStatus_RECONCILIATION_STATUS_MISMATCHED,
+				SettledAmount: line.Amount,
+				Currency:      line.Currency,
+				Reason:        fmt.Sprintf("duplicate settlement line %d", line.LineNumber),
+			})
+			report.MismatchedCount++
+			continue
+		}
+		settled[line.TransactionID] = true
+
+		// Reconciling the same batch again is allowed, settling twice is not
+		if earlierBatch, exists := s.reconciled[line.TransactionID]; exists && earlierBatch != batchID {
+			report.Entries = append(report.Entries, &paymentpb.ReconciliationEntry{
+				TransactionId: line.TransactionID,
+				Status:        paymentpb.ReconciliationStatus_RECONCILIATION_STATUS_MISMATCHED,
+				SettledAmount: line.Amount,
+				Currency:      line.Currency,
+				Reason:        fmt.Sprintf("already settled in batch %s", earlierBatch),
+			})
+			report.MismatchedCount++
+			continue
+		}
+
+		payment, exists := captured[line.TransactionID]
+		if !exists {
+			report.Entries = append(report.Entries, &paymentpb.ReconciliationEntry{
+				TransactionId: line.TransactionID,
+				Status:        paymentpb.ReconciliationStatus_RECONCILIATION_STATUS_MISSING_PAYMENT,
+				SettledAmount: line.Amount,
+				Currency:      line.Currency,
+				Reason:        "no captured payment for transaction",
+			})
+			report.MissingPaymentCount++
+			continue
+		}
+
+		entry := &paymentpb.ReconciliationEntry{
+			TransactionId:  line.TransactionID,
+			Status:         paymentpb.ReconciliationStatus_RECONCILIATION_STATUS_MATCHED,
+			PaymentId:      payment.Id,
+			ExpectedAmount: payment.Amount,
+			SettledAmount:  line.Amount,
+			Currency:       payment.Currency,
+		}
+
+		// Amounts are compared and reported in the currency's minor units
+		paymentCurrency, err := currency.Lookup(payment.Currency)
+		switch {
+		case line.Currency != payment.Currency:
+			entry.Status = paymentpb.ReconciliationStatus_RECONCILIATION_STATUS_MISMATCHED
+			entry.Reason = fmt.Sprintf("currency mismatch: expected %s, settled %s", payment.Currency, line.Currency)
+		case err != nil:
+			entry.Status = paymentpb.ReconciliationStatus_RECONCILIATION_STATUS_MISMATCHED
+			entry.Reason = err.Error()
+		case !paymentCurrency.Equal(line.Amount, payment.Amount):
+			entry.Status = paymentpb.ReconciliationStatus_RECONCILIATION_STATUS_MISMATCHED
+			entry.Reason = fmt.Sprintf("amount mismatch: expected %s, settled %s", paymentCurrency.Format(payment.Amount), paymentCurrency.Format(line.Amount))
+		}
+
+		s.reconciled[line.TransactionID] = batchID
+		if entry.Status == paymentpb.ReconciliationStatus_RECONCILIATION_STATUS_MATCHED {
+			report.MatchedCount++
+		} else {
+			report.MismatchedCount++
+		}
+		report.Entries = append(report.Entries, entry)
+	}
+
+	// Captured payments the acquirer has not reported in this or an earlier batch
+	for transactionID, payment := range captured {
+		if _, reconciled := s.reconciled[transactionID]; reconciled || settled[transactionID] {
+			continue
+		}
+		report.Entries = append(report.Entries, &paymentpb.ReconciliationEntry{
+			TransactionId:  transactionID,
+			Status:         paymentpb.ReconciliationStatus_RECONCILIATION_STATUS_MISSING_SETTLEMENT,
+			PaymentId:      payment.Id,
+			ExpectedAmount: payment.Amount,
+			Currency:       payment.Currency,
+			Reason:         "captured payment not present in settlement",
+		})
+		report.MissingSettlementCount++
+	}
+
+	sort.SliceStable(report.Entries, func(i, j int) bool {
+		if report.Entries[i].Status != report.Entries[j].Status {
+			return report.Entries[i].Status < report.Entries[j].Status
+		}
+		return report.Entries[i].TransactionId < report.Entries[j].TransactionId
+	})
+	report.GeneratedAt = time.Now().Format(time.RFC3339)
+
+	s.logger.Info("Settlement batch reconciled",
+		zap.String("batch_id", batchID),
+		zap.Int32("matched", report.MatchedCount),
+		zap.Int32("mismatched", report.MismatchedCount),
+		zap.Int32("missing_payment", report.MissingPaymentCount),
+		zap.Int32("missing_settlement", report.MissingSettlementCount))
+
+	return report, nil
+}
@@ -0,0 +1,194 @@
+package reconciliation
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/your-org/order-processing-system/pkg/apierrors"
+	paymentpb "github.com/your-org/order-processing-system/pkg/pb/payment"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type fakeStore struct {
+	payments []*paymentpb.Payment
+	err      error
+}
+
+func (f fakeStore) ListPayments(ctx context.Context) ([]*paymentpb.Payment, error) {
+	return f.payments, f.err
+}
+
+func captured(id, transactionID string, amount float64, currency string) *paymentpb.Payment {
+	return &paymentpb.Payment{
+		Id:            id,
+		TransactionId: transactionID,
+		Amount:        amount,
+		Currency:      currency,
+		Status:        paymentpb.PaymentStatus_PAYMENT_STATUS_SUCCESS,
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	store := fakeStore{payments: []*paymentpb.Payment{
+		captured("pay-1", "txn-matched", 10, "USD"),
+		captured("pay-2", "txn-amount", 20, "USD"),
+		captured("pay-3", "txn-currency", 30, "USD"),
+		captured("pay-4", "txn-unsettled", 40, "EUR"),
+		{Id: "pay-5", TransactionId: "txn-failed", Amount: 50, Currency: "USD", Status: paymentpb.PaymentStatus_PAYMENT_STATUS_FAILED},
+	}}
+	settlement := strings.Join([]string{
+		"transaction_id,amount,currency",
+		"txn-matched,10.004,USD",
+		"txn-amount,19.90,USD",
+		"txn-currency,30,EUR",
+		"txn-unknown,5,USD",
+		"txn-failed,50,USD",
+		"txn-matched,10,USD",
+	}, "\n")
+
+	report, err := NewService(zap.NewNop(), store).Reconcile(context.Background(), "batch-1", strings.NewReader(settlement))
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	if report.BatchId != "batch-1" {
+		t.Errorf("BatchId = %q, want batch-1", report.BatchId)
+	}
+	if report.MatchedCount != 1 || report.MismatchedCount != 3 || report.MissingPaymentCount != 2 || report.MissingSettlementCount != 1 {
+		t.Errorf("counts = matched %d, mismatched %d, missing payment %d, missing settlement %d; want 1, 3, 2, 1",
+			report.MatchedCount, report.MismatchedCount, report.MissingPaymentCount, report.MissingSettlementCount)
+	}
+
+	want := []struct {
+		transactionID string
+		status        paymentpb.ReconciliationStatus
+		reason        string
+	}{
+		{"txn-matched", paymentpb.ReconciliationStatus_RECONCILIATION_STATUS_MATCHED, ""},
+		{"txn-amount", paymentpb.ReconciliationStatus_RECONCILIATION_STATUS_MISMATCHED, "amount mismatch"},
+		{"txn-currency", paymentpb.ReconciliationStatus_RECONCILIATION_STATUS_MISMATCHED, "currency mismatch"},
+		{"txn-matched", paymentpb.ReconciliationStatus_RECONCILIATION_STATUS_MISMATCHED, "duplicate settlement line 7"},
+		{"txn-failed", paymentpb.ReconciliationStatus_RECONCILIATION_STATUS_MISSING_PAYMENT, "no captured payment"},
+		{"txn-unknown", paymentpb.ReconciliationStatus_RECONCILIATION_STATUS_MISSING_PAYMENT, "no captured payment"},
+		{"txn-unsettled", paymentpb.ReconciliationStatus_RECONCILIATION_STATUS_MISSING_SETTLEMENT, "not present in settlement"},
+	}
+	if len(report.Entries) != len(want) {
+		t.Fatalf("got %d entries, want %d: %v", len(report.Entries), len(want), report.Entries)
+	}
+	for i, entry := range report.Entries {
+		w := want[i]
+		if entry.TransactionId != w.transactionID || entry.Status != w.status || !strings.Contains(entry.Reason, w.reason) {
+			t.Errorf("entry %d = %s %s %q, want %s %s %q", i, entry.TransactionId, entry.Status, entry.Reason, w.transactionID, w.status, w.reason)
+		}
+		if w.reason == "" && entry.Reason != "" {
+			t.Errorf("entry %d reason = %q, want none", i, entry.Reason)
+		}
+	}
+}
+
+func TestReconcileInvalidSettlement(t *testing.T) {
+	_, err := NewService(zap.NewNop(), fakeStore{}).Reconcile(context.Background(), "batch-1", strings.NewReader("transaction_id,amount\n"))
+	if code := status.Code(err); code != codes.InvalidArgument {
+		t.Fatalf("Reconcile() code = %v, want InvalidArgument (error %v)", code, err)
+	}
+	if violations := apierrors.FieldViolations(err); len(violations) != 1 || violations[0].Field != "settlement_csv" {
+		t.Errorf("FieldViolations() = %+v, want one for settlement_csv", violations)
+	}
+}
+
+func TestReconcileStoreError(t *testing.T) {
+	storeErr := errors.New("store down")
+	_, err := NewService(zap.NewNop(), fakeStore{err: storeErr}).Reconcile(context.Background(), "batch-1", strings.NewReader("transaction_id,amount,currency\n"))
+	if !errors.Is(err, storeErr) {
+		t.Errorf("Reconcile() error = %v, want %v", err, storeErr)
+	}
+}
+
+func TestReconcileAcrossBatches(t *testing.T) {
+	store := fakeStore{payments: []*paymentpb.Payment{
+		captured("pay-1", "txn-1", 10, "USD"),
+		captured("pay-2", "txn-2", 20, "USD"),
+		captured("pay-3", "txn-3", 30, "USD"),
+	}}
+	service := NewService(zap.NewNop(), store)
+	reconcile := func(batchID string, lines ...string) *paymentpb.ReconciliationReport {
+		t.Helper()
+		settlement := strings.Join(append([]string{"transaction_id,amount,currency"}, lines...), "\n")
+		report, err := service.Reconcile(context.Background(), batchID, strings.NewReader(settlement))
+		if err != nil {
+			t.Fatalf("Reconcile(%s) error = %v", batchID, err)
+		}
+		return report
+	}
+
+	first := reconcile("batch-1", "txn-1,10,USD")
+	if first.MatchedCount != 1 || first.MissingSettlementCount != 2 {
+		t.Errorf("batch-1: matched %d, missing settlement %d; want 1, 2", first.MatchedCount, first.MissingSettlementCount)
+	}
+
+	// txn-1 was settled by batch-1, so only txn-3 is still missing
+	second := reconcile("batch-2", "txn-2,20,USD")
+	if second.MatchedCount != 1 || second.MissingSettlementCount != 1 {
+		t.Errorf("batch-2: matched %d, missing settlement %d; want 1, 1", second.MatchedCount, second.MissingSettlementCount)
+	}
+	for _, entry := range second.Entries {
+		if entry.Status == paymentpb.ReconciliationStatus_RECONCILIATION_STATUS_MISSING_SETTLEMENT && entry.TransactionId != "txn-3" {
+			t.Errorf("batch-2 reports %s missing", entry.TransactionId)
+		}
+	}
+
+	// Settling txn-1 again in a later batch is a mismatch; rerunning batch-1 is not
+	third := reconcile("batch-3", "txn-1,10,USD", "txn-3,30,USD")
+	if third.MatchedCount != 1 || third.MismatchedCount != 1 || third.MissingSettlementCount != 0 {
+		t.Errorf("batch-3: matched %d, mismatched %d, missing settlement %d; want 1, 1, 0",
+			third.MatchedCount, third.MismatchedCount, third.MissingSettlementCount)
+	}
+	for _, entry := range third.Entries {
+		if entry.TransactionId == "txn-1" && !strings.Contains(entry.Reason, "already settled in batch batch-1") {
+			t.Errorf("batch-3 txn-1 reason = %q, want already settled in batch-1", entry.Reason)
+		}
+	}
+	if rerun := reconcile("batch-1", "txn-1,10,USD"); rerun.MatchedCount != 1 || rerun.MismatchedCount != 0 {
+		t.Errorf("rerun of batch-1: matched %d, mismatched %d; want 1, 0", rerun.MatchedCount, rerun.MismatchedCount)
+	}
+}
+
+func TestReconcileComparesMinorUnits(t *testing.T) {
+	tests := []struct {
+		currency string
+		expected float64
+		settled  string
+		reason   string
+	}{
+		{currency: "USD", expected: 10, settled: "10.004"},
+		{currency: "USD", expected: 10, settled: "10.01", reason: "amount mismatch: expected 10.00, settled 10.01"},
+		{currency: "JPY", expected: 1500, settled: "1500.4"},
+		{currency: "JPY", expected: 1500, settled: "1501", reason: "amount mismatch: expected 1500, settled 1501"},
+		{currency: "KWD", expected: 1.5, settled: "1.5004"},
+		{currency: "KWD", expected: 1.5, settled: "1.501", reason: "amount mismatch: expected 1.500, settled 1.501"},
+		{currency: "XYZ", expected: 1, settled: "1", reason: "unsupported currency: XYZ"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.currency+" "+tt.settled, func(t *testing.T) {
+			store := fakeStore{payments: []*paymentpb.Payment{captured("pay-1", "txn-1", tt.expected, tt.currency)}}
+			settlement := "transaction_id,amount,currency\ntxn-1," + tt.settled + "," + tt.currency
+
+			report, err := NewService(zap.NewNop(), store).Reconcile(context.Background(), "batch-1", strings.NewReader(settlement))
+			if err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+			entry := report.Entries[0]
+			if matched := entry.Status == paymentpb.ReconciliationStatus_RECONCILIATION_STATUS_MATCHED; matched != (tt.reason == "") {
+				t.Errorf("status = %s, want matched %v", entry.Status, tt.reason == "")
+			}
+			if entry.Reason != tt.reason {
+				t.Errorf("reason = %q, want %q", entry.Reason, tt.reason)
+			}
+		})
+	}
+}
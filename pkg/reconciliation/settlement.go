@@ -0,0 +1,81 @@
+package reconciliation
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Required settlement file columns; any additional columns are ignored
+const (
+	columnTransactionID = "transaction_id"
+	columnAmount        = "amount"
+	columnCurrency      = "currency"
+)
+
+// SettlementLine is a single settled transaction reported by the acquirer
+type SettlementLine struct {
+	TransactionID string
+	Amount        float64
+	Currency      string
+	LineNumber    int
+}
+
+// ParseSettlementCSV reads an acquirer settlement file. The first row must be a
+// header naming at least the transaction_id, amount and currency columns.
+func ParseSettlementCSV(r io.Reader) ([]SettlementLine, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("settlement file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read settlement header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{columnTransactionID, columnAmount, columnCurrency} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("settlement file is missing column %q", name)
+		}
+	}
+
+	var lines []SettlementLine
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read settlement file: %w", err)
+		}
+
+		lineNumber, _ := reader.FieldPos(0)
+		transactionID := strings.TrimSpace(record[columns[columnTransactionID]])
+		if transactionID == "" {
+			return nil, fmt.Errorf("line %d: empty transaction_id", lineNumber)
+		}
+
+		amount, err := strconv.ParseFloat(strings.TrimSpace(record[columns[columnAmount]]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid amount: %w", lineNumber, err)
+		}
+
+		lines = append(lines, SettlementLine{
+			TransactionID: transactionID,
+			Amount:        amount,
+			Currency:      strings.ToUpper(strings.TrimSpace(record[columns[columnCurrency]])),
+			LineNumber:    lineNumber,
+		})
+	}
+
+	return lines, nil
+}
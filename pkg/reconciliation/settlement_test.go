@@ -0,0 +1,81 @@
+package reconciliation
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSettlementCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		want    []SettlementLine
+		wantErr string
+	}{
+		{
+			name: "valid",
+			csv:  "transaction_id,amount,currency\ntxn-1,10.50,usd\ntxn-2, 3 ,EUR\n",
+			want: []SettlementLine{
+				{TransactionID: "txn-1", Amount: 10.5, Currency: "USD", LineNumber: 2},
+				{TransactionID: "txn-2", Amount: 3, Currency: "EUR", LineNumber: 3},
+			},
+		},
+		{
+			name: "extra columns in any order",
+			csv:  "Currency,settled_at,Amount,Transaction_ID\nGBP,2024-01-15,7.25,txn-1\n",
+			want: []SettlementLine{{TransactionID: "txn-1", Amount: 7.25, Currency: "GBP", LineNumber: 2}},
+		},
+		{
+			name: "header only",
+			csv:  "transaction_id,amount,currency\n",
+		},
+		{
+			name:    "empty",
+			csv:     "",
+			wantErr: "settlement file is empty",
+		},
+		{
+			name:    "missing column",
+			csv:     "transaction_id,amount\ntxn-1,10\n",
+			wantErr: `missing column "currency"`,
+		},
+		{
+			name:    "bad amount",
+			csv:     "transaction_id,amount,currency\ntxn-1,10,USD\ntxn-2,ten,USD\n",
+			wantErr: "line 3: invalid amount",
+		},
+		{
+			name:    "empty transaction id",
+			csv:     "transaction_id,amount,currency\n ,10,USD\n",
+			wantErr: "line 2: empty transaction_id",
+		},
+		{
+			name:    "short row",
+			csv:     "transaction_id,amount,currency\ntxn-1,10\n",
+			wantErr: "failed to read settlement file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := ParseSettlementCSV(strings.NewReader(tt.csv))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseSettlementCSV() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSettlementCSV() error = %v", err)
+			}
+			if len(lines) != len(tt.want) {
+				t.Fatalf("ParseSettlementCSV() = %+v, want %+v", lines, tt.want)
+			}
+			for i := range lines {
+				if lines[i] != tt.want[i] {
+					t.Errorf("line %d = %+v, want %+v", i, lines[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/your-org/order-processing-system/pkg/observability"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// State is the state of a circuit breaker
+type State int
+
+const (
+	// StateClosed lets every call through
+	StateClosed State = iota
+	// StateOpen rejects every call until the open timeout elapses
+	StateOpen
+	// StateHalfOpen lets a limited number of probe calls through
+	StateHalfOpen
+)
+
+// String returns the state name
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half_open"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrCircuitOpen is returned when a call is rejected by an open circuit breaker
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerConfig controls when a circuit breaker trips and recovers
+type BreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures that opens the breaker
+	FailureThreshold int
+	// OpenTimeout is how long the breaker stays open before probing
+	OpenTimeout time.Duration
+	// HalfOpenMaxCalls is the number of concurrent probes allowed while half-open
+	HalfOpenMaxCalls int
+	// FailureCodes are the status codes that count as downstream failures;
+	// other errors are the caller's fault and do not affect the breaker
+	FailureCodes []codes.Code
+}
+
+// DefaultBreakerConfig returns the breaker settings used for downstream clients
+func DefaultBreakerConfig() BreakerConfig {
+	return BreakerConfig{
+		FailureThreshold: 5,
+		OpenTimeout:      10 * time.Second,
+		HalfOpenMaxCalls: 1,
+		FailureCodes:     []codes.Code{codes.Unavailable, codes.DeadlineExceeded, codes.Internal},
+	}
+}
+
+// CircuitBreaker tracks the health of one downstream connection
+type CircuitBreaker struct {
+	name     string
+	config   BreakerConfig
+	metrics  *observability.Metrics
+	logger   *zap.Logger
+	now      func() time.Time
+	mutex    sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	probes   int
+	// generation counts state changes, so outcomes of calls allowed in an
+	// earlier state are not counted against the current one
+	generation uint64
+}
+
+// Ticket is handed out for an allowed call and passed back with its outcome
+type Ticket struct {
+	generation uint64
+	probe      bool
+}
+
+// NewCircuitBreaker creates a closed circuit breaker for the named downstream
+func NewCircuitBreaker(name string, config BreakerConfig, metrics *observability.Metrics, logger *zap.Logger) *CircuitBreaker {
+	b := &CircuitBreaker{
+		name:    name,
+		config:  config,
+		metrics: metrics,
+		logger:  logger.Named("breaker").With(zap.String("circuit_breaker", name)),
+		now:     time.Now,
+	}
+	metrics.CircuitBreakerState.WithLabelValues(name).Set(float64(StateClosed))
+	return b
+}
+
+// Name returns the name of the downstream the breaker protects
+func (b *CircuitBreaker) Name() string {
+	return b.name
+}
+
+// State returns the current state, moving from open to half-open once the open timeout has elapsed
+func (b *CircuitBreaker) State() State {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.refresh()
+	return b.state
+}
+
+// Allow reports whether a call may proceed; every allowed call must be
+// followed by Record with the returned ticket
+func (b *CircuitBreaker) Allow() (Ticket, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.refresh()
+
+	ticket := Ticket{generation: b.generation}
+	switch b.state {
+	case StateOpen:
+		return Ticket{}, ErrCircuitOpen
+	case StateHalfOpen:
+		if b.probes >= b.config.HalfOpenMaxCalls {
+			return Ticket{}, ErrCircuitOpen
+		}
+		b.probes++
+		ticket.probe = true
+	}
+	return ticket, nil
+}
+
+// Record reports the outcome of an allowed call. Outcomes of calls allowed
+// before the last state change are ignored: a slow call from before the
+// breaker opened must neither close it nor free a probe slot.
+func (b *CircuitBreaker) Record(ticket Ticket, err error) {
+	b.record(ticket, b.isFailure(err), err == nil)
+}
+
+// Ignore ends an allowed call whose outcome says nothing about the downstream,
+// such as one the caller cancelled or ran out of time for
+func (b *CircuitBreaker) Ignore(ticket Ticket) {
+	b.record(ticket, false, false)
+}
+
+// record counts a call as failed, succeeded or, with neither, as neutral
+func (b *CircuitBreaker) record(ticket Ticket, failed, succeeded bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if ticket.generation != b.generation {
+		return
+	}
+
+	switch b.state {
+	case StateHalfOpen:
+		if !ticket.probe {
+			return
+		}
+		b.probes--
+		if failed {
+			b.transition(StateOpen)
+		} else if succeeded {
+			b.transition(StateClosed)
+		}
+	case StateClosed:
+		if failed {
+			b.failures++
+			if b.failures >= b.config.FailureThreshold {
+				b.transition(StateOpen)
+			}
+		} else if succeeded {
+			b.failures = 0
+		}
+	}
+}
+
+func (b *CircuitBreaker) isFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	code := status.Code(err)
+	for _, c := range b.config.FailureCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// refresh moves an open breaker to half-open once its timeout has elapsed; callers hold the mutex
+func (b *CircuitBreaker) refresh() {
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.config.OpenTimeout {
+		b.transition(StateHalfOpen)
+	}
+}
+
+// transition changes state and publishes it; callers hold the mutex
+func (b *CircuitBreaker) transition(to State) {
+	if b.state == to {
+		return
+	}
+	from := b.state
+	b.state = to
+	b.generation++
+	b.failures = 0
+	b.probes = 0
+	if to == StateOpen {
+		b.openedAt = b.now()
+	}
+
+	b.metrics.CircuitBreakerState.WithLabelValues(b.name).Set(float64(to))
+	b.metrics.CircuitBreakerTransitions.WithLabelValues(b.name, to.String()).Inc()
+	b.logger.Warn("Circuit breaker state changed", zap.String("from", from.String()), zap.String("to", to.String()))
+}
+
+// UnaryClientBreakerInterceptor creates a gRPC unary client interceptor that
+// fails fast with Unavailable while the breaker is open. Calls that end
+// because the caller's own context is done, e.g. when a step of the caller's
+// deadline budget runs out, are not counted against the downstream.
+func UnaryClientBreakerInterceptor(breaker *CircuitBreaker) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ticket, err := breaker.Allow()
+		if err != nil {
+			return status.Errorf(codes.Unavailable, "%s: %v", breaker.Name(), err)
+		}
+
+		err = invoker(ctx, method, req, reply, cc, opts...)
+		if err != nil && ctx.Err() != nil {
+			breaker.Ignore(ticket)
+			return err
+		}
+		breaker.Record(ticket, err)
+		return err
+	}
+}
@@ -0,0 +1,225 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/your-org/order-processing-system/pkg/observability"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	errUnavailable = status.Error(codes.Unavailable, "down")
+	errInvalid     = status.Error(codes.InvalidArgument, "bad request")
+)
+
+// newTestBreaker returns a breaker on a clock the test moves with advance
+func newTestBreaker(config BreakerConfig) (breaker *CircuitBreaker, advance func(time.Duration)) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	breaker = NewCircuitBreaker("downstream", config, observability.NewMetrics("test"), zap.NewNop())
+	breaker.now = func() time.Time { return now }
+	return breaker, func(d time.Duration) { now = now.Add(d) }
+}
+
+// call runs one call through the breaker, reporting whether it was allowed
+func call(b *CircuitBreaker, err error) bool {
+	ticket, allowErr := b.Allow()
+	if allowErr != nil {
+		return false
+	}
+	b.Record(ticket, err)
+	return true
+}
+
+// trip opens a closed breaker
+func trip(t *testing.T, b *CircuitBreaker) {
+	t.Helper()
+	for i := 0; i < b.config.FailureThreshold; i++ {
+		call(b, errUnavailable)
+	}
+	if b.State() != StateOpen {
+		t.Fatalf("state = %s after %d failures, want open", b.State(), b.config.FailureThreshold)
+	}
+}
+
+func TestBreakerTripsAtThreshold(t *testing.T) {
+	b, _ := newTestBreaker(DefaultBreakerConfig())
+
+	for i := 0; i < 4; i++ {
+		call(b, errUnavailable)
+	}
+	// A success resets the count of consecutive failures
+	call(b, nil)
+	for i := 0; i < 4; i++ {
+		call(b, errUnavailable)
+	}
+	if b.State() != StateClosed {
+		t.Fatalf("state = %s after 4 consecutive failures, want closed", b.State())
+	}
+
+	call(b, status.Error(codes.DeadlineExceeded, "slow"))
+	if b.State() != StateOpen {
+		t.Fatalf("state = %s after 5 consecutive failures, want open", b.State())
+	}
+	if _, err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Allow() while open = %v, want ErrCircuitOpen", err)
+	}
+}
+
+func TestBreakerIgnoresCallerErrors(t *testing.T) {
+	b, _ := newTestBreaker(DefaultBreakerConfig())
+
+	for i := 0; i < 10; i++ {
+		call(b, errInvalid)
+		call(b, status.Error(codes.NotFound, "missing"))
+	}
+	if b.State() != StateClosed {
+		t.Fatalf("state = %s after caller errors, want closed", b.State())
+	}
+
+	// Caller errors between failures neither count nor reset the count
+	for i := 0; i < 4; i++ {
+		call(b, errUnavailable)
+		call(b, errInvalid)
+	}
+	call(b, errUnavailable)
+	if b.State() != StateOpen {
+		t.Errorf("state = %s, want open after 5 failures interleaved with caller errors", b.State())
+	}
+}
+
+func TestBreakerOpenTimeout(t *testing.T) {
+	b, advance := newTestBreaker(DefaultBreakerConfig())
+	trip(t, b)
+
+	advance(b.config.OpenTimeout - time.Millisecond)
+	if call(b, nil) {
+		t.Error("call allowed before the open timeout elapsed")
+	}
+
+	advance(time.Millisecond)
+	if b.State() != StateHalfOpen {
+		t.Fatalf("state = %s after the open timeout, want half_open", b.State())
+	}
+	if !call(b, nil) || b.State() != StateClosed {
+		t.Errorf("state = %s after a successful probe, want closed", b.State())
+	}
+}
+
+func TestBreakerHalfOpenProbes(t *testing.T) {
+	config := DefaultBreakerConfig()
+	config.HalfOpenMaxCalls = 2
+	b, advance := newTestBreaker(config)
+	trip(t, b)
+	advance(config.OpenTimeout)
+
+	first, err := b.Allow()
+	if err != nil {
+		t.Fatalf("first probe: Allow() error = %v", err)
+	}
+	second, err := b.Allow()
+	if err != nil {
+		t.Fatalf("second probe: Allow() error = %v", err)
+	}
+	if _, err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("third probe: Allow() error = %v, want ErrCircuitOpen", err)
+	}
+
+	// A probe answered with a caller error frees its slot without a verdict
+	b.Record(first, errInvalid)
+	if b.State() != StateHalfOpen {
+		t.Errorf("state = %s after a caller error, want half_open", b.State())
+	}
+	third, err := b.Allow()
+	if err != nil {
+		t.Fatalf("Allow() after a freed slot error = %v", err)
+	}
+
+	// A failed probe reopens the breaker
+	b.Record(second, errUnavailable)
+	if b.State() != StateOpen {
+		t.Fatalf("state = %s after a failed probe, want open", b.State())
+	}
+
+	// The other probe's result belongs to the half-open state that has ended
+	b.Record(third, nil)
+	if b.State() != StateOpen {
+		t.Errorf("state = %s after a stale probe succeeded, want open", b.State())
+	}
+	if b.probes != 0 {
+		t.Errorf("probes = %d, want 0", b.probes)
+	}
+}
+
+func TestBreakerIgnoresCallsFromEarlierStates(t *testing.T) {
+	b, advance := newTestBreaker(DefaultBreakerConfig())
+
+	// A slow call allowed while closed ends after the breaker opened and
+	// moved to half-open
+	slow, _ := b.Allow()
+	trip(t, b)
+	advance(b.config.OpenTimeout)
+	if b.State() != StateHalfOpen {
+		t.Fatalf("state = %s, want half_open", b.State())
+	}
+
+	b.Record(slow, nil)
+	if b.State() != StateHalfOpen || b.probes != 0 {
+		t.Errorf("state = %s with %d probes after a call from the closed state, want half_open with 0", b.State(), b.probes)
+	}
+
+	probe, err := b.Allow()
+	if err != nil {
+		t.Fatalf("Allow() error = %v, want the probe slot still free", err)
+	}
+	b.Record(probe, nil)
+	if b.State() != StateClosed {
+		t.Errorf("state = %s after a successful probe, want closed", b.State())
+	}
+
+	// A failure from the closed state before the breaker last opened does not
+	// count toward the next trip
+	old, _ := b.Allow()
+	trip(t, b)
+	advance(b.config.OpenTimeout)
+	call(b, nil)
+	b.Record(old, errUnavailable)
+	if b.failures != 0 {
+		t.Errorf("failures = %d after a failure from an earlier closed state, want 0", b.failures)
+	}
+}
+
+func TestBreakerInterceptorIgnoresCallerDeadline(t *testing.T) {
+	b, _ := newTestBreaker(DefaultBreakerConfig())
+	interceptor := UnaryClientBreakerInterceptor(b)
+	// The downstream answers once the caller's context is done
+	slow := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		<-ctx.Done()
+		return status.FromContextError(ctx.Err()).Err()
+	}
+
+	for i := 0; i < 2*b.config.FailureThreshold; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		interceptor(ctx, "/test.Service/Call", nil, nil, nil, slow)
+		cancel()
+	}
+	if b.State() != StateClosed || b.failures != 0 {
+		t.Errorf("state = %s with %d failures after the caller's deadlines, want closed with none", b.State(), b.failures)
+	}
+
+	// DeadlineExceeded from the downstream itself still counts
+	timedOut := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return status.Error(codes.DeadlineExceeded, "downstream timed out")
+	}
+	for i := 0; i < b.config.FailureThreshold; i++ {
+		interceptor(context.Background(), "/test.Service/Call", nil, nil, nil, timedOut)
+	}
+	if b.State() != StateOpen {
+		t.Errorf("state = %s after downstream deadlines, want open", b.State())
+	}
+}
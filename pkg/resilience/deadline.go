@@ -0,0 +1,119 @@
+package resilience
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// TimeoutConfig holds per-method deadlines for outgoing calls
+type TimeoutConfig struct {
+	// Methods maps full gRPC method names to the deadline of a single attempt
+	Methods map[string]time.Duration
+	// Default applies to methods without an explicit timeout; zero disables it
+	Default time.Duration
+}
+
+// DefaultTimeoutConfig returns the per-attempt deadlines for the order service's downstream calls
+func DefaultTimeoutConfig() TimeoutConfig {
+	return TimeoutConfig{
+		Methods: map[string]time.Duration{
+			"/inventory.InventoryService/ReserveStock":    500 * time.Millisecond,
+			"/inventory.InventoryService/ReleaseStock":    500 * time.Millisecond,
+			"/inventory.InventoryService/GetProductStock": 300 * time.Millisecond,
+			// Gateways take up to ~800ms to answer
+			"/payment.PaymentService/ProcessPayment": 2 * time.Second,
+		},
+		Default: 1 * time.Second,
+	}
+}
+
+// ParseTimeouts overrides timeouts from a comma-separated list of
+// Method=duration pairs, where Method is a full gRPC method name or just the
+// final method segment, e.g. "ReserveStock=300ms,/payment.PaymentService/ProcessPayment=3s"
+func (c TimeoutConfig) ParseTimeouts(spec string) (TimeoutConfig, error) {
+	methods := make(map[string]time.Duration, len(c.Methods))
+	for method, timeout := range c.Methods {
+		methods[method] = timeout
+	}
+
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, found := strings.Cut(pair, "=")
+		if !found {
+			return c, fmt.Errorf("invalid timeout %q, expected Method=duration", pair)
+		}
+		timeout, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return c, fmt.Errorf("invalid timeout for %s: %w", name, err)
+		}
+
+		name = strings.TrimSpace(name)
+		if strings.HasPrefix(name, "/") {
+			methods[name] = timeout
+			continue
+		}
+		matched := false
+		for method := range methods {
+			if strings.HasSuffix(method, "/"+name) {
+				methods[method] = timeout
+				matched = true
+			}
+		}
+		if !matched {
+			return c, fmt.Errorf("unknown method %q in timeouts", name)
+		}
+	}
+
+	c.Methods = methods
+	return c, nil
+}
+
+// UnaryClientTimeoutInterceptor creates a gRPC unary client interceptor that
+// bounds every attempt by its method timeout. The caller's deadline still
+// applies when it is shorter.
+func UnaryClientTimeoutInterceptor(config TimeoutConfig) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		timeout, exists := config.Methods[method]
+		if !exists {
+			timeout = config.Default
+		}
+		if timeout <= 0 {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// StepContext derives the context for one step of a multi-step operation,
+// giving it share (0-1] of the time left before ctx's deadline but never less
+// than min. The step is derived from ctx, so it never outlives ctx's deadline,
+// min or not. Without a deadline on ctx the step is only cancelled with it.
+func StepContext(ctx context.Context, share float64, min time.Duration) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+
+	step := time.Duration(float64(time.Until(deadline)) * share)
+	if step < min {
+		step = min
+	}
+	return context.WithTimeout(ctx, step)
+}
+
+// Detached returns a context that keeps ctx's values, such as the active span,
+// but not its cancellation or deadline, bounded by its own timeout. It is used
+// for compensating actions that must run even after the caller gave up.
+func Detached(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), timeout)
+}
@@ -0,0 +1,118 @@
+package resilience
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStepContext(t *testing.T) {
+	tests := []struct {
+		name      string
+		remaining time.Duration
+		share     float64
+		min       time.Duration
+		want      time.Duration
+	}{
+		{name: "share of the remaining time", remaining: time.Second, share: 0.4, min: 50 * time.Millisecond, want: 400 * time.Millisecond},
+		{name: "whole remaining time", remaining: time.Second, share: 1, min: 50 * time.Millisecond, want: time.Second},
+		{name: "raised to the minimum", remaining: time.Second, share: 0.01, min: 50 * time.Millisecond, want: 50 * time.Millisecond},
+		{name: "minimum capped by the parent deadline", remaining: 20 * time.Millisecond, share: 0.5, min: 50 * time.Millisecond, want: 20 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent, cancelParent := context.WithTimeout(context.Background(), tt.remaining)
+			defer cancelParent()
+			parentDeadline, _ := parent.Deadline()
+
+			start := time.Now()
+			ctx, cancel := StepContext(parent, tt.share, tt.min)
+			defer cancel()
+
+			deadline, ok := ctx.Deadline()
+			if !ok {
+				t.Fatal("step has no deadline")
+			}
+			if deadline.After(parentDeadline) {
+				t.Errorf("step deadline %v is after the parent's %v", deadline, parentDeadline)
+			}
+			// Allow for the time between creating the two contexts
+			if got := deadline.Sub(start); got > tt.want+10*time.Millisecond || got < tt.want-10*time.Millisecond {
+				t.Errorf("step gets %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStepContextWithoutDeadline(t *testing.T) {
+	parent, cancelParent := context.WithCancel(context.Background())
+	ctx, cancel := StepContext(parent, 0.5, 50*time.Millisecond)
+	defer cancel()
+
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("step of a context without deadline has one")
+	}
+	cancelParent()
+	if ctx.Err() == nil {
+		t.Error("step not cancelled with its parent")
+	}
+}
+
+func TestParseTimeouts(t *testing.T) {
+	const (
+		reserve = "/inventory.InventoryService/ReserveStock"
+		payment = "/payment.PaymentService/ProcessPayment"
+	)
+
+	tests := []struct {
+		name    string
+		spec    string
+		want    map[string]time.Duration
+		wantErr string
+	}{
+		{name: "empty", spec: "", want: map[string]time.Duration{reserve: 500 * time.Millisecond, payment: 2 * time.Second}},
+		{name: "short name", spec: "ReserveStock=300ms", want: map[string]time.Duration{reserve: 300 * time.Millisecond, payment: 2 * time.Second}},
+		{
+			name: "full name and spaces",
+			spec: " ReserveStock = 300ms , /payment.PaymentService/ProcessPayment=3s,",
+			want: map[string]time.Duration{reserve: 300 * time.Millisecond, payment: 3 * time.Second},
+		},
+		{name: "new full name", spec: "/other.Service/Call=1s", want: map[string]time.Duration{"/other.Service/Call": time.Second}},
+		{name: "unknown short name", spec: "Call=1s", wantErr: `unknown method "Call"`},
+		{name: "missing duration", spec: "ReserveStock", wantErr: "expected Method=duration"},
+		{name: "invalid duration", spec: "ReserveStock=fast", wantErr: "invalid timeout for ReserveStock"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defaults := DefaultTimeoutConfig()
+			got, err := defaults.ParseTimeouts(tt.spec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ParseTimeouts(%q) error = %v, want %q", tt.spec, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTimeouts(%q) error = %v", tt.spec, err)
+			}
+			for method, timeout := range tt.want {
+				if got.Methods[method] != timeout {
+					t.Errorf("timeout of %s = %v, want %v", method, got.Methods[method], timeout)
+				}
+			}
+			if got.Default != defaults.Default {
+				t.Errorf("Default = %v, want %v", got.Default, defaults.Default)
+			}
+		})
+	}
+
+	// Overrides do not leak into the config they were parsed from
+	defaults := DefaultTimeoutConfig()
+	defaults.ParseTimeouts("ReserveStock=1ms")
+	if defaults.Methods[reserve] != 500*time.Millisecond {
+		t.Errorf("ParseTimeouts changed the receiver's timeout to %v", defaults.Methods[reserve])
+	}
+}
@@ -0,0 +1,189 @@
+package resilience
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/your-org/order-processing-system/pkg/observability"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RetryPolicy controls how a single RPC method is retried
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	// Jitter is the fraction of each backoff that is randomized, between 0 and 1
+	Jitter         float64
+	RetryableCodes []codes.Code
+	// Idempotent must be set for a method to be retried at all; calls that are
+	// not safe to repeat are never retried whatever their status code
+	Idempotent bool
+}
+
+// RetryConfig holds per-method retry policies and the shared retry budget
+type RetryConfig struct {
+	// Methods maps full gRPC method names, e.g. "/inventory.InventoryService/ReserveStock", to policies
+	Methods map[string]RetryPolicy
+	Budget  BudgetConfig
+}
+
+// BudgetConfig bounds retries across all calls of a client, following the gRPC
+// retry throttling scheme: every failure costs a token, every success refunds
+// TokenRatio tokens, and retries are only allowed while more than half of
+// MaxTokens remain
+type BudgetConfig struct {
+	MaxTokens  float64
+	TokenRatio float64
+}
+
+// DefaultRetryConfig returns the retry policies for the order service's downstream calls
+func DefaultRetryConfig() RetryConfig {
+	idempotent := RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 50 * time.Millisecond,
+		MaxBackoff:     500 * time.Millisecond,
+		Multiplier:     2,
+		Jitter:         0.5,
+		RetryableCodes: []codes.Code{codes.Unavailable, codes.Aborted},
+		Idempotent:     true,
+	}
+
+	return RetryConfig{
+		Methods: map[string]RetryPolicy{
+			// Reservations and releases are deduplicated by order ID in the inventory service
+			"/inventory.InventoryService/ReserveStock":    idempotent,
+			"/inventory.InventoryService/ReleaseStock":    withAttempts(idempotent, 5),
+			"/inventory.InventoryService/GetProductStock": idempotent,
+			// Charges are deduplicated by order ID too, but a charge is slow and
+			// payments the service cannot take are deferred instead when enabled
+			"/payment.PaymentService/ProcessPayment": {Idempotent: false},
+		},
+		Budget: BudgetConfig{
+			MaxTokens:  10,
+			TokenRatio: 0.1,
+		},
+	}
+}
+
+func withAttempts(policy RetryPolicy, attempts int) RetryPolicy {
+	policy.MaxAttempts = attempts
+	return policy
+}
+
+// retryBudget implements the token bucket described by BudgetConfig
+type retryBudget struct {
+	config BudgetConfig
+	tokens float64
+	mutex  sync.Mutex
+}
+
+func newRetryBudget(config BudgetConfig) *retryBudget {
+	return &retryBudget{config: config, tokens: config.MaxTokens}
+}
+
+func (b *retryBudget) onSuccess() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.tokens = math.Min(b.config.MaxTokens, b.tokens+b.config.TokenRatio)
+}
+
+// onFailure spends a token and reports whether a retry is still allowed
+func (b *retryBudget) onFailure() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.tokens = math.Max(0, b.tokens-1)
+	return b.tokens > b.config.MaxTokens/2
+}
+
+// UnaryClientRetryInterceptor creates a gRPC unary client interceptor that
+// retries idempotent calls with exponential backoff and jitter
+func UnaryClientRetryInterceptor(config RetryConfig, metrics *observability.Metrics, logger *zap.Logger) grpc.UnaryClientInterceptor {
+	return unaryClientRetryInterceptor(config, metrics, logger, sleep)
+}
+
+// sleep waits for d, reporting false if ctx is done first
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// unaryClientRetryInterceptor is UnaryClientRetryInterceptor waiting out
+// backoffs with wait
+func unaryClientRetryInterceptor(config RetryConfig, metrics *observability.Metrics, logger *zap.Logger, wait func(context.Context, time.Duration) bool) grpc.UnaryClientInterceptor {
+	logger = logger.Named("retry")
+	budget := newRetryBudget(config.Budget)
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+	var randomMutex sync.Mutex
+
+	jitter := func(d time.Duration, fraction float64) time.Duration {
+		randomMutex.Lock()
+		defer randomMutex.Unlock()
+		return d - time.Duration(fraction*random.Float64()*float64(d))
+	}
+
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		policy, exists := config.Methods[method]
+		if !exists || !policy.Idempotent || policy.MaxAttempts <= 1 {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		backoff := policy.InitialBackoff
+		for attempt := 1; ; attempt++ {
+			err := invoker(ctx, method, req, reply, cc, opts...)
+			if err == nil {
+				budget.onSuccess()
+				return nil
+			}
+
+			code := status.Code(err)
+			if !isRetryable(code, policy.RetryableCodes) {
+				return err
+			}
+			allowed := budget.onFailure()
+			if attempt >= policy.MaxAttempts || !allowed {
+				if !allowed {
+					metrics.ClientRetriesThrottled.WithLabelValues(method).Inc()
+				}
+				return err
+			}
+
+			delay := jitter(backoff, policy.Jitter)
+			logger.Warn("Retrying gRPC call",
+				zap.String("method", method),
+				zap.Int("attempt", attempt),
+				zap.String("code", code.String()),
+				zap.Duration("backoff", delay))
+			metrics.ClientRetries.WithLabelValues(method, code.String()).Inc()
+
+			if !wait(ctx, delay) {
+				return err
+			}
+
+			backoff = time.Duration(math.Min(float64(policy.MaxBackoff), float64(backoff)*policy.Multiplier))
+		}
+	}
+}
+
+func isRetryable(code codes.Code, retryable []codes.Code) bool {
+	for _, c := range retryable {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,177 @@
+package resilience
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/your-org/order-processing-system/pkg/observability"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const reserveStock = "/inventory.InventoryService/ReserveStock"
+
+// failingInvoker fails every call with the codes in order, then succeeds
+type failingInvoker struct {
+	codes []codes.Code
+	calls int
+}
+
+func (f *failingInvoker) invoke(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+	f.calls++
+	if f.calls <= len(f.codes) {
+		return status.Error(f.codes[f.calls-1], "failed")
+	}
+	return nil
+}
+
+// newTestRetryInterceptor returns a retry interceptor that records its
+// backoffs instead of waiting them out
+func newTestRetryInterceptor(config RetryConfig, metrics *observability.Metrics) (grpc.UnaryClientInterceptor, *[]time.Duration) {
+	var delays []time.Duration
+	wait := func(ctx context.Context, d time.Duration) bool {
+		delays = append(delays, d)
+		return true
+	}
+	return unaryClientRetryInterceptor(config, metrics, zap.NewNop(), wait), &delays
+}
+
+func repeat(code codes.Code, n int) []codes.Code {
+	failures := make([]codes.Code, n)
+	for i := range failures {
+		failures[i] = code
+	}
+	return failures
+}
+
+func TestRetryBackoffBounds(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts:    6,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     500 * time.Millisecond,
+		Multiplier:     2,
+		Jitter:         0.5,
+		RetryableCodes: []codes.Code{codes.Unavailable},
+		Idempotent:     true,
+	}
+	config := RetryConfig{Methods: map[string]RetryPolicy{reserveStock: policy}, Budget: BudgetConfig{MaxTokens: 100, TokenRatio: 1}}
+	// Un-jittered backoffs grow by the multiplier up to MaxBackoff
+	want := []time.Duration{100, 200, 400, 500, 500}
+
+	for run := 0; run < 50; run++ {
+		interceptor, delays := newTestRetryInterceptor(config, observability.NewMetrics("test"))
+		invoker := &failingInvoker{codes: repeat(codes.Unavailable, 5)}
+
+		if err := interceptor(context.Background(), reserveStock, nil, nil, nil, invoker.invoke); err != nil {
+			t.Fatalf("call error = %v, want success on the last attempt", err)
+		}
+		if len(*delays) != len(want) {
+			t.Fatalf("waited %d times, want %d", len(*delays), len(want))
+		}
+		for i, delay := range *delays {
+			upper := want[i] * time.Millisecond
+			lower := time.Duration(float64(upper) * (1 - policy.Jitter))
+			if delay < lower || delay > upper {
+				t.Errorf("run %d, backoff %d = %v, want between %v and %v", run, i, delay, lower, upper)
+			}
+		}
+	}
+}
+
+func TestRetryStopsAtMaxAttempts(t *testing.T) {
+	config := DefaultRetryConfig()
+	interceptor, _ := newTestRetryInterceptor(config, observability.NewMetrics("test"))
+	invoker := &failingInvoker{codes: repeat(codes.Unavailable, 10)}
+
+	err := interceptor(context.Background(), reserveStock, nil, nil, nil, invoker.invoke)
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("call error = %v, want the last Unavailable", err)
+	}
+	if invoker.calls != config.Methods[reserveStock].MaxAttempts {
+		t.Errorf("made %d attempts, want %d", invoker.calls, config.Methods[reserveStock].MaxAttempts)
+	}
+}
+
+func TestRetryCodeFiltering(t *testing.T) {
+	tests := []struct {
+		code  codes.Code
+		calls int
+	}{
+		{code: codes.Unavailable, calls: 2},
+		{code: codes.Aborted, calls: 2},
+		{code: codes.DeadlineExceeded, calls: 1},
+		{code: codes.InvalidArgument, calls: 1},
+		{code: codes.ResourceExhausted, calls: 1},
+		{code: codes.Internal, calls: 1},
+	}
+
+	for _, tt := range tests {
+		interceptor, _ := newTestRetryInterceptor(DefaultRetryConfig(), observability.NewMetrics("test"))
+		invoker := &failingInvoker{codes: []codes.Code{tt.code}}
+
+		interceptor(context.Background(), reserveStock, nil, nil, nil, invoker.invoke)
+		if invoker.calls != tt.calls {
+			t.Errorf("%s: made %d attempts, want %d", tt.code, invoker.calls, tt.calls)
+		}
+	}
+}
+
+func TestRetrySkipsNonIdempotentMethods(t *testing.T) {
+	for _, method := range []string{"/payment.PaymentService/ProcessPayment", "/unknown.Service/Method"} {
+		interceptor, delays := newTestRetryInterceptor(DefaultRetryConfig(), observability.NewMetrics("test"))
+		invoker := &failingInvoker{codes: []codes.Code{codes.Unavailable}}
+
+		err := interceptor(context.Background(), method, nil, nil, nil, invoker.invoke)
+		if status.Code(err) != codes.Unavailable || invoker.calls != 1 || len(*delays) != 0 {
+			t.Errorf("%s: error %v after %d attempts, want a single attempt", method, err, invoker.calls)
+		}
+	}
+}
+
+func TestRetryBudgetExhaustion(t *testing.T) {
+	config := DefaultRetryConfig()
+	config.Budget = BudgetConfig{MaxTokens: 4, TokenRatio: 1}
+	metrics := observability.NewMetrics("test")
+	interceptor, _ := newTestRetryInterceptor(config, metrics)
+
+	// Each failure costs a token and retries stop once no more than half of
+	// the four tokens remain: the first failure retries, the second does not
+	invoker := &failingInvoker{codes: repeat(codes.Unavailable, 10)}
+	interceptor(context.Background(), reserveStock, nil, nil, nil, invoker.invoke)
+	if invoker.calls != 2 {
+		t.Errorf("made %d attempts with a fresh budget, want 2", invoker.calls)
+	}
+
+	invoker = &failingInvoker{codes: repeat(codes.Unavailable, 10)}
+	interceptor(context.Background(), reserveStock, nil, nil, nil, invoker.invoke)
+	if invoker.calls != 1 {
+		t.Errorf("made %d attempts with an exhausted budget, want 1", invoker.calls)
+	}
+	if got := testutil.ToFloat64(metrics.ClientRetriesThrottled.WithLabelValues(reserveStock)); got != 2 {
+		t.Errorf("client_retries_throttled_total = %v, want 2", got)
+	}
+
+	// Successes refund tokens until retries are allowed again
+	for i := 0; i < 3; i++ {
+		interceptor(context.Background(), reserveStock, nil, nil, nil, (&failingInvoker{}).invoke)
+	}
+	invoker = &failingInvoker{codes: []codes.Code{codes.Unavailable}}
+	if err := interceptor(context.Background(), reserveStock, nil, nil, nil, invoker.invoke); err != nil || invoker.calls != 2 {
+		t.Errorf("after successes: error %v after %d attempts, want a successful retry", err, invoker.calls)
+	}
+}
+
+func TestRetryStopsWhenContextIsDone(t *testing.T) {
+	interceptor := unaryClientRetryInterceptor(DefaultRetryConfig(), observability.NewMetrics("test"), zap.NewNop(), sleep)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	invoker := &failingInvoker{codes: repeat(codes.Unavailable, 10)}
+
+	if err := interceptor(ctx, reserveStock, nil, nil, nil, invoker.invoke); status.Code(err) != codes.Unavailable || invoker.calls != 1 {
+		t.Errorf("error %v after %d attempts, want the first error once the context is done", err, invoker.calls)
+	}
+}
@@ -0,0 +1,177 @@
+// Package slo turns service level objectives declared in a config file into
+// Prometheus recording rules and multiwindow burn-rate alerts.
+package slo
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/prometheus/common/model"
+	"github.com/your-org/order-processing-system/pkg/observability"
+	"google.golang.org/grpc/codes"
+)
+
+// Type is what an objective measures
+type Type string
+
+const (
+	// TypeAvailability counts calls that fail with a server-side error
+	TypeAvailability Type = "availability"
+	// TypeLatency counts calls slower than a threshold
+	TypeLatency Type = "latency"
+)
+
+// DefaultPeriod is the window an error budget is spent over
+const DefaultPeriod = "30d"
+
+// DefaultErrorCodes are the status codes that count against availability;
+// the others are the caller's fault, such as invalid requests and unknown
+// orders, or outcomes of the business rules, such as declined payments
+var DefaultErrorCodes = []string{
+	codes.Unknown.String(),
+	codes.Internal.String(),
+	codes.Unavailable.String(),
+	codes.DeadlineExceeded.String(),
+	codes.DataLoss.String(),
+	codes.Unimplemented.String(),
+}
+
+// Objective is a service level objective for one RPC, or every RPC of a service
+type Objective struct {
+	// Name identifies the objective in rule labels and alerts
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Service is the service label of the metrics, e.g. "order-service"
+	Service string `json:"service"`
+	// Method is the full gRPC method, e.g. "/order.OrderService/CreateOrder";
+	// empty covers every method of the service
+	Method string `json:"method,omitempty"`
+	Type   Type   `json:"type"`
+	// Target is the fraction of calls that must be good, e.g. 0.999
+	Target float64 `json:"target"`
+	// ThresholdSeconds is how fast a call must be for a latency objective; it
+	// must be one of the request duration histogram buckets
+	ThresholdSeconds float64 `json:"threshold_seconds,omitempty"`
+	// ErrorCodes override DefaultErrorCodes for an availability objective
+	ErrorCodes []string `json:"error_codes,omitempty"`
+	// Period is the window the error budget is spent over, e.g. "30d"
+	Period string `json:"period,omitempty"`
+}
+
+// Config is a set of objectives
+type Config struct {
+	Objectives []Objective `json:"objectives"`
+}
+
+// nameRe restricts objective names to what reads well in labels and alerts
+var nameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// LoadConfig reads objectives from a JSON file
+func LoadConfig(path string) (Config, error) {
+	var config Config
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read SLO file: %w", err)
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return Config{}, fmt.Errorf("failed to parse SLO file: %w", err)
+	}
+	if err := config.validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid SLO file %s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+func (c Config) validate() error {
+	if len(c.Objectives) == 0 {
+		return fmt.Errorf("no objectives")
+	}
+
+	names := make(map[string]bool, len(c.Objectives))
+	for _, objective := range c.Objectives {
+		if names[objective.Name] {
+			return fmt.Errorf("duplicate objective %q", objective.Name)
+		}
+		names[objective.Name] = true
+
+		if err := objective.validate(); err != nil {
+			return fmt.Errorf("objective %q: %w", objective.Name, err)
+		}
+	}
+	return nil
+}
+
+func (o Objective) validate() error {
+	if !nameRe.MatchString(o.Name) {
+		return fmt.Errorf("name must be lowercase letters, digits, '-' and '_'")
+	}
+	if o.Service == "" {
+		return fmt.Errorf("service is required")
+	}
+	if o.Target <= 0 || o.Target >= 1 {
+		return fmt.Errorf("target %v must be between 0 and 1", o.Target)
+	}
+
+	period, err := o.period()
+	if err != nil {
+		return err
+	}
+	if longest := burnRateAlerts[len(burnRateAlerts)-1].long; period < longest {
+		return fmt.Errorf("period %s is shorter than the %s alert window", period, longest)
+	}
+
+	switch o.Type {
+	case TypeAvailability:
+		valid := make(map[string]bool)
+		for code := codes.OK; code <= codes.Unauthenticated; code++ {
+			valid[code.String()] = true
+		}
+		for _, code := range o.ErrorCodes {
+			if !valid[code] {
+				return fmt.Errorf("unknown status code %q", code)
+			}
+		}
+	case TypeLatency:
+		if !isDurationBucket(o.ThresholdSeconds) {
+			return fmt.Errorf("threshold %vs is not a request duration bucket %v", o.ThresholdSeconds, observability.RequestDurationBuckets)
+		}
+	default:
+		return fmt.Errorf("unknown type %q", o.Type)
+	}
+	return nil
+}
+
+// period returns the objective's error budget period
+func (o Objective) period() (model.Duration, error) {
+	if o.Period == "" {
+		o.Period = DefaultPeriod
+	}
+	period, err := model.ParseDuration(o.Period)
+	if err != nil {
+		return 0, fmt.Errorf("invalid period: %w", err)
+	}
+	return period, nil
+}
+
+// errorCodes returns the status codes that count against an availability objective
+func (o Objective) errorCodes() []string {
+	if len(o.ErrorCodes) > 0 {
+		return o.ErrorCodes
+	}
+	return DefaultErrorCodes
+}
+
+// isDurationBucket reports whether seconds is a bucket boundary of the
+// request duration histograms, so the share of calls under it can be counted
+func isDurationBucket(seconds float64) bool {
+	for _, bucket := range observability.RequestDurationBuckets {
+		if seconds == bucket {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,206 @@
+package slo
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v3"
+)
+
+// RuleFile is a Prometheus rule file, as listed under rule_files in prometheus.yml
+type RuleFile struct {
+	Groups []RuleGroup `yaml:"groups"`
+}
+
+// RuleGroup is a group of rules evaluated in order
+type RuleGroup struct {
+	Name  string `yaml:"name"`
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule is a recording rule, when Record is set, or an alerting rule
+type Rule struct {
+	Record      string            `yaml:"record,omitempty"`
+	Alert       string            `yaml:"alert,omitempty"`
+	Expr        string            `yaml:"expr"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// burnRateAlert is one multiwindow burn-rate condition, as in the Google SRE
+// workbook: it holds when the error rate over both windows would spend
+// budgetSpent of the error budget within the long window. The short window
+// lets the alert resolve soon after the errors stop.
+type burnRateAlert struct {
+	severity    string
+	long, short model.Duration
+	budgetSpent float64
+}
+
+// burnRateAlerts page on fast burns and open tickets on slow ones; for a 30
+// day period their burn rates are 14.4, 6, 3 and 1. They are ordered by long
+// window.
+var burnRateAlerts = []burnRateAlert{
+	{severity: "page", long: model.Duration(time.Hour), short: model.Duration(5 * time.Minute), budgetSpent: 0.02},
+	{severity: "page", long: model.Duration(6 * time.Hour), short: model.Duration(30 * time.Minute), budgetSpent: 0.05},
+	{severity: "ticket", long: model.Duration(24 * time.Hour), short: model.Duration(2 * time.Hour), budgetSpent: 0.1},
+	{severity: "ticket", long: model.Duration(72 * time.Hour), short: model.Duration(6 * time.Hour), budgetSpent: 0.1},
+}
+
+// AlertName is the name of every generated alert; the slo and severity
+// labels tell them apart
+const AlertName = "SLOErrorBudgetBurn"
+
+// recordName is the recording rule for an objective's error ratio over a window
+func recordName(window model.Duration) string {
+	return "slo:sli_error:ratio_rate" + window.String()
+}
+
+// windows returns every window the alerts use, shortest first
+func windows() []model.Duration {
+	seen := make(map[model.Duration]bool)
+	var all []model.Duration
+	for _, alert := range burnRateAlerts {
+		for _, window := range []model.Duration{alert.short, alert.long} {
+			if !seen[window] {
+				seen[window] = true
+				all = append(all, window)
+			}
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i] < all[j] })
+	return all
+}
+
+// selector returns the label matchers of the objective's metrics plus extra
+func (o Objective) selector(extra ...string) string {
+	matchers := []string{"service=" + strconv.Quote(o.Service)}
+	if o.Method != "" {
+		matchers = append(matchers, "method="+strconv.Quote(o.Method))
+	}
+	return "{" + strings.Join(append(matchers, extra...), ",") + "}"
+}
+
+// labels returns the labels recorded on the objective's series and alerts
+func (o Objective) labels() map[string]string {
+	labels := map[string]string{"slo": o.Name, "service": o.Service}
+	if o.Method != "" {
+		labels["method"] = o.Method
+	}
+	return labels
+}
+
+// bucketMatcher matches the le label of a histogram bucket. Integer bounds
+// are written as "1" in the Prometheus text format and as "1.0" in
+// OpenMetrics, so both are matched.
+func bucketMatcher(seconds float64) string {
+	bound := strconv.FormatFloat(seconds, 'f', -1, 64)
+	pattern := strings.ReplaceAll(bound, ".", `\.`)
+	if !strings.Contains(bound, ".") {
+		pattern += `(\.0)?`
+	}
+	return "le=~" + strconv.Quote(pattern)
+}
+
+// errorRatio returns the expression for the share of bad calls over a window
+func (o Objective) errorRatio(window model.Duration) string {
+	if o.Type == TypeLatency {
+		return fmt.Sprintf("1 - (\n  sum(rate(request_duration_seconds_bucket%s[%s]))\n  /\n  sum(rate(request_duration_seconds_count%s[%s]))\n)",
+			o.selector(bucketMatcher(o.ThresholdSeconds)), window, o.selector(), window)
+	}
+	return fmt.Sprintf("sum(rate(requests_total%s[%s]))\n/\nsum(rate(requests_total%s[%s]))",
+		o.selector("code=~"+strconv.Quote(strings.Join(o.errorCodes(), "|"))), window, o.selector(), window)
+}
+
+// describe returns what the objective promises
+func (o Objective) describe() string {
+	if o.Description != "" {
+		return o.Description
+	}
+
+	calls := o.Service + " calls"
+	if o.Method != "" {
+		calls = o.Method + " calls to " + o.Service
+	}
+	target := strconv.FormatFloat(o.Target*100, 'g', 10, 64) + "%"
+	if o.Type == TypeLatency {
+		return fmt.Sprintf("%s of %s complete within %ss", target, calls, strconv.FormatFloat(o.ThresholdSeconds, 'f', -1, 64))
+	}
+	return fmt.Sprintf("%s of %s succeed", target, calls)
+}
+
+// group returns the recording rules and burn-rate alerts of one objective
+func (o Objective) group() RuleGroup {
+	group := RuleGroup{Name: "slo-" + o.Name}
+	for _, window := range windows() {
+		group.Rules = append(group.Rules, Rule{
+			Record: recordName(window),
+			Expr:   o.errorRatio(window),
+			Labels: o.labels(),
+		})
+	}
+
+	period, _ := o.period()
+	budget := "(1 - " + strconv.FormatFloat(o.Target, 'f', -1, 64) + ")"
+	slo := "{slo=" + strconv.Quote(o.Name) + "}"
+
+	// One alert per severity, firing on any of its conditions
+	conditions := make(map[string][]string)
+	var severities []string
+	for _, alert := range burnRateAlerts {
+		burnRate := alert.budgetSpent * float64(period) / float64(alert.long)
+		threshold := strconv.FormatFloat(math.Round(burnRate*1000)/1000, 'f', -1, 64) + " * " + budget
+		if _, exists := conditions[alert.severity]; !exists {
+			severities = append(severities, alert.severity)
+		}
+		conditions[alert.severity] = append(conditions[alert.severity], fmt.Sprintf("(\n  %s%s > %s\n  and\n  %s%s > %s\n)",
+			recordName(alert.long), slo, threshold, recordName(alert.short), slo, threshold))
+	}
+
+	for _, severity := range severities {
+		labels := o.labels()
+		labels["severity"] = severity
+		group.Rules = append(group.Rules, Rule{
+			Alert:  AlertName,
+			Expr:   strings.Join(conditions[severity], "\nor\n"),
+			Labels: labels,
+			Annotations: map[string]string{
+				"summary":     fmt.Sprintf("SLO %s is burning its %s error budget too fast", o.Name, period),
+				"description": o.describe() + "; {{ $value | humanizePercentage }} of calls are currently bad.",
+			},
+		})
+	}
+	return group
+}
+
+// Generate returns the recording rules and burn-rate alerts of every objective
+func Generate(config Config) (RuleFile, error) {
+	if err := config.validate(); err != nil {
+		return RuleFile{}, fmt.Errorf("invalid SLO config: %w", err)
+	}
+
+	var rules RuleFile
+	for _, objective := range config.Objectives {
+		rules.Groups = append(rules.Groups, objective.group())
+	}
+	return rules, nil
+}
+
+// Marshal writes a rule file in the YAML format Prometheus loads
+func (f RuleFile) Marshal() ([]byte, error) {
+	var b strings.Builder
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(f); err != nil {
+		return nil, fmt.Errorf("failed to encode rules: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode rules: %w", err)
+	}
+	return []byte(b.String()), nil
+}
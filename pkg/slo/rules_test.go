@@ -0,0 +1,177 @@
+package slo
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func validObjective() Objective {
+	return Objective{
+		Name:    "create-order-availability",
+		Service: "order-service",
+		Method:  "/order.OrderService/CreateOrder",
+		Type:    TypeAvailability,
+		Target:  0.999,
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Objective)
+		wantErr string
+	}{
+		{name: "valid availability", modify: func(o *Objective) {}},
+		{name: "valid latency", modify: func(o *Objective) { o.Type, o.ThresholdSeconds = TypeLatency, 0.25 }},
+		{name: "valid error codes", modify: func(o *Objective) { o.ErrorCodes = []string{"Unavailable", "Internal"} }},
+		{name: "target of 1", modify: func(o *Objective) { o.Target = 1 }, wantErr: "target 1 must be between 0 and 1"},
+		{name: "target of 0", modify: func(o *Objective) { o.Target = 0 }, wantErr: "target 0 must be between 0 and 1"},
+		{name: "percentage target", modify: func(o *Objective) { o.Target = 99.9 }, wantErr: "must be between 0 and 1"},
+		{name: "threshold between buckets", modify: func(o *Objective) { o.Type, o.ThresholdSeconds = TypeLatency, 2 }, wantErr: "threshold 2s is not a request duration bucket"},
+		{name: "latency without threshold", modify: func(o *Objective) { o.Type = TypeLatency }, wantErr: "is not a request duration bucket"},
+		{name: "unknown code", modify: func(o *Objective) { o.ErrorCodes = []string{"UNAVAILABLE"} }, wantErr: `unknown status code "UNAVAILABLE"`},
+		{name: "period shorter than alert window", modify: func(o *Objective) { o.Period = "2d" }, wantErr: "period 2d is shorter than the 3d alert window"},
+		{name: "invalid period", modify: func(o *Objective) { o.Period = "a month" }, wantErr: "invalid period"},
+		{name: "unknown type", modify: func(o *Objective) { o.Type = "throughput" }, wantErr: `unknown type "throughput"`},
+		{name: "invalid name", modify: func(o *Objective) { o.Name = "Create Order" }, wantErr: "name must be"},
+		{name: "no service", modify: func(o *Objective) { o.Service = "" }, wantErr: "service is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objective := validObjective()
+			tt.modify(&objective)
+
+			err := Config{Objectives: []Objective{objective}}.validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("validate() error = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigValidateDuplicateName(t *testing.T) {
+	err := Config{Objectives: []Objective{validObjective(), validObjective()}}.validate()
+	if err == nil || !strings.Contains(err.Error(), `duplicate objective "create-order-availability"`) {
+		t.Errorf("validate() error = %v, want duplicate objective", err)
+	}
+
+	if err := (Config{}).validate(); err == nil {
+		t.Error("validate() accepted a config without objectives")
+	}
+}
+
+func TestBurnRateThresholds(t *testing.T) {
+	rules, err := Generate(Config{Objectives: []Objective{validObjective()}})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	alerts := make(map[string]string)
+	for _, rule := range rules.Groups[0].Rules {
+		if rule.Alert != "" {
+			alerts[rule.Labels["severity"]] = rule.Expr
+		}
+	}
+
+	want := map[string][]string{
+		"page": {
+			"slo:sli_error:ratio_rate1h{slo=\"create-order-availability\"} > 14.4 * (1 - 0.999)",
+			"slo:sli_error:ratio_rate5m{slo=\"create-order-availability\"} > 14.4 * (1 - 0.999)",
+			"slo:sli_error:ratio_rate6h{slo=\"create-order-availability\"} > 6 * (1 - 0.999)",
+			"slo:sli_error:ratio_rate30m{slo=\"create-order-availability\"} > 6 * (1 - 0.999)",
+		},
+		"ticket": {
+			"slo:sli_error:ratio_rate1d{slo=\"create-order-availability\"} > 3 * (1 - 0.999)",
+			"slo:sli_error:ratio_rate2h{slo=\"create-order-availability\"} > 3 * (1 - 0.999)",
+			"slo:sli_error:ratio_rate3d{slo=\"create-order-availability\"} > 1 * (1 - 0.999)",
+			"slo:sli_error:ratio_rate6h{slo=\"create-order-availability\"} > 1 * (1 - 0.999)",
+		},
+	}
+	if len(alerts) != len(want) {
+		t.Errorf("alerts for severities %v, want page and ticket", alerts)
+	}
+	for severity, conditions := range want {
+		for _, condition := range conditions {
+			if !strings.Contains(alerts[severity], condition) {
+				t.Errorf("%s alert lacks %q:\n%s", severity, condition, alerts[severity])
+			}
+		}
+	}
+}
+
+func TestBucketMatcher(t *testing.T) {
+	tests := []struct {
+		seconds  float64
+		want     string
+		match    []string
+		mismatch []string
+	}{
+		{seconds: 1, want: `le=~"1(\\.0)?"`, match: []string{"1", "1.0"}, mismatch: []string{"10", "1.5", "0.1"}},
+		{seconds: 10, want: `le=~"10(\\.0)?"`, match: []string{"10", "10.0"}, mismatch: []string{"1", "100"}},
+		{seconds: 2.5, want: `le=~"2\\.5"`, match: []string{"2.5"}, mismatch: []string{"2x5", "25", "2.50"}},
+		{seconds: 0.005, want: `le=~"0\\.005"`, match: []string{"0.005"}, mismatch: []string{"0.0050", "0x005"}},
+	}
+
+	for _, tt := range tests {
+		got := bucketMatcher(tt.seconds)
+		if got != tt.want {
+			t.Errorf("bucketMatcher(%v) = %s, want %s", tt.seconds, got, tt.want)
+			continue
+		}
+
+		// Prometheus anchors regular expression matchers at both ends
+		pattern, err := strconv.Unquote(strings.TrimPrefix(got, "le=~"))
+		if err != nil {
+			t.Fatalf("bucketMatcher(%v) = %s: %v", tt.seconds, got, err)
+		}
+		re := regexp.MustCompile("^(?:" + pattern + ")$")
+		for _, le := range tt.match {
+			if !re.MatchString(le) {
+				t.Errorf("bucketMatcher(%v) does not match le=%q", tt.seconds, le)
+			}
+		}
+		for _, le := range tt.mismatch {
+			if re.MatchString(le) {
+				t.Errorf("bucketMatcher(%v) matches le=%q", tt.seconds, le)
+			}
+		}
+	}
+}
+
+// TestCommittedRulesAreUpToDate fails when deployments/docker/slo-rules.yml
+// was not regenerated after a change to the SLOs or the generator
+func TestCommittedRulesAreUpToDate(t *testing.T) {
+	const (
+		configPath = "deployments/docker/slos.json"
+		rulesPath  = "deployments/docker/slo-rules.yml"
+	)
+
+	config, err := LoadConfig("../../" + configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	rules, err := Generate(config)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	data, err := rules.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	want := "# Generated by cmd/slo-rules from " + configPath + ". DO NOT EDIT.\n" + string(data)
+
+	committed, err := os.ReadFile("../../" + rulesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(committed) != want {
+		t.Errorf("%s is out of date with %s; run make slo-rules", rulesPath, configPath)
+	}
+}
@@ -0,0 +1,56 @@
+// Package validation enforces the buf.validate constraints declared on the
+// service protos.
+package validation
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bufbuild/protovalidate-go"
+	"github.com/your-org/order-processing-system/pkg/apierrors"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// UnaryServerInterceptor creates a gRPC unary server interceptor that rejects
+// requests violating their proto constraints with InvalidArgument and a
+// BadRequest detail listing every violated field
+func UnaryServerInterceptor(validator *protovalidate.Validator, logger *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		msg, ok := req.(proto.Message)
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		if err := validator.Validate(msg); err != nil {
+			var validationErr *protovalidate.ValidationError
+			if !errors.As(err, &validationErr) {
+				// The constraints themselves are broken, which is a bug rather than a bad request
+				logger.Error("Failed to validate request", zap.String("method", info.FullMethod), zap.Error(err))
+				return nil, status.Error(codes.Internal, "failed to validate request")
+			}
+
+			violations := make([]apierrors.FieldViolation, 0, len(validationErr.Violations))
+			for _, v := range validationErr.Violations {
+				violations = append(violations, apierrors.FieldViolation{
+					// Message-level constraints have no field path; their ID names what they check
+					Field:       fieldOrConstraint(v.FieldPath, v.ConstraintId),
+					Description: v.Message,
+				})
+			}
+			return nil, apierrors.InvalidArgument(violations...)
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func fieldOrConstraint(fieldPath, constraintID string) string {
+	if fieldPath != "" {
+		return fieldPath
+	}
+	return constraintID
+}
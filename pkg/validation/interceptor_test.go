@@ -0,0 +1,99 @@
+package validation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bufbuild/protovalidate-go"
+	"github.com/your-org/order-processing-system/pkg/apierrors"
+	orderpb "github.com/your-org/order-processing-system/pkg/pb/order"
+	paymentpb "github.com/your-org/order-processing-system/pkg/pb/payment"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func validRequest() *orderpb.CreateOrderRequest {
+	return &orderpb.CreateOrderRequest{
+		CustomerId: "customer-1",
+		Items: []*orderpb.OrderItem{
+			{ProductId: "product-1", Quantity: 1, UnitPrice: 999.99},
+			{ProductId: "product-2", Quantity: 2, UnitPrice: 29.99},
+		},
+		PaymentMethod: &paymentpb.PaymentMethod{Method: &paymentpb.PaymentMethod_Token{Token: "pm_1"}},
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	validator, err := protovalidate.New()
+	if err != nil {
+		t.Fatalf("protovalidate.New() error = %v", err)
+	}
+	interceptor := UnaryServerInterceptor(validator, zap.NewNop())
+	info := &grpc.UnaryServerInfo{FullMethod: "/order.OrderService/CreateOrder"}
+
+	tests := []struct {
+		name       string
+		modify     func(*orderpb.CreateOrderRequest)
+		violations []string
+	}{
+		{name: "valid", modify: func(req *orderpb.CreateOrderRequest) {}},
+		{name: "empty customer ID", modify: func(req *orderpb.CreateOrderRequest) { req.CustomerId = "" }, violations: []string{"customer_id"}},
+		{name: "zero quantity", modify: func(req *orderpb.CreateOrderRequest) { req.Items[0].Quantity = 0 }, violations: []string{"items[0].quantity"}},
+		{name: "negative quantity", modify: func(req *orderpb.CreateOrderRequest) { req.Items[1].Quantity = -1 }, violations: []string{"items[1].quantity"}},
+		{
+			name:       "duplicate product IDs",
+			modify:     func(req *orderpb.CreateOrderRequest) { req.Items[1].ProductId = "product-1" },
+			violations: []string{"items.unique_product_id"},
+		},
+		{
+			name: "every violation",
+			modify: func(req *orderpb.CreateOrderRequest) {
+				req.CustomerId = ""
+				req.Items[0].Quantity = 0
+				req.Items[1].ProductId = "product-1"
+			},
+			violations: []string{"customer_id", "items[0].quantity", "items.unique_product_id"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := validRequest()
+			tt.modify(req)
+
+			called := false
+			_, err := interceptor(context.Background(), req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				called = true
+				return nil, nil
+			})
+
+			if tt.violations == nil {
+				if err != nil || !called {
+					t.Errorf("valid request: error = %v, handler called = %v", err, called)
+				}
+				return
+			}
+			if called {
+				t.Error("handler called for an invalid request")
+			}
+			if code := status.Code(err); code != codes.InvalidArgument {
+				t.Fatalf("code = %v, want InvalidArgument (error %v)", code, err)
+			}
+
+			got := make(map[string]bool)
+			for _, violation := range apierrors.FieldViolations(err) {
+				got[violation.Field] = true
+			}
+			if len(got) != len(tt.violations) {
+				t.Errorf("violations = %+v, want fields %v", apierrors.FieldViolations(err), tt.violations)
+			}
+			for _, field := range tt.violations {
+				if !got[field] {
+					t.Errorf("no violation for %s in %+v", field, apierrors.FieldViolations(err))
+				}
+			}
+		})
+	}
+}
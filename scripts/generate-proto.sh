@@ -24,23 +24,34 @@ if ! command -v protoc-gen-go-grpc &> /dev/null; then
     go install google.golang.org/grpc/cmd/protoc-gen-go-grpc@latest
 fi
 
-# Generate Go code for each proto file
+# The buf.validate constraint definitions imported by the protos come from the
+# protovalidate module pinned in go.mod, so they always match the version
+# protovalidate-go checks requests against
+PROTOVALIDATE_DESCRIPTORS=$(mktemp)
+trap 'rm -f "$PROTOVALIDATE_DESCRIPTORS"' EXIT
+go run scripts/protovalidate_descriptors.go > "$PROTOVALIDATE_DESCRIPTORS"
+
+# Generate Go code for each proto file into the package named by its
+# go_package, e.g. pkg/pb/order
 echo "Generating Go code from Protocol Buffer definitions..."
+MODULE=github.com/your-org/order-processing-system
 
 # Order service
-protoc --go_out=. --go_opt=paths=source_relative \
-       --go-grpc_out=. --go-grpc_opt=paths=source_relative \
+protoc -I . --descriptor_set_in="$PROTOVALIDATE_DESCRIPTORS" \
+       --go_out=. --go_opt=module=$MODULE \
+       --go-grpc_out=. --go-grpc_opt=module=$MODULE \
        api/proto/order.proto
 
 # Inventory service
-protoc --go_out=. --go_opt=paths=source_relative \
-       --go-grpc_out=. --go-grpc_opt=paths=source_relative \
+protoc -I . --descriptor_set_in="$PROTOVALIDATE_DESCRIPTORS" \
+       --go_out=. --go_opt=module=$MODULE \
+       --go-grpc_out=. --go-grpc_opt=module=$MODULE \
        api/proto/inventory.proto
 
 # Payment service
-protoc --go_out=. --go_opt=paths=source_relative \
-       --go-grpc_out=. --go-grpc_opt=paths=source_relative \
+protoc -I . --descriptor_set_in="$PROTOVALIDATE_DESCRIPTORS" \
+       --go_out=. --go_opt=module=$MODULE \
+       --go-grpc_out=. --go-grpc_opt=module=$MODULE \
        api/proto/payment.proto
 
 echo "Protocol Buffer code generation completed successfully!"
-
@@ -0,0 +1,52 @@
+//go:build ignore
+
+// Command protovalidate_descriptors writes the buf.validate definitions built
+// into the protovalidate module pinned in go.mod, with everything they import,
+// as a FileDescriptorSet for protoc's --descriptor_set_in. The protos are then
+// compiled against exactly the constraints protovalidate-go enforces.
+//
+//	go run scripts/protovalidate_descriptors.go > protovalidate.binpb
+package main
+
+import (
+	"log"
+	"os"
+
+	_ "buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func main() {
+	validate, err := protoregistry.GlobalFiles.FindFileByPath("buf/validate/validate.proto")
+	if err != nil {
+		log.Fatalf("protovalidate descriptors not found: %v", err)
+	}
+
+	// Imports go before the files that use them
+	set := &descriptorpb.FileDescriptorSet{}
+	seen := make(map[string]bool)
+	var add func(file protoreflect.FileDescriptor)
+	add = func(file protoreflect.FileDescriptor) {
+		if seen[file.Path()] {
+			return
+		}
+		seen[file.Path()] = true
+		for i := 0; i < file.Imports().Len(); i++ {
+			add(file.Imports().Get(i).FileDescriptor)
+		}
+		set.File = append(set.File, protodesc.ToFileDescriptorProto(file))
+	}
+	add(validate)
+
+	data, err := proto.Marshal(set)
+	if err != nil {
+		log.Fatalf("failed to encode descriptors: %v", err)
+	}
+	if _, err := os.Stdout.Write(data); err != nil {
+		log.Fatalf("failed to write descriptors: %v", err)
+	}
+}